@@ -1,12 +1,19 @@
 package main
 
 import (
+	"bufio"
+	"encoding/json"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/MusicalNinjaDad/snaggle/elf"
 	. "github.com/MusicalNinjaDad/snaggle/internal"
 	. "github.com/MusicalNinjaDad/snaggle/internal/testing"
 )
@@ -42,6 +49,409 @@ func Test(t *testing.T) {
 	}
 }
 
+func TestSysroot(t *testing.T) {
+	Assert := Assert(t)
+	sysroot := BuildSysroot(t)
+	dest := WorkspaceTempDir(t)
+
+	expectedFiles := map[string]string{
+		filepath.Join(sysroot, "opt/app/hello_dynamic"):                 filepath.Join(dest, "bin/hello"),
+		filepath.Join(sysroot, "opt/sysroot-libs/ld-linux-x86-64.so.2"): filepath.Join(dest, "lib64/ld-linux-x86-64.so.2"),
+		filepath.Join(sysroot, "opt/sysroot-libs/libc.so.6"):            filepath.Join(dest, "lib64/libc.so.6"),
+	}
+
+	snaggle := exec.Command(snaggleBin, "--sysroot", sysroot, "/usr/bin/hello", dest)
+	_, err := snaggle.Output()
+	if !Assert.Testify.NoError(err) {
+		var exiterr *exec.ExitError
+		Assert.Testify.ErrorAs(err, &exiterr)
+		t.Logf("Stderr: %s", exiterr.Stderr)
+	}
+
+	Assert.DirectoryContents(expectedFiles, dest)
+}
+
+func TestMissingDependencies(t *testing.T) {
+	Assert := Assert(t)
+	dest := WorkspaceTempDir(t)
+	bin, err := filepath.Abs("../../elf/testdata/rpath/bin/missing")
+	Assert.Testify.NoError(err)
+	libdir := filepath.Dir(bin) + "/../lib"
+
+	expectedErr := []string{
+		"Error: these libraries could not be found, nothing has been snagged:",
+		"libmissing1.so (needed by " + bin + ")",
+		"libmissing2.so (needed by " + bin + ")",
+		"libb.so (needed by " + libdir + "/liba.so)",
+	}
+
+	snaggle := exec.Command(snaggleBin, bin, dest)
+	stdout, err := snaggle.Output()
+
+	Assert.Testify.Empty(stdout)
+	Assert.DirectoryContents(nil, dest)
+
+	var exitError *exec.ExitError
+	if Assert.Testify.ErrorAs(err, &exitError) {
+		Assert.Testify.Equal(1, exitError.ExitCode())
+		Assert.Testify.Equal(expectedErr, StripLines(string(exitError.Stderr)))
+	}
+}
+
+func TestVerifySymbols(t *testing.T) {
+	Assert := Assert(t)
+	dest := WorkspaceTempDir(t)
+	bin, err := filepath.Abs("../../elf/testdata/symbols/bin/newer")
+	Assert.Testify.NoError(err)
+
+	expectedErr := []string{
+		"Error: these symbols could not be resolved, nothing has been snagged:",
+		"newfunc@SYM_2.0 (libsym.so) (needed by " + bin + ")",
+	}
+
+	snaggle := exec.Command(snaggleBin, "--verify-symbols", bin, dest)
+	stdout, err := snaggle.Output()
+
+	Assert.Testify.Empty(stdout)
+	Assert.DirectoryContents(nil, dest)
+
+	var exitError *exec.ExitError
+	if Assert.Testify.ErrorAs(err, &exitError) {
+		Assert.Testify.Equal(1, exitError.ExitCode())
+		Assert.Testify.Equal(expectedErr, StripLines(string(exitError.Stderr)))
+	}
+}
+
+func TestTargetISA(t *testing.T) {
+	Assert := Assert(t)
+	dest := WorkspaceTempDir(t)
+	bin, err := filepath.Abs("../../elf/testdata/hwcaps/bin/hw")
+	Assert.Testify.NoError(err)
+
+	snaggle := exec.Command(snaggleBin, "--isa", "x86-64-v3", "--all-hwcaps", bin, dest)
+	_, err = snaggle.Output()
+	Assert.Testify.NoError(err)
+	for _, snagged := range []string{"lib/libhw.so", "lib/glibc-hwcaps/x86-64-v2/libhw.so", "lib/glibc-hwcaps/x86-64-v3/libhw.so"} {
+		Assert.Testify.FileExists(filepath.Join(dest, snagged))
+	}
+
+	snaggle = exec.Command(snaggleBin, "--isa", "x86-64-v5", bin, dest)
+	_, err = snaggle.Output()
+	var exitError *exec.ExitError
+	if Assert.Testify.ErrorAs(err, &exitError) {
+		Assert.Testify.Equal(2, exitError.ExitCode())
+	}
+}
+
+func TestDlopen(t *testing.T) {
+	Assert := Assert(t)
+	dest := WorkspaceTempDir(t)
+	bin, err := filepath.Abs("../../elf/testdata/dlopen/bin/app")
+	Assert.Testify.NoError(err)
+
+	snaggle := exec.Command(snaggleBin, "--dlopen", "suggested", bin, dest)
+	_, err = snaggle.Output()
+	Assert.Testify.NoError(err)
+	for _, plugin := range []string{"req", "dep", "rec", "sug"} {
+		Assert.Testify.FileExists(filepath.Join(dest, "lib/libplugin-"+plugin+".so"))
+	}
+
+	snaggle = exec.Command(snaggleBin, "--dlopen", "optional", bin, dest)
+	_, err = snaggle.Output()
+	var exitError *exec.ExitError
+	if Assert.Testify.ErrorAs(err, &exitError) {
+		Assert.Testify.Equal(2, exitError.ExitCode())
+	}
+}
+
+func TestLdSoPreload(t *testing.T) {
+	Assert := Assert(t)
+	sysroot := BuildSysroot(t)
+	dest := WorkspaceTempDir(t)
+	Assert.Testify.NoError(Copy("../../elf/testdata/filter/lib/libfiltee.so", filepath.Join(sysroot, "opt/sysroot-libs/libfiltee.so")))
+	Assert.Testify.NoError(os.WriteFile(filepath.Join(sysroot, "etc/ld.so.preload"), []byte("libfiltee.so\n"), 0664))
+
+	snaggle := exec.Command(snaggleBin, "--sysroot", sysroot, "--ld-so-preload", "/usr/bin/hello", dest)
+	_, err := snaggle.Output()
+	Assert.Testify.NoError(err)
+	Assert.Testify.FileExists(filepath.Join(dest, "lib64/libfiltee.so"))
+	Assert.Testify.FileExists(filepath.Join(dest, "etc/ld.so.preload"))
+}
+
+func TestScripts(t *testing.T) {
+	Assert := Assert(t)
+	sysroot := BuildSysroot(t)
+	dest := WorkspaceTempDir(t)
+	Assert.Testify.NoError(os.WriteFile(filepath.Join(sysroot, "opt/app/run.sh"), []byte("#!/usr/bin/hello\n"), 0775))
+
+	snaggle := exec.Command(snaggleBin, "--sysroot", sysroot, "--scripts", "/opt/app", dest)
+	_, err := snaggle.Output()
+	Assert.Testify.NoError(err)
+	Assert.Testify.FileExists(filepath.Join(dest, "bin/run.sh"))
+	Assert.Testify.FileExists(filepath.Join(dest, "usr/bin/hello"))
+	Assert.Testify.FileExists(filepath.Join(dest, "lib64/libc.so.6"))
+}
+
+func TestCommands(t *testing.T) {
+	Assert := Assert(t)
+	sysroot := BuildSysroot(t)
+	dest := WorkspaceTempDir(t)
+	Assert.Testify.NoError(os.MkdirAll(filepath.Join(sysroot, "bin"), 0775))
+	Assert.Testify.NoError(Copy(P_hello_dynamic, filepath.Join(sysroot, "bin/sh")))
+	Assert.Testify.NoError(os.WriteFile(filepath.Join(sysroot, "opt/app/run.sh"), []byte("#!/bin/sh\nhello\nmissing-tool\n"), 0775))
+
+	snaggle := exec.Command(snaggleBin, "--sysroot", sysroot, "--commands", "--path", "/usr/bin", "/opt/app/run.sh", dest)
+	var stderr strings.Builder
+	snaggle.Stderr = &stderr
+	Assert.Testify.NoError(snaggle.Run())
+	Assert.Testify.FileExists(filepath.Join(dest, "bin/hello"))
+	Assert.Testify.Equal("Warning: these commands could not be resolved and have not been snagged:\n  missing-tool (/opt/app/run.sh:3)\n", stderr.String())
+}
+
+func TestPid(t *testing.T) {
+	Assert := Assert(t)
+	dest := WorkspaceTempDir(t)
+	process := exec.Command("../../elf/testdata/process/bin/plugged")
+	stdout, err := process.StdoutPipe()
+	Assert.Testify.NoError(err)
+	if !Assert.Testify.NoError(process.Start()) {
+		return
+	}
+	defer func() {
+		_ = process.Process.Kill()
+		_ = process.Wait()
+	}()
+	ready, err := bufio.NewReader(stdout).ReadString('\n')
+	if !Assert.Testify.NoError(err) || !Assert.Testify.Equal("ready\n", ready) {
+		return // plugin not loaded
+	}
+	pid := strconv.Itoa(process.Process.Pid)
+
+	snaggle := exec.Command(snaggleBin, "--pid", pid, dest)
+	_, err = snaggle.Output()
+	Assert.Testify.NoError(err)
+	Assert.Testify.FileExists(filepath.Join(dest, "bin/plugged"))
+	Assert.Testify.FileExists(filepath.Join(dest, "lib64/libplugged-plugin.so"))
+
+	for _, args := range [][]string{{"--pid", pid, "--in-place", dest}, {"--pid", pid, "bin/plugged", dest}} {
+		snaggle = exec.Command(snaggleBin, args...)
+		_, err = snaggle.Output()
+		var exitError *exec.ExitError
+		if Assert.Testify.ErrorAs(err, &exitError) {
+			Assert.Testify.Equal(2, exitError.ExitCode())
+		}
+	}
+}
+
+func TestPidNamespace(t *testing.T) {
+	Assert := Assert(t)
+	sleep, err := exec.LookPath("sleep")
+	Assert.Testify.NoError(err)
+	sleep, err = filepath.EvalSymlinks(sleep)
+	Assert.Testify.NoError(err)
+
+	process := exec.Command("unshare", "--mount", sleep, "30")
+	if err := process.Start(); err != nil {
+		t.Skip("cannot unshare:", err)
+	}
+	defer func() {
+		_ = process.Process.Kill()
+		_ = process.Wait()
+	}()
+	exe := filepath.Join("/proc", strconv.Itoa(process.Process.Pid), "exe")
+	for range 100 { // until unshare has exec'd sleep, in the new namespace
+		if running, _ := os.Readlink(exe); running == sleep {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if running, _ := os.Readlink(exe); running != sleep {
+		t.Skip("cannot unshare the mount namespace")
+	}
+
+	dest := WorkspaceTempDir(t)
+	snaggle := exec.Command(snaggleBin, "--pid", strconv.Itoa(process.Process.Pid), dest)
+	stdout, err := snaggle.Output()
+
+	Assert.Testify.Empty(stdout)
+	Assert.DirectoryContents(nil, dest)
+
+	var exitError *exec.ExitError
+	if Assert.Testify.ErrorAs(err, &exitError) {
+		Assert.Testify.Equal(1, exitError.ExitCode())
+		Assert.Testify.Contains(string(exitError.Stderr), "cannot snag a process with a different mount namespace or root directory")
+		Assert.Testify.NotContains(string(exitError.Stderr), rootCmd.UsageString())
+	}
+}
+
+func TestTree(t *testing.T) {
+	Assert := Assert(t)
+	bin, err := filepath.Abs("../../elf/testdata/rpath/bin/rpath")
+	Assert.Testify.NoError(err)
+	libdir := filepath.Dir(bin) + "/../lib"
+
+	expected := []string{
+		bin + " (interpreter => " + P_ld_linux + ")",
+		"liba.so => " + libdir + "/liba.so",
+		"libb.so => " + libdir + "/libb.so",
+		"libc.so.6 => " + P_libc,
+		"ld-linux-x86-64.so.2 => " + P_ld_linux,
+	}
+
+	stdout, err := exec.Command(snaggleBin, "tree", bin).Output()
+	Assert.Testify.NoError(err)
+	Assert.Testify.Equal(expected, StripLines(string(stdout)))
+}
+
+func TestWhy(t *testing.T) {
+	Assert := Assert(t)
+	bin, err := filepath.Abs("../../elf/testdata/rpath/bin/rpath")
+	Assert.Testify.NoError(err)
+	libdir := filepath.Dir(bin) + "/../lib"
+
+	stdout, err := exec.Command(snaggleBin, "why", "libb.so", bin).Output()
+	Assert.Testify.NoError(err)
+	lines := StripLines(string(stdout))
+	if Assert.Testify.GreaterOrEqual(len(lines), 4) {
+		Assert.Testify.Equal([]string{
+			bin + " -> liba.so -> libb.so",
+			"libb.so => " + libdir + "/libb.so",
+			"DT_RPATH $ORIGIN/../lib of " + bin + ": " + libdir + "/libb.so used",
+			"ld.so.cache: not listed",
+		}, lines[:4])
+	}
+
+	// every location searched for a missing library
+	missing := filepath.Dir(bin) + "/missing"
+	stdout, err = exec.Command(snaggleBin, "why", "libb.so", missing).Output()
+	Assert.Testify.NoError(err)
+	lines = StripLines(string(stdout))
+	if Assert.Testify.GreaterOrEqual(len(lines), 3) {
+		Assert.Testify.Equal([]string{
+			missing + " -> liba.so -> libb.so",
+			"libb.so => not found",
+		}, lines[:2])
+		Assert.Testify.Contains(lines, "ld.so.cache: not listed")
+		for _, line := range lines[2:] {
+			Assert.Testify.NotContains(line, " used")
+		}
+	}
+
+	stdout, err = exec.Command(snaggleBin, "why", "libnothing.so", bin).Output()
+	Assert.Testify.NoError(err)
+	Assert.Testify.Equal([]string{"libnothing.so is not needed"}, StripLines(string(stdout)))
+}
+
+func TestInspect(t *testing.T) {
+	Assert := Assert(t)
+	bin, err := filepath.Abs("../../elf/testdata/rpath/bin/rpath")
+	Assert.Testify.NoError(err)
+
+	stdout, err := exec.Command(snaggleBin, "inspect", bin).Output()
+	Assert.Testify.NoError(err)
+	lines := StripLines(string(stdout))
+	Assert.Testify.Contains(lines, "Type:         DYNEXE")
+	Assert.Testify.Contains(lines, "RPATH:        $ORIGIN/../lib")
+	Assert.Testify.Contains(lines, "Stripped:     false")
+
+	// errors are reported as part of the output
+	lib, err := filepath.Abs("../../elf/testdata/rpath/lib/liba.so")
+	Assert.Testify.NoError(err)
+	stdout, err = exec.Command(snaggleBin, "inspect", "--json", lib).Output()
+	var exitError *exec.ExitError
+	if Assert.Testify.ErrorAs(err, &exitError) {
+		Assert.Testify.Equal(1, exitError.ExitCode())
+		Assert.Testify.Empty(exitError.Stderr)
+	}
+	var inspected map[string]any
+	Assert.Testify.NoError(json.Unmarshal(stdout, &inspected))
+	Assert.Testify.Equal("liba.so", inspected["elf"].(map[string]any)["soname"])
+	Assert.Testify.Equal("DYN", inspected["elf"].(map[string]any)["type"])
+	Assert.Testify.Len(inspected["errors"], 1)
+}
+
+func TestCompat(t *testing.T) {
+	Assert := Assert(t)
+	root, err := filepath.Abs("../../elf/testdata/compat/root")
+	Assert.Testify.NoError(err)
+
+	stdout, err := exec.Command(snaggleBin, "compat", root).Output()
+	var exitError *exec.ExitError
+	if Assert.Testify.ErrorAs(err, &exitError) {
+		Assert.Testify.Equal(1, exitError.ExitCode())
+		Assert.Testify.Empty(exitError.Stderr)
+	}
+	Assert.Testify.Equal([]string{
+		"Needs glibc:  GLIBC_2.17",
+		"Min kernel:   4.4.0",
+		"ISA level:    x86-64-v3",
+		"",
+		"PATH              GLIBC        KERNEL  ISA        BUNDLED GLIBC",
+		"/bin/new          GLIBC_2.17   4.4.0   x86-64-v3  GLIBC_2.2.5 (too old)",
+		"/bin/old          GLIBC_2.2.5  -       -          GLIBC_2.2.5",
+		"/lib64/libc.so.6  -            -       -          GLIBC_2.2.5",
+	}, StripLines(string(stdout)))
+
+	stdout, err = exec.Command(snaggleBin, "compat", "--json", root).Output()
+	Assert.Testify.Error(err)
+	var report map[string]any
+	Assert.Testify.NoError(json.Unmarshal(stdout, &report))
+	Assert.Testify.Equal("GLIBC_2.17", report["glibcNeeded"])
+	Assert.Testify.Len(report["binaries"], 3)
+}
+
+func TestTreeFormats(t *testing.T) {
+	Assert := Assert(t)
+	bin, err := filepath.Abs("../../elf/testdata/rpath/bin/missing")
+	Assert.Testify.NoError(err)
+
+	// missing libraries are highlighted, not an error
+	stdout, err := exec.Command(snaggleBin, "tree", "--format", "json", bin).Output()
+	Assert.Testify.NoError(err)
+	var trees []treeNode
+	Assert.Testify.NoError(json.Unmarshal(stdout, &trees))
+	if Assert.Testify.Len(trees, 1) {
+		Assert.Testify.Equal(bin, trees[0].Path)
+		Assert.Testify.Contains(trees[0].Needs, &treeNode{Soname: "libmissing1.so", Missing: true})
+	}
+
+	stdout, err = exec.Command(snaggleBin, "tree", "--format", "dot", bin).Output()
+	Assert.Testify.NoError(err)
+	Assert.Testify.True(strings.HasPrefix(string(stdout), "digraph snaggle {\n"))
+	Assert.Testify.Contains(string(stdout), `"libmissing1.so (not found)" [label="libmissing1.so\nnot found", color=red, fontcolor=red, style=dashed];`)
+	Assert.Testify.Contains(string(stdout), `"`+bin+`" -> "libmissing1.so (not found)" [label="libmissing1.so"];`)
+
+	_, err = exec.Command(snaggleBin, "tree", "--format", "yaml", bin).Output()
+	var exitError *exec.ExitError
+	if Assert.Testify.ErrorAs(err, &exitError) {
+		Assert.Testify.Equal(2, exitError.ExitCode())
+	}
+}
+
+func TestTreeCycle(t *testing.T) {
+	Assert := assert.New(t)
+	root := &elf.Elf{Path: "/bin/app"}
+	graph := &elf.Graph{
+		Root:  root,
+		Nodes: map[string]*elf.Elf{"/bin/app": root, "/lib/liba.so": {}, "/lib/libb.so": {}},
+		Edges: []elf.Edge{
+			{From: "/bin/app", To: "/lib/liba.so", Soname: "liba.so"},
+			{From: "/bin/app", To: "/lib/libb.so", Soname: "libb.so"},
+			{From: "/lib/liba.so", To: "/lib/libb.so", Soname: "libb.so"},
+			{From: "/lib/libb.so", To: "/lib/liba.so", Soname: "liba.so"},
+		},
+	}
+
+	var text strings.Builder
+	writeTree(&text, newTree(graph), "")
+	expected := "/bin/app\n" +
+		"    liba.so => /lib/liba.so\n" +
+		"        libb.so => /lib/libb.so\n" +
+		"            liba.so => /lib/liba.so [cycle]\n" +
+		"    libb.so => /lib/libb.so [duplicate]\n"
+	Assert.Equal(expected, text.String())
+}
+
 func TestInvalidNumberArgs(t *testing.T) {
 	Assert := assert.New(t)
 
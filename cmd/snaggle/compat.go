@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/MusicalNinjaDad/snaggle"
+	"github.com/MusicalNinjaDad/snaggle/elf"
+)
+
+var compatJSON bool
+
+func init() {
+	compatCmd.Flags().BoolVar(&compatJSON, "json", false, "Output as JSON")
+	subcommandHelp(compatCmd)
+}
+
+var compatCmd = &cobra.Command{
+	Use:                   "compat [--json] DESTINATION",
+	Short:                 "Report the minimum glibc, kernel & x86-64 level needed to run DESTINATION",
+	SilenceUsage:          true,
+	DisableFlagsInUseLine: true,
+	Long: `Report the minimum glibc, kernel & x86-64 micro-architecture level needed to run everything which
+has been snagged to DESTINATION, and for each binary & library in DESTINATION:
+
+  - the highest glibc symbol version it needs (e.g. GLIBC_2.34)
+  - the minimum kernel version from its .note.ABI-tag
+  - the x86-64 micro-architecture level from its GNU_PROPERTY_X86_ISA_1_NEEDED (e.g. x86-64-v3)
+  - the highest glibc symbol version provided by the libc.so.6 bundled in DESTINATION
+
+Anything which needs a newer glibc than the bundled libc.so.6 provides is flagged, in which case the
+exit code is 1.
+`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		report, err := snaggle.Compat(args[0])
+		if err != nil && !errors.Is(err, snaggle.ErrIncompatible) {
+			return err
+		}
+		if err != nil {
+			cmd.SilenceErrors = true // already flagged in the output
+		}
+
+		if compatJSON {
+			encoder := json.NewEncoder(cmd.OutOrStdout())
+			encoder.SetIndent("", "  ")
+			if encErr := encoder.Encode(report); encErr != nil {
+				return encErr
+			}
+		} else {
+			writeCompat(cmd.OutOrStdout(), report)
+		}
+		return err
+	},
+}
+
+// Writes the overall requirements, followed by a table of each binary
+func writeCompat(out io.Writer, report snaggle.CompatReport) {
+	orNone := func(value string) string {
+		if value == "" {
+			return "-"
+		}
+		return value
+	}
+
+	_, _ = fmt.Fprintln(out, "Needs glibc:  "+orNone(report.GlibcNeeded))
+	_, _ = fmt.Fprintln(out, "Min kernel:   "+orNone(report.MinKernel))
+	_, _ = fmt.Fprintln(out, "ISA level:    "+orNone(elf.ISALevelName(report.ISALevel)))
+	_, _ = fmt.Fprintln(out)
+
+	table := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(table, "PATH\tGLIBC\tKERNEL\tISA\tBUNDLED GLIBC")
+	for _, binary := range report.Binaries {
+		bundled := orNone(binary.BundledGlibc)
+		if binary.Incompatible {
+			bundled += " (too old)"
+		}
+		_, _ = fmt.Fprintln(table, strings.Join([]string{
+			binary.Path, orNone(binary.GlibcNeeded), orNone(binary.MinKernel), orNone(elf.ISALevelName(binary.ISALevel)), bundled,
+		}, "\t"))
+	}
+	_ = table.Flush()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/MusicalNinjaDad/snaggle/elf"
+)
+
+var inspectJSON bool
+var inspectOptions []elf.Option
+
+func init() {
+	inspectCmd.Flags().BoolVar(&inspectJSON, "json", false, "Output as JSON")
+	inspectCmd.Flags().Func("sysroot", "Resolve FILE within the root filesystem at `SYSROOT` rather than the host", func(sysroot string) error {
+		inspectOptions = append(inspectOptions, elf.Sysroot(sysroot))
+		return nil
+	})
+	subcommandHelp(inspectCmd)
+}
+
+var inspectCmd = &cobra.Command{
+	Use:                   "inspect [--json] [--sysroot SYSROOT] FILE",
+	Short:                 "Print everything snaggle knows about FILE",
+	SilenceUsage:          true,
+	DisableFlagsInUseLine: true,
+	Long: `Print everything snaggle knows about FILE: the details used to decide how it is snagged (class,
+type, interpreter & dependencies) plus its soname, machine, build-id, DT_RPATH, DT_RUNPATH and whether
+it has been stripped.
+
+Any problems found while parsing FILE are listed under Errors, in which case the exit code is 1.
+`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		parsed, err := elf.New(args[0], inspectOptions...)
+		if parsed.Class == elf.EI_CLASS(elf.ELFNONE) {
+			return err // not even an ELF, so there is nothing to inspect
+		}
+		inspection := newInspection(parsed, err)
+		if err != nil {
+			cmd.SilenceErrors = true // already listed in the output
+		}
+
+		if inspectJSON {
+			encoder := json.NewEncoder(cmd.OutOrStdout())
+			encoder.SetIndent("", "  ")
+			if encErr := encoder.Encode(inspection); encErr != nil {
+				return encErr
+			}
+		} else {
+			writeInspection(cmd.OutOrStdout(), inspection)
+		}
+		return err
+	},
+}
+
+// An ELF, and every error found while parsing it, as reported by `snaggle inspect`
+type inspection struct {
+	Elf    elf.Elf  `json:"elf"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Collects every error in err alongside parsed
+func newInspection(parsed elf.Elf, err error) inspection {
+	inspection := inspection{Elf: parsed}
+	var errelf *elf.ErrElf
+	if errors.As(err, &errelf) {
+		for _, err := range errelf.Unwrap() {
+			inspection.Errors = append(inspection.Errors, err.Error())
+		}
+	}
+	return inspection
+}
+
+// Writes inspection in the same style as [elf.Elf.String]
+func writeInspection(out io.Writer, inspection inspection) {
+	_, _ = io.WriteString(out, inspection.Elf.String())
+	if len(inspection.Errors) > 0 {
+		_, _ = fmt.Fprintln(out, "Errors:")
+		for _, err := range inspection.Errors {
+			_, _ = fmt.Fprintln(out, "    "+strings.ReplaceAll(err, "\n", "\n    "))
+		}
+	}
+}
@@ -9,17 +9,41 @@ https://github.com/MusicalNinjaDad/snaggle
 
 Usage:
 
-	snaggle [--in-place] FILE DESTINATION
-	snaggle [--copy | --in-place] [--recursive] DIRECTORY DESTINATION
+	snaggle [--in-place] [--sysroot SYSROOT] [--verify-symbols] [--isa LEVEL [--all-hwcaps]] [--dlopen PRIORITY [--scan-rodata]] [--ld-so-preload] [--scripts | --commands [--path PATH]] FILE DESTINATION
+	snaggle [--copy | --in-place] [--recursive] [--sysroot SYSROOT] [--verify-symbols] [--isa LEVEL [--all-hwcaps]] [--dlopen PRIORITY [--scan-rodata]] [--ld-so-preload] [--scripts | --commands [--path PATH]] DIRECTORY DESTINATION
+	snaggle --pid PID [--verify-symbols] [--isa LEVEL [--all-hwcaps]] [--dlopen PRIORITY [--scan-rodata]] [--ld-so-preload] DESTINATION
+	snaggle [command]
+
+Available Commands:
+
+	compat      Report the minimum glibc, kernel & x86-64 level needed to run DESTINATION
+	help        Help about any command
+	inspect     Print everything snaggle knows about FILE
+	tree        Print the dependency tree of each FILE
+	why         Explain why LIBRARY would be snagged
 
 Flags:
 
-	    --copy        Copy entire directory contents to /DESTINATION/full/source/path
-	-h, --help        help for snaggle
-	    --in-place    Snag in place: only snag dependencies & interpreter
-	-r, --recursive   Recurse subdirectories & snag everything
-	-v, --verbose     Output to stdout and process sequentially for readability
-	    --version     version for snaggle
+	    --all-hwcaps        Also snag every glibc-hwcaps variant of each library to DESTINATION/.../glibc-hwcaps
+	    --commands          As --scripts, also snag the commands run by shell scripts
+	    --copy              Copy entire directory contents to /DESTINATION/full/source/path
+	    --dlopen PRIORITY   Also snag libraries declared in .note.dlopen with at least PRIORITY: required, recommended or suggested
+	-h, --help              help for snaggle
+	    --in-place          Snag in place: only snag dependencies & interpreter
+	    --isa LEVEL         Snag glibc-hwcaps variants for x86-64 LEVEL (e.g. x86-64-v3), rather than the baseline
+	    --ld-so-preload     Also snag /etc/ld.so.preload, if anything it lists was snagged
+	    --lib32 DIR         Snag 32-bit libraries to DESTINATION/DIR (default "lib")
+	    --path PATH         Find commands run by scripts in PATH (default "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin")
+	    --pid PID           Snag the running process PID and everything it has loaded, to DESTINATION
+	-r, --recursive         Recurse subdirectories & snag everything
+	    --scan-rodata       With --dlopen, also snag libraries named in .rodata of anything without .note.dlopen
+	    --scripts           Also snag #! scripts and their interpreters
+	    --sysroot SYSROOT   Snag from the root filesystem at SYSROOT rather than the host
+	-v, --verbose           Output to stdout and process sequentially for readability
+	    --verify-symbols    Check every undefined symbol is exported by a snagged library, like ldd -r
+	    --version           version for snaggle
+
+Use "snaggle [command] --help" for more information about a command.
 
 In the form "snaggle FILE DESTINATION":
 
@@ -30,12 +54,75 @@ In the form "snaggle DIRECTORY DESTINATION":
 
 	All valid ELF binaries in DIRECTORY, and all their dependencies, will be snagged to DESTINATION.
 
+With --sysroot SYSROOT:
+
+	FILE/DIRECTORY, interpreters, dependencies, ld.so.cache, ld.so.conf and symlinks are all resolved
+	within SYSROOT, as though it were "/", without needing to chroot. FILE/DIRECTORY is relative to SYSROOT.
+	SYSROOT may be for a different architecture (e.g. aarch64, riscv64 or ppc64le) as nothing is executed.
+
+With --verify-symbols:
+
+	Every undefined symbol, including its GNU symbol version (e.g. GLIBC_2.34), must be exported by one of
+	the libraries which would be snagged, otherwise nothing is snagged.
+
+With --isa LEVEL and --all-hwcaps:
+
+	By default the baseline libraries are snagged, which run on any x86-64 CPU, even if ld.so on the host uses
+	a variant from a glibc-hwcaps subdirectory (e.g. glibc-hwcaps/x86-64-v3). --isa snags the variants which
+	ld.so would use on a CPU supporting LEVEL instead. --all-hwcaps snags the baseline and every variant, into
+	the same glibc-hwcaps layout, so that ld.so can choose at runtime.
+
+With --dlopen PRIORITY and --scan-rodata:
+
+	Libraries loaded at runtime via dlopen() are invisible to ldd. --dlopen also snags those declared in the
+	.note.dlopen of each binary or library (see https://uapi-group.org/specifications/specs/elf_dlopen_metadata/)
+	with at least PRIORITY, and anything they need. Nothing is snagged if a required one cannot be found.
+	--scan-rodata also guesses from any lib*.so* strings in anything without a .note.dlopen.
+
+With --ld-so-preload:
+
+	Libraries listed in /etc/ld.so.preload, filtees (DT_FILTER & DT_AUXILIARY) and audit libraries (DT_AUDIT &
+	DT_DEPAUDIT) are always snagged. --ld-so-preload also snags /etc/ld.so.preload to DESTINATION/etc, so that
+	they are preloaded when running from DESTINATION.
+
+With --pid PID:
+
+	The executable of the running process PID is snagged to DESTINATION/bin and every other file it has mapped
+	as executable (see /proc/PID/maps) to DESTINATION/lib64, each with its own dependencies. This includes
+	libraries loaded via dlopen(), which ldd cannot see. Nothing is snagged in-place, by copying or from a
+	sysroot. PID must share the mount namespace & root directory of snaggle, so cannot be in a container or
+	chroot.
+
+With --scripts:
+
+	Files starting with #! are snagged to DESTINATION/bin, alongside their interpreter at the exact path given
+	(e.g. DESTINATION/usr/bin/python3) and everything it needs. For #!/usr/bin/env COMMAND, COMMAND is also
+	found in the PATH and snagged to DESTINATION/bin. Without --scripts, files which are not ELFs are skipped
+	in DIRECTORY mode, unless copying.
+
+With --commands:
+
+	Shell scripts (sh, dash, ash, bash & mksh) are also parsed and every command they run by name, other than
+	builtins & functions, is found in PATH (see --path) and snagged to DESTINATION/bin. This is a best effort:
+	commands which cannot be found, or which are constructed at runtime (e.g. exec "$@"), are listed as a
+	warning and snaggle still succeeds.
+
 Snaggle will hardlink (or copy, see notes):
 - Executables              -> DESTINATION/bin
 - Dynamic libraries (*.so) -> DESTINATION/lib64
+- 32-bit libraries         -> DESTINATION/lib (see --lib32)
+- riscv64 libraries        -> DESTINATION/lib64/lp64d
+- musl (Alpine) libraries  -> DESTINATION/lib
+- Interpreters             -> DESTINATION/exact/path/requested (e.g. DESTINATION/lib/ld-musl-x86_64.so.1)
 
 Notes:
-  - Follows symlinks
+  - Follows symlinks, including any usr-merge symlinks already in DESTINATION (e.g. lib64 -> usr/lib64)
+  - An error is returned if two different files would be snagged to the same path, e.g. two executables
+    with the same name
+  - Libraries located via an RPATH or RUNPATH relative to $ORIGIN keep the same relative location, other
+    libraries located via an RPATH or RUNPATH keep their full path under DESTINATION
+  - Libraries requested by path (e.g. /opt/vendor/lib/libx.so or plugins/libplugin.so) are snagged to exactly
+    that path under DESTINATION, relative paths are relative to the snagged executable
   - Hardlinks will be created if possible.
   - A copy will be performed if hardlinking fails for one of the following reasons:
     FILE/DIRECTORY & DESTINATION are on different filesystems or
@@ -61,15 +148,19 @@ import (
 	"log"
 	"os"
 	"runtime/debug"
+	"strconv"
 	"strings"
 
 	"github.com/spf13/cobra"
 
 	"github.com/MusicalNinjaDad/snaggle"
+	"github.com/MusicalNinjaDad/snaggle/elf"
 )
 
 var options []snaggle.Option
 
+var pid int
+
 func addOption(option snaggle.Option) func(string) error {
 	return func(_ string) error {
 		options = append(options, option)
@@ -81,6 +172,7 @@ func init() {
 	log.Default().SetOutput(os.Stdout)
 
 	rootCmd.Version = snaggle.Version
+	rootCmd.CompletionOptions.DisableDefaultCmd = true
 
 	helpTemplate := []string{rootCmd.HelpTemplate(), helpNotes, exitCodes}
 	rootCmd.SetHelpTemplate(strings.Join(helpTemplate, "\n"))
@@ -88,11 +180,49 @@ func init() {
 	rootCmd.Flags().BoolFunc("copy", "Copy entire directory contents to /DESTINATION/full/source/path", addOption(snaggle.Copy()))
 	rootCmd.Flags().BoolFunc("in-place", "Snag in place: only snag dependencies & interpreter", addOption(snaggle.InPlace()))
 	rootCmd.Flags().BoolFuncP("recursive", "r", "Recurse subdirectories & snag everything", addOption(snaggle.Recursive()))
+	rootCmd.Flags().BoolFunc("verify-symbols", "Check every undefined symbol is exported by a snagged library, like ldd -r", addOption(snaggle.VerifySymbols()))
 	rootCmd.Flags().BoolFuncP("verbose", "v", "Output to stdout and process sequentially for readability", addOption(snaggle.Verbose()))
+	rootCmd.Flags().Func("lib32", "Snag 32-bit libraries to DESTINATION/`DIR` (default \"lib\")", func(dir string) error {
+		options = append(options, snaggle.Lib32(dir))
+		return nil
+	})
+	rootCmd.Flags().Func("sysroot", "Snag from the root filesystem at `SYSROOT` rather than the host", func(sysroot string) error {
+		options = append(options, snaggle.Sysroot(sysroot))
+		return nil
+	})
+	rootCmd.Flags().Func("isa", "Snag glibc-hwcaps variants for x86-64 `LEVEL` (e.g. x86-64-v3), rather than the baseline", func(level string) error {
+		isaLevel, err := parseISALevel(level)
+		options = append(options, snaggle.TargetISA(isaLevel))
+		return err
+	})
+	rootCmd.Flags().BoolFunc("all-hwcaps", "Also snag every glibc-hwcaps variant of each library to DESTINATION/.../glibc-hwcaps", addOption(snaggle.AllHWCaps()))
+	rootCmd.Flags().Func("dlopen", "Also snag libraries declared in .note.dlopen with at least `PRIORITY`: required, recommended or suggested", func(priority string) error {
+		dlopen, err := parseDlopenPriority(priority)
+		options = append(options, snaggle.Dlopen(dlopen))
+		return err
+	})
+	rootCmd.Flags().BoolFunc("ld-so-preload", "Also snag /etc/ld.so.preload, if anything it lists was snagged", addOption(snaggle.LdSoPreload()))
+	rootCmd.Flags().BoolFunc("scripts", "Also snag #! scripts and their interpreters", addOption(snaggle.Scripts()))
+	rootCmd.Flags().BoolFunc("commands", "As --scripts, also snag the commands run by shell scripts", addOption(snaggle.Commands()))
+	rootCmd.Flags().Func("path", "Find commands run by scripts in `PATH` (default \"/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin\")", func(path string) error {
+		options = append(options, snaggle.Path(path))
+		return nil
+	})
+	rootCmd.Flags().IntVar(&pid, "pid", 0, "Snag the running process `PID` and everything it has loaded, to DESTINATION")
+	rootCmd.Flags().BoolFunc("scan-rodata", "With --dlopen, also snag libraries named in .rodata of anything without .note.dlopen", addOption(snaggle.ScanRodata()))
+
+	rootCmd.AddCommand(treeCmd, whyCmd, inspectCmd, compatCmd)
 
 	// These are called somewhere in execute - which is not available to integration tests
 	rootCmd.InitDefaultHelpFlag()
 	rootCmd.InitDefaultVersionFlag()
+	rootCmd.InitDefaultHelpCmd()
+}
+
+// Gives cmd the default help template plus the exit codes: rootCmd's template would otherwise be inherited,
+// but its notes are about snagging
+func subcommandHelp(cmd *cobra.Command) {
+	cmd.SetHelpTemplate(strings.Join([]string{new(cobra.Command).HelpTemplate(), exitCodes}, "\n"))
 }
 
 // defer panicHandler to get meaningful output to stderr and control over the exitcode on panic
@@ -113,11 +243,12 @@ func main() {
 	defer panicHandler(3)
 
 	var snaggleError *snaggle.SnaggleError
+	var elfError *elf.ErrElf
 	err := rootCmd.Execute()
 	switch {
 	case err == nil:
 		os.Exit(0)
-	case errors.As(err, &snaggleError):
+	case errors.As(err, &snaggleError), errors.As(err, &elfError):
 		os.Exit(1)
 	default:
 		println(rootCmd.UsageString())
@@ -135,15 +266,95 @@ Snaggle is designed to help create minimal runtime containers from pre-existing
 It may work for other use cases and I'd be interested to hear about them at:
 https://github.com/MusicalNinjaDad/snaggle
 `,
-	Args: ExactArgs(2),
+	Args: func(cmd *cobra.Command, args []string) error {
+		if cmd.Flags().Changed("pid") {
+			return ExactArgs(1)(cmd, args)
+		}
+		return ExactArgs(2)(cmd, args)
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return snaggle.Snaggle(args[0], args[1], options...)
+		var err error
+		if cmd.Flags().Changed("pid") {
+			err = snaggle.FromProcess(pid, args[0], options...)
+		} else {
+			err = snaggle.Snaggle(args[0], args[1], options...)
+		}
+		var missing *elf.MissingDependencyError
+		var unresolved *elf.UnresolvedSymbolError
+		var commands *snaggle.UnresolvedCommandError
+		switch {
+		case errors.As(err, &missing):
+			cmd.SilenceErrors = true
+			cmd.PrintErr(missingSummary(missing))
+		case errors.As(err, &unresolved):
+			cmd.SilenceErrors = true
+			cmd.PrintErr(unresolvedSummary(unresolved))
+		case errors.As(err, &commands):
+			cmd.PrintErr(commandsSummary(commands))
+			return nil // everything else has been snagged
+		}
+		return err
 	},
 }
 
+// The x86-64 micro-architecture level from its name (e.g. `x86-64-v3`) or number (e.g. `3`)
+func parseISALevel(level string) (int, error) {
+	for isaLevel := 1; isaLevel <= 4; isaLevel++ {
+		if level == elf.ISALevelName(isaLevel) || level == strconv.Itoa(isaLevel) {
+			return isaLevel, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown x86-64 level %q, expected one of x86-64-baseline, x86-64-v2, x86-64-v3, x86-64-v4", level)
+}
+
+// A `.note.dlopen` priority from its name
+func parseDlopenPriority(priority string) (elf.Priority, error) {
+	for _, known := range []elf.Priority{elf.REQUIRED, elf.RECOMMENDED, elf.SUGGESTED} {
+		if priority == string(known) {
+			return known, nil
+		}
+	}
+	return "", fmt.Errorf("unknown priority %q, expected one of required, recommended, suggested", priority)
+}
+
+// A readable summary of every library which could not be found
+func missingSummary(missing *elf.MissingDependencyError) string {
+	var summary strings.Builder
+	summary.WriteString("Error: these libraries could not be found, nothing has been snagged:\n")
+	for _, lib := range missing.Missing {
+		summary.WriteString("  " + lib.Soname + " (needed by " + lib.NeededBy + ")\n")
+	}
+	return summary.String()
+}
+
+// A readable summary of every symbol which could not be resolved
+func unresolvedSummary(unresolved *elf.UnresolvedSymbolError) string {
+	var summary strings.Builder
+	summary.WriteString("Error: these symbols could not be resolved, nothing has been snagged:\n")
+	for _, sym := range unresolved.Unresolved {
+		summary.WriteString("  " + sym.String() + " (needed by " + sym.NeededBy + ")\n")
+	}
+	return summary.String()
+}
+
+// A readable summary of every command run by a script which could not be resolved
+func commandsSummary(unresolved *snaggle.UnresolvedCommandError) string {
+	var summary strings.Builder
+	summary.WriteString("Warning: these commands could not be resolved and have not been snagged:\n")
+	for _, command := range unresolved.Unresolved {
+		line := command.Script + ":" + strconv.FormatUint(uint64(command.Line), 10)
+		if command.Dynamic {
+			line += ", constructed at runtime"
+		}
+		summary.WriteString("  " + command.Name + " (" + line + ")\n")
+	}
+	return summary.String()
+}
+
 var usages = []string{
-	"snaggle [--in-place] FILE DESTINATION",
-	"snaggle [--copy | --in-place] [--recursive] DIRECTORY DESTINATION",
+	"snaggle [--in-place] [--sysroot SYSROOT] [--verify-symbols] [--isa LEVEL [--all-hwcaps]] [--dlopen PRIORITY [--scan-rodata]] [--ld-so-preload] [--scripts | --commands [--path PATH]] FILE DESTINATION",
+	"snaggle [--copy | --in-place] [--recursive] [--sysroot SYSROOT] [--verify-symbols] [--isa LEVEL [--all-hwcaps]] [--dlopen PRIORITY [--scan-rodata]] [--ld-so-preload] [--scripts | --commands [--path PATH]] DIRECTORY DESTINATION",
+	"snaggle --pid PID [--verify-symbols] [--isa LEVEL [--all-hwcaps]] [--dlopen PRIORITY [--scan-rodata]] [--ld-so-preload] DESTINATION",
 }
 
 var helpNotes = `
@@ -154,12 +365,67 @@ In the form "snaggle FILE DESTINATION":
 In the form "snaggle DIRECTORY DESTINATION":
   All valid ELF binaries in DIRECTORY, and all their dependencies, will be snagged to DESTINATION.
 
+With --sysroot SYSROOT:
+  FILE/DIRECTORY, interpreters, dependencies, ld.so.cache, ld.so.conf and symlinks are all resolved
+  within SYSROOT, as though it were "/", without needing to chroot. FILE/DIRECTORY is relative to SYSROOT.
+  SYSROOT may be for a different architecture (e.g. aarch64, riscv64 or ppc64le) as nothing is executed.
+
+With --verify-symbols:
+  Every undefined symbol, including its GNU symbol version (e.g. GLIBC_2.34), must be exported by one of
+  the libraries which would be snagged, otherwise nothing is snagged.
+
+With --isa LEVEL and --all-hwcaps:
+  By default the baseline libraries are snagged, which run on any x86-64 CPU, even if ld.so on the host uses
+  a variant from a glibc-hwcaps subdirectory (e.g. glibc-hwcaps/x86-64-v3). --isa snags the variants which
+  ld.so would use on a CPU supporting LEVEL instead. --all-hwcaps snags the baseline and every variant, into
+  the same glibc-hwcaps layout, so that ld.so can choose at runtime.
+
+With --dlopen PRIORITY and --scan-rodata:
+  Libraries loaded at runtime via dlopen() are invisible to ldd. --dlopen also snags those declared in the
+  .note.dlopen of each binary or library (see https://uapi-group.org/specifications/specs/elf_dlopen_metadata/)
+  with at least PRIORITY, and anything they need. Nothing is snagged if a required one cannot be found.
+  --scan-rodata also guesses from any lib*.so* strings in anything without a .note.dlopen.
+
+With --ld-so-preload:
+  Libraries listed in /etc/ld.so.preload, filtees (DT_FILTER & DT_AUXILIARY) and audit libraries (DT_AUDIT &
+  DT_DEPAUDIT) are always snagged. --ld-so-preload also snags /etc/ld.so.preload to DESTINATION/etc, so that
+  they are preloaded when running from DESTINATION.
+
+With --pid PID:
+  The executable of the running process PID is snagged to DESTINATION/bin and every other file it has mapped
+  as executable (see /proc/PID/maps) to DESTINATION/lib64, each with its own dependencies. This includes
+  libraries loaded via dlopen(), which ldd cannot see. Nothing is snagged in-place, by copying or from a
+  sysroot. PID must share the mount namespace & root directory of snaggle, so cannot be in a container or
+  chroot.
+
+With --scripts:
+  Files starting with #! are snagged to DESTINATION/bin, alongside their interpreter at the exact path given
+  (e.g. DESTINATION/usr/bin/python3) and everything it needs. For #!/usr/bin/env COMMAND, COMMAND is also
+  found in the PATH and snagged to DESTINATION/bin. Without --scripts, files which are not ELFs are skipped
+  in DIRECTORY mode, unless copying.
+
+With --commands:
+  Shell scripts (sh, dash, ash, bash & mksh) are also parsed and every command they run by name, other than
+  builtins & functions, is found in PATH (see --path) and snagged to DESTINATION/bin. This is a best effort:
+  commands which cannot be found, or which are constructed at runtime (e.g. exec "$@"), are listed as a
+  warning and snaggle still succeeds.
+
 Snaggle will hardlink (or copy, see notes):
 - Executables              -> DESTINATION/bin
 - Dynamic libraries (*.so) -> DESTINATION/lib64
+- 32-bit libraries         -> DESTINATION/lib (see --lib32)
+- riscv64 libraries        -> DESTINATION/lib64/lp64d
+- musl (Alpine) libraries  -> DESTINATION/lib
+- Interpreters             -> DESTINATION/exact/path/requested (e.g. DESTINATION/lib/ld-musl-x86_64.so.1)
 
 Notes:
-- Follows symlinks
+- Follows symlinks, including any usr-merge symlinks already in DESTINATION (e.g. lib64 -> usr/lib64)
+- An error is returned if two different files would be snagged to the same path, e.g. two executables
+  with the same name
+- Libraries located via an RPATH or RUNPATH relative to $ORIGIN keep the same relative location, other
+  libraries located via an RPATH or RUNPATH keep their full path under DESTINATION
+- Libraries requested by path (e.g. /opt/vendor/lib/libx.so or plugins/libplugin.so) are snagged to exactly
+  that path under DESTINATION, relative paths are relative to the snagged executable
 - Hardlinks will be created if possible.
 - A copy will be performed if hardlinking fails for one of the following reasons:
     FILE/DIRECTORY & DESTINATION are on different filesystems or
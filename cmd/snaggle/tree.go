@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/MusicalNinjaDad/snaggle/elf"
+)
+
+var treeFormat = "text"
+var treeOptions []elf.Option
+
+func init() {
+	treeCmd.Flags().Func("format", "Output as `FORMAT`: text, json or dot (default \"text\")", func(format string) error {
+		if !slices.Contains([]string{"text", "json", "dot"}, format) {
+			return fmt.Errorf("unknown format %q, expected text, json or dot", format)
+		}
+		treeFormat = format
+		return nil
+	})
+	treeCmd.Flags().Func("sysroot", "Resolve FILE(s) within the root filesystem at `SYSROOT` rather than the host", func(sysroot string) error {
+		treeOptions = append(treeOptions, elf.Sysroot(sysroot))
+		return nil
+	})
+	subcommandHelp(treeCmd)
+}
+
+var treeCmd = &cobra.Command{
+	Use:                   "tree [--format text|json|dot] [--sysroot SYSROOT] FILE...",
+	Short:                 "Print the dependency tree of each FILE",
+	SilenceUsage:          true,
+	DisableFlagsInUseLine: true,
+	Long: `Print the dependency tree of each FILE, in the order ld.so processes each DT_NEEDED entry.
+
+- text: indented, like ldd --tree; each library is expanded once, later occurrences are marked
+  [duplicate] and any which would loop back to an object already in the chain are marked [cycle]
+- json: the same tree, with "duplicate", "cycle" & "missing" set where relevant
+- dot:  a Graphviz digraph of every object, with each edge labelled by the soname requested
+
+Libraries which cannot be found are shown as "not found" (highlighted in red with --format dot).
+`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		graphs := make([]*elf.Graph, 0, len(args))
+		for _, path := range args {
+			graph, err := elf.NewGraph(path, treeOptions...)
+			var missing *elf.MissingDependencyError
+			if err != nil && !errors.As(err, &missing) {
+				return err
+			}
+			graphs = append(graphs, graph)
+		}
+
+		out := cmd.OutOrStdout()
+		switch treeFormat {
+		case "json":
+			trees := make([]*treeNode, 0, len(graphs))
+			for _, graph := range graphs {
+				trees = append(trees, newTree(graph))
+			}
+			encoder := json.NewEncoder(out)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(trees)
+		case "dot":
+			return writeDot(out, graphs)
+		default:
+			for _, graph := range graphs {
+				writeTree(out, newTree(graph), "")
+			}
+			return nil
+		}
+	},
+}
+
+// An object in a dependency tree, and everything which it needs
+type treeNode struct {
+	Soname      string      `json:"soname,omitempty"` // "" for the root
+	Path        string      `json:"path,omitempty"`
+	Interpreter string      `json:"interpreter,omitempty"` // only for the root
+	Missing     bool        `json:"missing,omitempty"`
+	Duplicate   bool        `json:"duplicate,omitempty"` // already expanded elsewhere in the tree
+	Cycle       bool        `json:"cycle,omitempty"`     // already in the chain which led here
+	Needs       []*treeNode `json:"needs,omitempty"`
+}
+
+// Builds the tree for graph, depth-first, expanding each object the first time it is reached
+func newTree(graph *elf.Graph) *treeNode {
+	expanded := make(map[string]bool)
+	var expand func(node *treeNode, chain []string)
+	expand = func(node *treeNode, chain []string) {
+		expanded[node.Path] = true
+		chain = append(chain, node.Path)
+		for _, edge := range graph.Needs(node.Path) {
+			child := &treeNode{Soname: edge.Soname, Path: edge.To}
+			switch {
+			case edge.To == "":
+				child.Missing = true
+			case slices.Contains(chain, edge.To):
+				child.Cycle = true
+			case expanded[edge.To]:
+				child.Duplicate = true
+			default:
+				expand(child, chain)
+			}
+			node.Needs = append(node.Needs, child)
+		}
+	}
+
+	root := &treeNode{Path: graph.Root.Path}
+	if graph.Interpreter != nil {
+		root.Interpreter = graph.Interpreter.Path
+	}
+	expand(root, nil)
+	return root
+}
+
+// Writes tree, in the same style as `ldd --tree`
+func writeTree(out io.Writer, tree *treeNode, indent string) {
+	var line string
+	switch {
+	case tree.Soname == "" && tree.Interpreter != "":
+		line = tree.Path + " (interpreter => " + tree.Interpreter + ")"
+	case tree.Soname == "":
+		line = tree.Path
+	case tree.Missing:
+		line = tree.Soname + " => not found"
+	default:
+		line = tree.Soname + " => " + tree.Path
+	}
+	switch {
+	case tree.Cycle:
+		line += " [cycle]"
+	case tree.Duplicate:
+		line += " [duplicate]"
+	}
+	_, _ = fmt.Fprintln(out, indent+line)
+	for _, child := range tree.Needs {
+		writeTree(out, child, indent+"    ")
+	}
+}
+
+// Writes all graphs as a single Graphviz digraph
+func writeDot(out io.Writer, graphs []*elf.Graph) error {
+	var dot strings.Builder
+	dot.WriteString("digraph snaggle {\n")
+	nodes := make(map[string]bool)
+	edges := make(map[string]bool)
+	node := func(id string, attrs string) {
+		if !nodes[id] {
+			nodes[id] = true
+			dot.WriteString("\t" + strconv.Quote(id) + " [" + attrs + "];\n")
+		}
+	}
+	for _, graph := range graphs {
+		for _, obj := range graph.LoadOrder() {
+			node(obj.Path, "label="+strconv.Quote(obj.Name))
+		}
+		for _, edge := range graph.Edges {
+			to := edge.To
+			if to == "" {
+				to = edge.Soname + " (not found)"
+				node(to, "label="+strconv.Quote(edge.Soname+"\nnot found")+", color=red, fontcolor=red, style=dashed")
+			}
+			line := "\t" + strconv.Quote(edge.From) + " -> " + strconv.Quote(to) + " [label=" + strconv.Quote(edge.Soname) + "];\n"
+			if !edges[line] { // graphs often share libraries
+				edges[line] = true
+				dot.WriteString(line)
+			}
+		}
+	}
+	dot.WriteString("}\n")
+	_, err := io.WriteString(out, dot.String())
+	return err
+}
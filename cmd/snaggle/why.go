@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/MusicalNinjaDad/snaggle"
+	"github.com/MusicalNinjaDad/snaggle/elf"
+)
+
+var whyOptions []snaggle.Option
+
+func init() {
+	whyCmd.Flags().Func("sysroot", "Resolve everything within the root filesystem at `SYSROOT` rather than the host", func(sysroot string) error {
+		whyOptions = append(whyOptions, snaggle.Sysroot(sysroot))
+		return nil
+	})
+	whyCmd.Flags().Func("isa", "Resolve glibc-hwcaps variants for x86-64 `LEVEL` (e.g. x86-64-v3), rather than the baseline", func(level string) error {
+		isaLevel, err := parseISALevel(level)
+		whyOptions = append(whyOptions, snaggle.TargetISA(isaLevel))
+		return err
+	})
+	whyCmd.Flags().Func("dlopen", "Include libraries declared in .note.dlopen with at least `PRIORITY`: required, recommended or suggested", func(priority string) error {
+		dlopen, err := parseDlopenPriority(priority)
+		whyOptions = append(whyOptions, snaggle.Dlopen(dlopen))
+		return err
+	})
+	subcommandHelp(whyCmd)
+}
+
+var whyCmd = &cobra.Command{
+	Use:                   "why [--sysroot SYSROOT] [--isa LEVEL] [--dlopen PRIORITY] LIBRARY FILE|DIRECTORY...",
+	Short:                 "Explain why LIBRARY would be snagged",
+	SilenceUsage:          true,
+	DisableFlagsInUseLine: true,
+	Long: `Explain why LIBRARY would be snagged for any FILE, or any binary in DIRECTORY (recursively).
+
+LIBRARY can be a soname (e.g. libLLVM.so.17), a filename or a full path.
+
+For each binary which needs LIBRARY, lists every chain of DT_NEEDED entries leading to it, followed by
+every location which was searched for it, in order: the first where it was found is used, any others
+where it was also found have lower precedence.
+
+To explain an existing DESTINATION: snaggle why --sysroot DESTINATION LIBRARY /
+`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		reasons, err := snaggle.Why(args[0], args[1:], whyOptions...)
+		if err != nil {
+			return err
+		}
+		if len(reasons) == 0 {
+			_, err := fmt.Fprintln(cmd.OutOrStdout(), args[0]+" is not needed")
+			return err
+		}
+		for _, reason := range reasons {
+			writeReason(cmd.OutOrStdout(), reason)
+		}
+		return nil
+	},
+}
+
+// Writes each chain in reason, followed by how the library was located
+func writeReason(out io.Writer, reason snaggle.Reason) {
+	for _, chain := range reason.Chains {
+		line := reason.Binary
+		for _, edge := range chain {
+			line += " -> " + edge.Soname
+		}
+		_, _ = fmt.Fprintln(out, line)
+	}
+
+	lib := reason.Library
+	switch {
+	case lib.Path == "":
+		_, _ = fmt.Fprintln(out, "    "+lib.Soname+" => not found")
+	case lib.Source == elf.UNRESOLVED:
+		_, _ = fmt.Fprintln(out, "    "+lib.Soname+" => "+lib.Path+" (the interpreter)")
+	case lib.Source == elf.PATHNAME:
+		_, _ = fmt.Fprintln(out, "    "+lib.Soname+" => "+lib.Path+" (pathname, not searched for)")
+	default:
+		_, _ = fmt.Fprintln(out, "    "+lib.Soname+" => "+lib.Path)
+	}
+
+	used := false
+	for _, candidate := range lib.Candidates {
+		where := candidate.Source.String()
+		if candidate.SearchDir != "" {
+			where += " " + candidate.SearchDir
+		}
+		if candidate.Owner != "" {
+			where += " of " + candidate.Owner
+		}
+
+		var result string
+		switch {
+		case candidate.Path == "":
+			result = "not listed"
+		case !candidate.Found:
+			result = candidate.Path + " not found"
+		case !used:
+			result = candidate.Path + " used"
+			used = true
+		default:
+			result = candidate.Path + " also found, lower precedence"
+		}
+		_, _ = fmt.Fprintln(out, "        "+where+": "+result)
+	}
+}
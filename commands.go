@@ -0,0 +1,252 @@
+package snaggle
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// A command run by a script which could not be snagged, see [Commands()]
+type Command struct {
+	// The command, as written in the script
+	Name string `json:"name"`
+	// Path to the script
+	Script string `json:"script"`
+	// The line in Script
+	Line uint `json:"line"`
+	// The command is constructed at runtime (e.g. `"$@"` or `$TOOL`), so cannot be resolved
+	Dynamic bool `json:"dynamic,omitempty"`
+}
+
+// Error returned if a command run by a script cannot be resolved, see [Commands()]
+var ErrUnresolvedCommand = errors.New("commands could not be resolved")
+
+// Every command, run by any script, which could not be resolved. Everything else has been snagged.
+type UnresolvedCommandError struct {
+	Unresolved []Command
+}
+
+func (e *UnresolvedCommandError) Error() string {
+	commands := make([]string, 0, len(e.Unresolved))
+	for _, command := range e.Unresolved {
+		commands = append(commands, command.Name+" ("+command.Script+":"+strconv.FormatUint(uint64(command.Line), 10)+")")
+	}
+	return ErrUnresolvedCommand.Error() + ": " + strings.Join(commands, ", ")
+}
+
+func (e *UnresolvedCommandError) Unwrap() error { return ErrUnresolvedCommand }
+
+// Shells whose scripts can be parsed for commands, and the language they speak
+var shells = map[string]syntax.LangVariant{
+	"sh":   syntax.LangPOSIX,
+	"ash":  syntax.LangPOSIX,
+	"dash": syntax.LangPOSIX,
+	"bash": syntax.LangBash,
+	"mksh": syntax.LangMirBSDKorn,
+}
+
+// Commands built into every shell we parse, which are never looked up in the PATH
+var builtins = []string{
+	// POSIX special built-ins
+	".", ":", "break", "continue", "eval", "exec", "exit", "export", "readonly", "return", "set", "shift", "times",
+	"trap", "unset",
+	// POSIX utilities which are always built in
+	"alias", "bg", "cd", "command", "false", "fc", "fg", "getopts", "hash", "jobs", "kill", "read", "true", "type",
+	"ulimit", "umask", "unalias", "wait",
+	// built into dash, busybox ash, bash & mksh
+	"[", "echo", "local", "printf", "pwd", "test",
+	// bash & mksh
+	"builtin", "caller", "compgen", "complete", "declare", "dirs", "disown", "enable", "help", "history", "let",
+	"logout", "mapfile", "popd", "print", "pushd", "readarray", "shopt", "source", "suspend", "typeset", "whence",
+}
+
+// The language of the shell which runs a script, ok is false if it is not a shell we can parse
+func (s shebang) shell() (language syntax.LangVariant, ok bool) {
+	name := filepath.Base(s.interpreter)
+	if name == "env" {
+		name = filepath.Base(s.envCommand())
+	}
+	language, ok = shells[name]
+	return language, ok
+}
+
+// Every command run by the script at path (within any sysroot), which is neither built in nor a function
+// declared in the script, at its first use. Dynamic commands are included at every use.
+//
+// Scripts which are not valid for language are parsed as bash, as `/bin/sh` is often bash.
+func scriptCommands(path string, language syntax.LangVariant, options options) ([]Command, error) {
+	script, err := os.ReadFile(options.host(path))
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := syntax.NewParser(syntax.Variant(language)).Parse(strings.NewReader(string(script)), path)
+	if err != nil && language != syntax.LangBash {
+		parsed, err = syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(string(script)), path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var functions []string
+	syntax.Walk(parsed, func(node syntax.Node) bool {
+		if function, ok := node.(*syntax.FuncDecl); ok {
+			functions = append(functions, function.Name.Value)
+		}
+		return true
+	})
+
+	var commands []Command
+	syntax.Walk(parsed, func(node syntax.Node) bool {
+		call, ok := node.(*syntax.CallExpr)
+		if !ok {
+			return true
+		}
+		for _, word := range commandWords(call.Args) {
+			name, literal := wordLiteral(word)
+			switch {
+			case !literal:
+				commands = append(commands, Command{Name: printWord(word), Script: path, Line: word.Pos().Line(), Dynamic: true})
+			case slices.Contains(builtins, name), slices.Contains(functions, name):
+				continue
+			case !slices.ContainsFunc(commands, func(command Command) bool { return command.Name == name }):
+				commands = append(commands, Command{Name: name, Script: path, Line: word.Pos().Line()})
+			}
+		}
+		return true // command substitutions within the arguments
+	})
+	return commands, nil
+}
+
+// The words naming commands run by a simple command with args: the first, and the command run via any `exec`,
+// `command` or `env`. None for `command -v` & `command -V`, which only look the command up.
+func commandWords(args []*syntax.Word) []*syntax.Word {
+	if len(args) == 0 {
+		return nil // only assignments
+	}
+	name, _ := wordLiteral(args[0])
+	rest := make([]string, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		literal, _ := wordLiteral(arg)
+		rest = append(rest, literal)
+	}
+
+	var skip int // arguments before the command being run
+	switch name {
+	case "exec":
+		for skip < len(rest) && strings.HasPrefix(rest[skip], "-") {
+			if rest[skip] == "-a" {
+				skip++ // followed by a value
+			}
+			skip++
+		}
+	case "command":
+		for skip < len(rest) && strings.HasPrefix(rest[skip], "-") {
+			if rest[skip] == "-v" || rest[skip] == "-V" {
+				return nil
+			}
+			skip++
+		}
+	case "env":
+		skip = envCommandIndex(rest)
+	default:
+		return args[:1]
+	}
+
+	words := []*syntax.Word{args[0]}
+	if name == "exec" || name == "command" {
+		words = nil // built in
+	}
+	if skip < len(rest) {
+		words = append(words, commandWords(args[1+skip:])...)
+	}
+	return words
+}
+
+// The value of word, if it contains no expansions. Quotes are removed.
+func wordLiteral(word *syntax.Word) (string, bool) {
+	var value strings.Builder
+	for _, part := range word.Parts {
+		switch part := part.(type) {
+		case *syntax.Lit:
+			value.WriteString(part.Value)
+		case *syntax.SglQuoted:
+			if part.Dollar {
+				return "", false // $'...' escapes
+			}
+			value.WriteString(part.Value)
+		case *syntax.DblQuoted:
+			for _, quoted := range part.Parts {
+				lit, ok := quoted.(*syntax.Lit)
+				if !ok {
+					return "", false
+				}
+				value.WriteString(lit.Value)
+			}
+		default:
+			return "", false
+		}
+	}
+	return value.String(), true
+}
+
+// word as written in the script
+func printWord(word *syntax.Word) string {
+	var printed strings.Builder
+	if err := syntax.NewPrinter().Print(&printed, word); err != nil {
+		return "?"
+	}
+	return printed.String()
+}
+
+// Plans to snag every command run by the script at path, which is run by a shell we can parse, to root/bin; or
+// to the exact path given if it contains a `/`. Commands which are themselves scripts have their interpreter
+// snagged, but are not parsed for further commands.
+//
+// Commands which cannot be found in the PATH, or which are dynamic, are returned without error.
+func planCommands(path string, language syntax.LangVariant, root string, options options, checker chan<- skipCheck) (plan, []Command, error) {
+	commands, err := scriptCommands(path, language, options)
+	if err != nil {
+		return nil, nil, &SnaggleError{Src: path, Dst: root, err: err}
+	}
+
+	inBin := options
+	inBin.copy, inBin.inplace = false, false
+	exact := options
+	exact.copy, exact.inplace = true, false // copied files keep their full path under root
+
+	var planned plan
+	var unresolved []Command
+	for _, command := range commands {
+		var snags plan
+		var err error
+		switch {
+		case command.Dynamic:
+			unresolved = append(unresolved, command)
+		case filepath.IsAbs(command.Name):
+			if _, statErr := os.Stat(options.host(command.Name)); statErr != nil {
+				unresolved = append(unresolved, command)
+				continue
+			}
+			snags, err = planCommand(path, command.Name, root, exact, checker, 0)
+		case strings.Contains(command.Name, "/"):
+			unresolved = append(unresolved, command) // relative to the working directory at runtime
+		default:
+			found, ok := lookPath(command.Name, options)
+			if !ok {
+				unresolved = append(unresolved, command)
+				continue
+			}
+			snags, err = planCommand(path, found, root, inBin, checker, 0)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		planned = append(planned, snags...)
+	}
+	return planned, unresolved, nil
+}
@@ -0,0 +1,134 @@
+package snaggle
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/MusicalNinjaDad/snaggle/elf"
+)
+
+// The runtime requirements of everything in a root created by [Snaggle], see [Compat]
+type CompatReport struct {
+	// The highest glibc symbol version needed by anything in root, e.g. `GLIBC_2.34`; "" if none
+	GlibcNeeded string `json:"glibcNeeded,omitempty"`
+	// The highest minimum Linux kernel version from any `.note.ABI-tag`, e.g. `3.2.0`; "" if none
+	MinKernel string `json:"minKernel,omitempty"`
+	// The highest x86-64 micro-architecture level needed by anything in root (see [elf.ISALevelName]), 0 if none
+	ISALevel int `json:"isaLevel,omitempty"`
+	// Every ELF in root, in the order found
+	Binaries []Compatibility `json:"binaries"`
+}
+
+// The runtime requirements of a single ELF in a root created by [Snaggle]
+type Compatibility struct {
+	// Path to the ELF, within root
+	Path string `json:"path"`
+	// As [elf.Elf.GlibcNeeded]
+	GlibcNeeded string `json:"glibcNeeded,omitempty"`
+	// As [elf.Elf.MinKernel]
+	MinKernel string `json:"minKernel,omitempty"`
+	// As [elf.Elf.ISALevel]
+	ISALevel int `json:"isaLevel,omitempty"`
+	// The highest glibc symbol version provided by the `libc.so.6` bundled in root, for the same class and
+	// machine; "" if none is bundled
+	BundledGlibc string `json:"bundledGlibc,omitempty"`
+	// Does this need a newer glibc than the bundled `libc.so.6` provides?
+	Incompatible bool `json:"incompatible"`
+}
+
+// Error returned by [Compat] if anything needs a newer glibc than is bundled
+var ErrIncompatible = errors.New("needs a newer glibc than is bundled")
+
+// Compat reports the minimum glibc, kernel & x86-64 micro-architecture level needed to run everything in root,
+// which was created by [Snaggle]. Every file in root is checked, recursively, non-ELFs are ignored.
+//
+// Anything which needs a newer glibc than the bundled `libc.so.6` provides is flagged as Incompatible and the
+// report is returned alongside an error wrapping [ErrIncompatible].
+func Compat(root string) (CompatReport, error) {
+	var report CompatReport
+	sysroot, err := filepath.Abs(root)
+	if err != nil {
+		return report, &InvocationError{Path: root, err: err}
+	}
+	options := options{sysroot: sysroot, recursive: true}
+
+	paths, err := listDir("/", options)
+	if err != nil {
+		return report, &SnaggleError{Src: root, err: err}
+	}
+
+	elves := make([]*elf.Elf, len(paths))
+	parsers := new(errgroup.Group)
+	for idx, path := range paths {
+		parsers.Go(func() error {
+			parsed, err := elf.Headers(path, elf.Sysroot(sysroot))
+			switch {
+			case errors.Is(err, elf.ErrInvalidElf):
+				return nil // not an ELF, so nothing is needed
+			case err != nil:
+				return &SnaggleError{Src: path, err: err}
+			}
+			elves[idx] = &parsed
+			return nil
+		})
+	}
+	if err := parsers.Wait(); err != nil {
+		return report, err
+	}
+
+	// symlinks (e.g. libfoo.so.1 -> libfoo.so.1.2) are only reported once
+	seen := make(map[string]bool)
+	var libcs []*elf.Elf
+	for idx, parsed := range elves {
+		if parsed == nil || seen[parsed.Path] {
+			elves[idx] = nil
+			continue
+		}
+		seen[parsed.Path] = true
+		if parsed.Soname == "libc.so.6" {
+			libcs = append(libcs, parsed)
+		}
+	}
+
+	var incompatible []string
+	for idx, parsed := range elves {
+		if parsed == nil {
+			continue
+		}
+		compat := Compatibility{
+			Path:        paths[idx],
+			GlibcNeeded: parsed.GlibcNeeded,
+			MinKernel:   parsed.MinKernel,
+			ISALevel:    parsed.ISALevel,
+		}
+		for _, libc := range libcs {
+			if libc.Class == parsed.Class && libc.Machine == parsed.Machine {
+				compat.BundledGlibc = libc.GlibcProvided
+				compat.Incompatible = elf.CompareGlibc(compat.GlibcNeeded, compat.BundledGlibc) > 0
+				break
+			}
+		}
+		if compat.Incompatible {
+			incompatible = append(incompatible, compat.Path+" needs "+compat.GlibcNeeded+", bundled libc.so.6 provides "+compat.BundledGlibc)
+		}
+
+		if elf.CompareGlibc(compat.GlibcNeeded, report.GlibcNeeded) > 0 {
+			report.GlibcNeeded = compat.GlibcNeeded
+		}
+		if elf.CompareKernel(compat.MinKernel, report.MinKernel) > 0 {
+			report.MinKernel = compat.MinKernel
+		}
+		report.ISALevel = max(report.ISALevel, compat.ISALevel)
+		report.Binaries = append(report.Binaries, compat)
+	}
+
+	if len(incompatible) > 0 {
+		err := fmt.Errorf("%w: %s", ErrIncompatible, strings.Join(incompatible, "; "))
+		return report, &SnaggleError{Src: root, err: err}
+	}
+	return report, nil
+}
@@ -0,0 +1,91 @@
+package elf
+
+import (
+	debug_elf "debug/elf"
+	"encoding/binary"
+	"runtime"
+)
+
+// Per-architecture details of glibc, so that binaries for architectures which cannot run on the host
+// can still be resolved.
+type arch struct {
+	interpreter string // path to the glibc interpreter
+	slibdir     string // directory, relative to `/` or `/usr`, which upstream glibc uses for libraries
+	multiarch   string // Debian-style multiarch triplet
+	platform    string // value of `AT_PLATFORM` provided by the kernel, and therefore `$PLATFORM`
+}
+
+// The glibc details for elffile's class, machine & byte order, defaulting to x86_64.
+func archOf(elffile *debug_elf.File) arch {
+	switch elffile.Machine {
+	case debug_elf.EM_386:
+		return arch{"/lib/ld-linux.so.2", "lib", "i386-linux-gnu", "i686"}
+	case debug_elf.EM_AARCH64:
+		if elffile.ByteOrder == binary.BigEndian {
+			return arch{"/lib/ld-linux-aarch64_be.so.1", "lib64", "aarch64_be-linux-gnu", "aarch64_be"}
+		}
+		return arch{"/lib/ld-linux-aarch64.so.1", "lib64", "aarch64-linux-gnu", "aarch64"}
+	case debug_elf.EM_RISCV:
+		// lp64d, as used by all major distributions
+		return arch{"/lib/ld-linux-riscv64-lp64d.so.1", "lib64/lp64d", "riscv64-linux-gnu", "riscv64"}
+	case debug_elf.EM_PPC64:
+		// the kernel provides the CPU generation (e.g. `power9`) as AT_PLATFORM, which cannot be known in advance
+		if elffile.ByteOrder == binary.BigEndian {
+			return arch{"/lib64/ld64.so.1", "lib64", "powerpc64-linux-gnu", "ppc64"}
+		}
+		return arch{"/lib64/ld64.so.2", "lib64", "powerpc64le-linux-gnu", "ppc64le"}
+	case debug_elf.EM_S390:
+		return arch{"/lib/ld64.so.1", "lib64", "s390x-linux-gnu", "s390x"}
+	default:
+		return arch{"/lib64/ld-linux-x86-64.so.2", "lib64", "x86_64-linux-gnu", "x86_64"}
+	}
+}
+
+// Default system directories for elffile.
+//
+//   - Upstream glibc uses /lib64 & /usr/lib64 for 64-bit and /lib & /usr/lib for 32-bit libraries
+//     (/lib64/lp64d & /usr/lib64/lp64d for riscv64)
+//   - Debian-based distributions use multiarch directories, and /lib32 & /usr/lib32 for 32-bit libraries
+//     on 64-bit systems
+func defaultDirs(elffile *debug_elf.File) []string {
+	arch := archOf(elffile)
+	if elffile.Class == debug_elf.ELFCLASS32 {
+		return []string{
+			"/lib/" + arch.multiarch,
+			"/usr/lib/" + arch.multiarch,
+			"/lib32",
+			"/usr/lib32",
+			"/lib",
+			"/usr/lib",
+		}
+	}
+	return []string{
+		"/" + arch.slibdir,
+		"/usr/" + arch.slibdir,
+		"/lib/" + arch.multiarch,
+		"/usr/lib/" + arch.multiarch,
+		"/lib",
+		"/usr/lib",
+	}
+}
+
+// Machines whose binaries the host can execute, by GOARCH
+var hostMachines = map[string][]debug_elf.Machine{
+	"amd64":   {debug_elf.EM_X86_64, debug_elf.EM_386},
+	"386":     {debug_elf.EM_386},
+	"arm64":   {debug_elf.EM_AARCH64},
+	"riscv64": {debug_elf.EM_RISCV},
+	"ppc64le": {debug_elf.EM_PPC64},
+	"ppc64":   {debug_elf.EM_PPC64},
+	"s390x":   {debug_elf.EM_S390},
+}
+
+// Can the host execute binaries for machine?
+func isNative(machine debug_elf.Machine) bool {
+	for _, native := range hostMachines[runtime.GOARCH] {
+		if machine == native {
+			return true
+		}
+	}
+	return false
+}
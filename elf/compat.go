@@ -0,0 +1,267 @@
+package elf
+
+import (
+	"cmp"
+	debug_elf "debug/elf"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	glibcPrefix = "GLIBC_"
+
+	nt_GNU_ABI_TAG                = 1          // `.note.ABI-tag`
+	nt_GNU_BUILD_ID               = 3          // `.note.gnu.build-id`
+	nt_GNU_PROPERTY_TYPE_0        = 5          // `.note.gnu.property`
+	gnu_PROPERTY_X86_ISA_1_NEEDED = 0xc0008002 // GNU_PROPERTY_X86_UINT32_OR_LO + 2
+)
+
+// The highest `GLIBC_*` version in versions, "" if there are none. GLIBC_PRIVATE is ignored.
+func highestGlibc(versions []string) string {
+	var highest string
+	for _, version := range versions {
+		if _, ok := glibcVersion(version); ok && CompareGlibc(version, highest) > 0 {
+			highest = version
+		}
+	}
+	return highest
+}
+
+// The numeric parts of version, e.g. [2 34] for `GLIBC_2.34`. ok is false unless version is `GLIBC_` followed
+// by dot-separated numbers.
+func glibcVersion(version string) (parts []int, ok bool) {
+	numbers, found := strings.CutPrefix(version, glibcPrefix)
+	if !found {
+		return nil, false
+	}
+	return versionNumbers(numbers)
+}
+
+// The numeric parts of a dot-separated version, e.g. [3 2 0] for `3.2.0`. ok is false if any part is not a number.
+func versionNumbers(version string) (parts []int, ok bool) {
+	for number := range strings.SplitSeq(version, ".") {
+		part, err := strconv.Atoi(number)
+		if err != nil {
+			return nil, false
+		}
+		parts = append(parts, part)
+	}
+	return parts, true
+}
+
+// Compares two glibc symbol versions (e.g. `GLIBC_2.34` and `GLIBC_2.2.5`) numerically, returning -1, 0 or +1.
+// "", or anything which is not a numbered `GLIBC_` version, is lower than any numbered version.
+func CompareGlibc(a string, b string) int {
+	aParts, aOK := glibcVersion(a)
+	bParts, bOK := glibcVersion(b)
+	return compareVersions(aParts, aOK, bParts, bOK)
+}
+
+// Compares two kernel versions (e.g. `3.2.0` and `4.4.0`), as found in [Elf.MinKernel], numerically returning
+// -1, 0 or +1. "" is lower than any version.
+func CompareKernel(a string, b string) int {
+	aParts, aOK := versionNumbers(a)
+	bParts, bOK := versionNumbers(b)
+	return compareVersions(aParts, aOK, bParts, bOK)
+}
+
+// Compares the numeric parts of two versions, missing parts are 0. Invalid versions (!ok) are lowest.
+func compareVersions(aParts []int, aOK bool, bParts []int, bOK bool) int {
+	switch {
+	case !aOK && !bOK:
+		return 0
+	case !aOK:
+		return -1
+	case !bOK:
+		return 1
+	}
+	for idx := range max(len(aParts), len(bParts)) {
+		var aPart, bPart int
+		if idx < len(aParts) {
+			aPart = aParts[idx]
+		}
+		if idx < len(bParts) {
+			bPart = bParts[idx]
+		}
+		if aPart != bPart {
+			return cmp.Compare(aPart, bPart)
+		}
+	}
+	return 0
+}
+
+// The highest `GLIBC_*` version needed (`.gnu.version_r`) from any library, "" if none
+func glibcNeeded(elffile *debug_elf.File) (string, error) {
+	if elffile.SectionByType(debug_elf.SHT_GNU_VERSYM) == nil {
+		return "", nil // no version information, e.g. a static or musl binary
+	}
+	needs, err := elffile.DynamicVersionNeeds()
+	if err != nil {
+		return "", fmt.Errorf("%w: reading .gnu.version_r: %w", ErrInvalidElf, err)
+	}
+	var versions []string
+	for _, need := range needs {
+		for _, dep := range need.Needs {
+			versions = append(versions, dep.Dep)
+		}
+	}
+	return highestGlibc(versions), nil
+}
+
+// The highest `GLIBC_*` version defined (`.gnu.version_d`), "" unless elffile is part of glibc
+func glibcProvided(elffile *debug_elf.File) (string, error) {
+	if elffile.SectionByType(debug_elf.SHT_GNU_VERSYM) == nil {
+		return "", nil // no version information
+	}
+	definitions, err := elffile.DynamicVersions()
+	if err != nil {
+		return "", fmt.Errorf("%w: reading .gnu.version_d: %w", ErrInvalidElf, err)
+	}
+	var versions []string
+	for _, definition := range definitions {
+		versions = append(versions, definition.Name)
+	}
+	return highestGlibc(versions), nil
+}
+
+// A single note: the name excludes the trailing NUL
+type note struct {
+	name string
+	kind uint32
+	desc []byte
+}
+
+// Every note in the section called name, nil if there is no such section
+func notes(elffile *debug_elf.File, name string) ([]note, error) {
+	section := elffile.Section(name)
+	if section == nil {
+		return nil, nil
+	}
+	data, err := section.Data()
+	if err != nil {
+		return nil, fmt.Errorf("%w: reading %s: %w", ErrInvalidElf, name, err)
+	}
+	align := uint64(4)
+	if section.Addralign == 8 {
+		align = 8 // e.g. `.note.gnu.property` on 64-bit
+	}
+	pad := func(offset uint64) uint64 { return (offset + align - 1) &^ (align - 1) }
+
+	var notes []note
+	for offset := uint64(0); offset < uint64(len(data)); {
+		// namesz, descsz & type; followed by the name and the description, each starting aligned
+		if offset+12 > uint64(len(data)) {
+			return notes, fmt.Errorf("%w: malformed %s", ErrInvalidElf, name)
+		}
+		namesz := uint64(elffile.ByteOrder.Uint32(data[offset:]))
+		descsz := uint64(elffile.ByteOrder.Uint32(data[offset+4:]))
+		kind := elffile.ByteOrder.Uint32(data[offset+8:])
+		nameStart := offset + 12
+		descStart := pad(nameStart + namesz)
+		if descStart+descsz > uint64(len(data)) {
+			return notes, fmt.Errorf("%w: malformed %s", ErrInvalidElf, name)
+		}
+		notes = append(notes, note{
+			name: strings.TrimRight(string(data[nameStart:nameStart+namesz]), "\x00"),
+			kind: kind,
+			desc: data[descStart : descStart+descsz],
+		})
+		offset = pad(descStart + descsz)
+	}
+	return notes, nil
+}
+
+// The minimum Linux kernel version from `.note.ABI-tag`, e.g. `3.2.0`; "" if there is none or it is for
+// a different OS
+func minKernel(elffile *debug_elf.File) (string, error) {
+	abitags, err := notes(elffile, ".note.ABI-tag")
+	if err != nil {
+		return "", err
+	}
+	for _, tag := range abitags {
+		if tag.name != "GNU" || tag.kind != nt_GNU_ABI_TAG {
+			continue
+		}
+		// os, major, minor, subminor
+		if len(tag.desc) < 16 {
+			return "", fmt.Errorf("%w: malformed .note.ABI-tag", ErrInvalidElf)
+		}
+		word := func(idx int) uint32 { return elffile.ByteOrder.Uint32(tag.desc[idx*4:]) }
+		if word(0) != 0 { // ELF_NOTE_OS_LINUX
+			return "", nil
+		}
+		return fmt.Sprintf("%d.%d.%d", word(1), word(2), word(3)), nil
+	}
+	return "", nil
+}
+
+// The x86-64 micro-architecture level (1 = x86-64-baseline to 4 = x86-64-v4) from
+// `GNU_PROPERTY_X86_ISA_1_NEEDED`, 0 if not recorded or not x86
+func isaLevel(elffile *debug_elf.File) (int, error) {
+	if elffile.Machine != debug_elf.EM_X86_64 && elffile.Machine != debug_elf.EM_386 {
+		return 0, nil
+	}
+	properties, err := notes(elffile, ".note.gnu.property")
+	if err != nil {
+		return 0, err
+	}
+	align := 4
+	if elffile.Class == debug_elf.ELFCLASS64 {
+		align = 8
+	}
+	for _, property := range properties {
+		if property.name != "GNU" || property.kind != nt_GNU_PROPERTY_TYPE_0 {
+			continue
+		}
+		// pr_type & pr_datasz, followed by the data, aligned
+		for desc := property.desc; len(desc) >= 8; {
+			prType := elffile.ByteOrder.Uint32(desc)
+			prDatasz := int(elffile.ByteOrder.Uint32(desc[4:]))
+			if 8+prDatasz > len(desc) {
+				return 0, fmt.Errorf("%w: malformed .note.gnu.property", ErrInvalidElf)
+			}
+			if prType == gnu_PROPERTY_X86_ISA_1_NEEDED && prDatasz >= 4 {
+				return levelFromISABits(elffile.ByteOrder.Uint32(desc[8:])), nil
+			}
+			desc = desc[min(len(desc), 8+(prDatasz+align-1)&^(align-1)):]
+		}
+	}
+	return 0, nil
+}
+
+// The highest level set in GNU_PROPERTY_X86_ISA_1_BASELINE (bit 0) ... GNU_PROPERTY_X86_ISA_1_V4 (bit 3)
+func levelFromISABits(bits uint32) int {
+	level := 0
+	for bit := range 4 {
+		if bits&(1<<bit) != 0 {
+			level = bit + 1
+		}
+	}
+	return level
+}
+
+// The name used by gcc's `-march` for an x86-64 micro-architecture level, e.g. `x86-64-v3`; "" for level 0
+func ISALevelName(level int) string {
+	switch level {
+	case 0:
+		return ""
+	case 1:
+		return "x86-64-baseline"
+	default:
+		return fmt.Sprintf("x86-64-v%d", level)
+	}
+}
+
+// The `glibc-hwcaps` subdirectories which `ld.so` searches on a CPU supporting the x86-64 micro-architecture level,
+// in order of preference; none for other architectures
+func hwcaps(elffile *debug_elf.File, level int) []string {
+	if elffile.Machine != debug_elf.EM_X86_64 || elffile.Class != debug_elf.ELFCLASS64 {
+		return nil
+	}
+	var subdirs []string
+	for l := min(level, 4); l >= 2; l-- {
+		subdirs = append(subdirs, ISALevelName(l))
+	}
+	return subdirs
+}
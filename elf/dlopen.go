@@ -0,0 +1,121 @@
+package elf
+
+import (
+	"bytes"
+	debug_elf "debug/elf"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+)
+
+// A library which may be loaded at runtime via `dlopen()`, as declared in `.note.dlopen`. These are
+// invisible to `ldd`.
+//
+// See https://uapi-group.org/specifications/specs/elf_dlopen_metadata/
+type DlopenDependency struct {
+	// Alternative sonames, in order of preference: only the first which can be found is loaded
+	Sonames []string `json:"soname"`
+	// The feature which needs the library, e.g. `zstd`
+	Feature string `json:"feature,omitempty"`
+	// A human-readable description of Feature
+	Description string `json:"description,omitempty"`
+	// How important Feature is, see [Priority]
+	Priority Priority `json:"priority,omitempty"`
+	// Found by scanning `.rodata` for `lib*.so*` strings, rather than declared, see [ScanRodata]. Has no Priority.
+	Guessed bool `json:"guessed,omitempty"`
+}
+
+// How important a [DlopenDependency] is
+type Priority string
+
+// The priorities defined for `.note.dlopen`, from most to least important
+const (
+	// The feature is core functionality, the library should always be available
+	REQUIRED Priority = "required"
+	// The feature is important, the library should usually be available. The default if none is declared.
+	RECOMMENDED Priority = "recommended"
+	// The feature is optional
+	SUGGESTED Priority = "suggested"
+)
+
+// The owner & type of a `.note.dlopen` note
+const (
+	dlopenOwner            = "FDO"
+	nt_FDO_DLOPEN_METADATA = 0x407c0c0a
+)
+
+// Is p at least as important as threshold? Unknown priorities are treated as SUGGESTED.
+func (p Priority) atLeast(threshold Priority) bool {
+	rank := func(priority Priority) int {
+		switch priority {
+		case REQUIRED:
+			return 0
+		case RECOMMENDED:
+			return 1
+		}
+		return 2
+	}
+	return rank(p) <= rank(threshold)
+}
+
+// Every library declared in `.note.dlopen`, nil if there are none.
+//
+// If scan and nothing is declared, `.rodata` is scanned for strings which look like sonames (e.g. `libfoo.so.1`),
+// excluding any which are already `DT_NEEDED`; these are returned as Guessed.
+func dlopenDependencies(elffile *debug_elf.File, scan bool) ([]DlopenDependency, error) {
+	dlopenNotes, err := notes(elffile, ".note.dlopen")
+	if err != nil {
+		return nil, err
+	}
+	var dependencies []DlopenDependency
+	for _, note := range dlopenNotes {
+		if note.name != dlopenOwner || note.kind != nt_FDO_DLOPEN_METADATA {
+			continue
+		}
+		var declared []DlopenDependency
+		if err := json.Unmarshal(bytes.TrimRight(note.desc, "\x00"), &declared); err != nil {
+			return dependencies, fmt.Errorf("%w: malformed .note.dlopen: %w", ErrInvalidElf, err)
+		}
+		for _, dependency := range declared {
+			if dependency.Priority == "" {
+				dependency.Priority = RECOMMENDED
+			}
+			dependencies = append(dependencies, dependency)
+		}
+	}
+	if len(dependencies) > 0 || !scan {
+		return dependencies, nil
+	}
+	return scanRodata(elffile)
+}
+
+// Something which looks like a soname, e.g. `libfoo.so`, `libfoo-1.2.so.3` or `libc++.so.1`
+var sonameLike = regexp.MustCompile(`^lib[[:alnum:]_+-][[:alnum:]_.+-]*\.so(\.[[:digit:]]+)*$`)
+
+// Every string in `.rodata` which looks like a soname, other than any `DT_NEEDED` or the object's own
+// `DT_SONAME`, each as a Guessed [DlopenDependency]
+func scanRodata(elffile *debug_elf.File) ([]DlopenDependency, error) {
+	section := elffile.Section(".rodata")
+	if section == nil || section.Type == debug_elf.SHT_NOBITS {
+		return nil, nil
+	}
+	data, err := section.Data()
+	if err != nil {
+		return nil, fmt.Errorf("%w: reading .rodata: %w", ErrInvalidElf, err)
+	}
+	exclude, _ := elffile.DynString(debug_elf.DT_NEEDED) // errors are reported when resolving
+	soname, _ := elffile.DynString(debug_elf.DT_SONAME)
+	exclude = append(exclude, soname...)
+
+	var guessed []DlopenDependency
+	for str := range bytes.SplitSeq(data, []byte{0}) {
+		candidate := string(str)
+		if !sonameLike.MatchString(candidate) || slices.Contains(exclude, candidate) {
+			continue
+		}
+		exclude = append(exclude, candidate) // only once
+		guessed = append(guessed, DlopenDependency{Sonames: []string{candidate}, Guessed: true})
+	}
+	return guessed, nil
+}
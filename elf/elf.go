@@ -10,27 +10,47 @@
 //
 //	bin, err := elf.New(path)
 //
+// or, to parse a file which is not on the host, with [elf.NewFromFS] or [elf.NewFromReader].
+//
+// Dependencies are located natively, following the same search order as `ld.so`, without executing
+// anything. Provide the Option [CrossCheck()] to additionally call the interpreter (like `ldd`) and
+// validate that both agree.
+//
 //	bin is an Elf with the following structure:
 //	{
 //		Name: base filename
 //		Path: absolute path
 //		Class: 32-bit or 64-bit?
+//		Machine: architecture
+//		OSABI: target operating system ABI
 //		Type: EXE, BIN, PIE, ...
+//		Entry: entry point address
 //		Interpreter: path to requested interpeter
-//		Dependencies: slice of paths to dependencies as identified and found by the interpreter
+//		Libc: glibc or musl?
+//		Soname: DT_SONAME
+//		Needed: DT_NEEDED entries, as listed
+//		Rpath & Runpath: DT_RPATH & DT_RUNPATH, as listed
+//		BuildID: from .note.gnu.build-id
+//		Stripped: no symbol table?
+//		Dependencies: slice of paths to dependencies, located in the same way as the interpreter would
+//		Libraries: details of how each dependency was located
 //	}
 //
 // # Note:
 //
-// Only accepts static binaries or dynamic binaries which use ld-linux*.so as the interpreter
+// Only accepts static binaries or dynamic binaries which use ld-linux*.so, ld64.so or ld-musl-*.so.1 as the interpreter.
+// Binaries for any architecture can be parsed, regardless of the host, as nothing is executed.
 package elf
 
 import (
 	"bytes"
 	debug_elf "debug/elf"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os/exec"
 	"path/filepath"
 	"reflect"
@@ -63,15 +83,49 @@ var (
 	ErrInvalidElf = errors.New("invalid ELF file")
 	// Error wrapping a failure when calling `ld-linux*.so` (like `ldd`) to identify dependencies
 	ErrLdd = errors.New("ldd failed to execute")
+	// Error returned by [CrossCheck()] if native resolution and `ldd` identify different dependencies
+	ErrCrossCheck = errors.New("native resolution differs from ldd")
+	// Error returned when an `ld.so.cache` cannot be parsed
+	ErrInvalidCache = errors.New("invalid ld.so.cache")
+	// Error wrapped by [MissingDependencyError] when one or more dependencies cannot be located
+	ErrMissingDependency = errors.New("missing dependency")
+	// Error wrapped by [UnresolvedSymbolError] when one or more symbols are not defined by any object loaded
+	ErrUnresolvedSymbol = errors.New("unresolved symbol")
 )
 
+// Every dependency which could not be located, each with the Soname & NeededBy of the request.
+//
+// Wraps both [ErrMissingDependency] and [fs.ErrNotExist]. To list the missing dependencies use [errors.As]:
+//
+//	var missing *MissingDependencyError
+//	if errors.As(err, &missing) {
+//	     missing.Missing
+//	}
+//
+// .
+type MissingDependencyError struct {
+	Missing []Library
+}
+
+func (e *MissingDependencyError) Error() string {
+	requests := make([]string, 0, len(e.Missing))
+	for _, lib := range e.Missing {
+		requests = append(requests, lib.Soname+" needed by "+lib.NeededBy)
+	}
+	return ErrMissingDependency.Error() + ": " + strings.Join(requests, ", ")
+}
+
+func (e *MissingDependencyError) Unwrap() []error {
+	return []error{ErrMissingDependency, fs.ErrNotExist}
+}
+
 // # Specific errors which wrap [ErrInvalidElf]
 var (
 	// Error returned if dynamic ELF has a bad entry for the interpreter
 	ErrBadInterpreter = fmt.Errorf("%w: bad interpreter", ErrInvalidElf)
 	// Error returned if the ELF is not a type we support (currently only ET_EXEC & ET_DYN)
 	ErrUnsupportedElfType = fmt.Errorf("%w: %w (unsupported ELF Type)", ErrInvalidElf, errors.ErrUnsupported)
-	// Error returned if the interpreter is not `ld-linux*.so`
+	// Error returned if the interpreter is not `ld-linux*.so` or `ld-musl-*.so.1`
 	ErrUnsupportedInterpreter = fmt.Errorf("%w: %w (unsupported interpreter)", ErrInvalidElf, errors.ErrUnsupported)
 )
 
@@ -84,13 +138,54 @@ type Elf struct {
 	// 32 or 64 bit?
 	//  - See https://man7.org/linux/man-pages/man5/elf.5.html#:~:text=.%20%20(3%3A%20%27F%27)-,EI_CLASS,-The%20fifth%20byte
 	Class EI_CLASS
+	// The architecture (e_machine), e.g. EM_X86_64 or EM_AARCH64
+	Machine debug_elf.Machine
+	// The target operating system ABI (EI_OSABI), usually ELFOSABI_NONE (System V) or ELFOSABI_LINUX
+	OSABI debug_elf.OSABI
 	// Simplified based on ET_DYN & DynFlag1
 	Type Type
+	// The entry point address (e_entry), 0 if none
+	Entry uint64
 	// Absolute path to the interpreter (if executable), "" if not executable.
 	//  - See https://gist.github.com/x0nu11byt3/bcb35c3de461e5fb66173071a2379779 for much more background
 	Interpreter string
+	// Which C library the binary is linked against, based upon the interpreter and DT_NEEDED
+	Libc Libc
+	// DT_SONAME, "" if not set
+	Soname string
+	// The `DT_NEEDED` entries, exactly as listed and in the same order, before any are located
+	Needed []string
+	// The `DT_FILTER` entries: standard filtees, which provide the symbols of this filter object instead
+	Filter []string
+	// The `DT_AUXILIARY` entries: auxiliary filtees, which are only used if they can be found
+	Auxiliary []string
+	// The audit libraries listed in `DT_AUDIT`, only used by ld.so if this is the executable
+	Audit []string
+	// The audit libraries listed in `DT_DEPAUDIT`, only used by ld.so if this is the executable
+	DepAudit []string
+	// Libraries which may be loaded via `dlopen()`, as declared in `.note.dlopen` (or guessed, see [ScanRodata])
+	DlopenDependencies []DlopenDependency
+	// Each directory in DT_RPATH, as listed: before expanding any `$ORIGIN`, `$LIB` or `$PLATFORM`
+	Rpath []string
+	// Each directory in DT_RUNPATH, as listed: before expanding any `$ORIGIN`, `$LIB` or `$PLATFORM`
+	Runpath []string
+	// Hex-encoded contents of the `.note.gnu.build-id` section, "" if there is none
+	BuildID string
+	// Has the symbol table (`.symtab`) been removed?
+	Stripped bool
+	// The highest glibc symbol version needed from any library (`.gnu.version_r`), e.g. `GLIBC_2.34`; "" if none
+	GlibcNeeded string
+	// The highest glibc symbol version defined (`.gnu.version_d`), "" unless this is part of glibc (e.g. `libc.so.6`)
+	GlibcProvided string
+	// The minimum Linux kernel version from `.note.ABI-tag`, e.g. `3.2.0`; "" if there is none
+	MinKernel string
+	// The x86-64 micro-architecture level from `GNU_PROPERTY_X86_ISA_1_NEEDED`: 1 (x86-64-baseline) to 4
+	// (x86-64-v4); 0 if not recorded
+	ISALevel int
 	// All requested libraries
 	Dependencies []string
+	// How each of the Dependencies was located, in the same order
+	Libraries []Library
 }
 
 // 32 or 64 bit?
@@ -104,6 +199,46 @@ const (
 	ELF64   = debug_elf.ELFCLASS64   // 2
 )
 
+func (c EI_CLASS) String() string {
+	switch debug_elf.Class(c) {
+	case ELFNONE:
+		return "ELFNONE"
+	case ELF32:
+		return "ELF32"
+	case ELF64:
+		return "ELF64"
+	default:
+		return fmt.Sprintf("EI_CLASS(%d)", byte(c))
+	}
+}
+
+func (c EI_CLASS) MarshalJSON() ([]byte, error) { return json.Marshal(c.String()) }
+
+// The C library, and therefore the dynamic loader, used by a binary
+type Libc byte
+
+// # Values for [Libc]
+const (
+	LIBCNONE = 0 // Not dynamically linked against a known libc
+	GLIBC    = 1 // GNU libc: loaded by `ld-linux*.so`
+	MUSL     = 2 // musl libc (e.g. Alpine): the loader `ld-musl-<arch>.so.1` is also libc
+)
+
+func (l Libc) String() string {
+	switch l {
+	case LIBCNONE:
+		return "none"
+	case GLIBC:
+		return "glibc"
+	case MUSL:
+		return "musl"
+	default:
+		return fmt.Sprintf("Libc(%d)", byte(l))
+	}
+}
+
+func (l Libc) MarshalJSON() ([]byte, error) { return json.Marshal(l.String()) }
+
 // Binary type
 //
 // Think carefully before directly comparing to bitmask (2^n) values. See value descriptions for individual hints.
@@ -113,16 +248,38 @@ type Type byte
 //
 // Think carefully before directly comparing to bitmask (2^n) values. See value descriptions for individual hints.
 const (
-	UNDEF = 0 // Undefined
-	EXEC  = 1 // Executable: Use Elf.IsExe() to catch _any_ type of executable (including PIE)
-	DYN   = 2 // Dynamic: Use Elf.IsDyn() to catch _any_ type of dynamically linked binary (including ET_EXEC with Dyn table)
+	UNDEF     = 0 // Undefined
+	EXEC      = 1 // Executable: Use Elf.IsExe() to catch _any_ type of executable (including PIE)
+	DYN       = 2 // Dynamic: Use Elf.IsDyn() to catch _any_ type of dynamically linked binary (including ET_EXEC with Dyn table)
+	SELFRELOC = 4 // Self-relocating: position independent but relocates itself without an interpreter
 )
 
 // # Meaningful combination values for [Type]
 const (
-	DYNEXE = 3 // EXEC + DYN (PIE or ET_EXEC with Dynamic table)
+	DYNEXE    = 3 // EXEC + DYN (PIE or ET_EXEC with Dynamic table)
+	STATICPIE = 5 // EXEC + SELFRELOC (static-PIE, e.g. `gcc -static-pie`: no interpreter or dependencies)
 )
 
+// The name of the matching constant, e.g. "DYNEXE"
+func (t Type) String() string {
+	switch t {
+	case UNDEF:
+		return "UNDEF"
+	case EXEC:
+		return "EXEC"
+	case DYN:
+		return "DYN"
+	case DYNEXE:
+		return "DYNEXE"
+	case STATICPIE:
+		return "STATICPIE"
+	default:
+		return fmt.Sprintf("Type(%d)", byte(t))
+	}
+}
+
+func (t Type) MarshalJSON() ([]byte, error) { return json.Marshal(t.String()) }
+
 // Is this ELF **primarily** an executable.
 //
 //   - This will return `false` in cases such as `/lib64/ld-linux-x86-64.so.2` which has an entry point
@@ -139,6 +296,8 @@ func (e *Elf) IsLib() bool {
 }
 
 // Is this ELF dynamically linked?
+//
+//   - This will return `false` for a static-PIE, which has a Dynamic table but only to relocate itself
 func (e *Elf) IsDyn() bool {
 	return e.Type&Type(DYN) != 0
 }
@@ -150,36 +309,154 @@ func (e *Elf) IsDyn() bool {
 //     in this case Name & Path will be filled, although Path may not be fully resolved
 //   - If errors are encountered in parsing these will be collected in the returned [ErrElf] and the result will
 //     contain as much valid information as possible
-func New(path string) (Elf, error) {
+//   - If a [Sysroot] is provided, path is relative to it
+func New(path string, opts ...Option) (Elf, error) {
+	elf, _, _, err := parse(path, opts)
+	return elf, err
+}
+
+// Construct a new [Elf] for the file located at path from its headers alone, any error will be an [ErrElf]
+//
+//   - Only Name, Path, Class, Machine and the informational details (Soname, GlibcNeeded, MinKernel, ISALevel
+//     etc.) are filled. Nothing is resolved, so a missing interpreter or dependency is not an error.
+//   - Returns an error only if the Path cannot be resolved or the file is not a valid ELF
+//   - Accepts the same Options as [New], although only [Sysroot] has any effect
+func Headers(path string, opts ...Option) (Elf, error) {
+	elf := Elf{Name: filepath.Base(path), Path: path}
+	reterr := &ErrElf{path: path}
+
+	options, err := newOptions(opts)
+	if err != nil {
+		reterr.Join(err)
+		return elf, reterr
+	}
+	files := options.filesystem()
+
+	resolved, err := resolve(files, path)
+	if err != nil {
+		reterr.Join(err)
+		return elf, reterr
+	}
+	elf.Path = resolved
+
+	elffile, err := files.openElf(elf.Path)
+	if err != nil {
+		reterr.Join(invalidElf(err))
+		return elf, reterr
+	}
+
+	elf.Class = EI_CLASS(elffile.Class)
+	elf.Machine = elffile.Machine
+	metadata(&elf, elffile)
+
+	reterr.Join(elffile.Close())
+	if reterr.IsError() {
+		return elf, reterr
+	}
+	return elf, nil
+}
+
+// Construct a new [Elf] for the file at path within fsys, any error will be an [ErrElf]
+//
+//   - fsys is treated as `/`: path, any symlinks, DT_NEEDED lookups, DT_RPATH & DT_RUNPATH, ld.so.cache &
+//     ld.so.conf are all resolved within it. Nothing is read from the host.
+//   - Path, Dependencies & Libraries will contain absolute paths within fsys, e.g. "/usr/lib/libc.so.6"
+//   - Symlinks can only be followed if fsys implements [fs.ReadLinkFS] (e.g. [os.DirFS])
+//   - Each ELF is read fully into memory
+//   - Accepts the same Options as [New], except [Sysroot] & [CrossCheck()] which are not supported
+func NewFromFS(fsys fs.FS, path string, opts ...Option) (Elf, error) {
+	elf, _, _, err := parse(path, append(slices.Clone(opts), func(o *options) { o.fsys = fsys }))
+	return elf, err
+}
+
+// Construct a new [Elf] from the contents of r, any error will be an [ErrElf]
+//
+//   - path is where the file would be located, it does not need to exist. It provides the Name & Path and
+//     is used to expand `$ORIGIN` and to locate any relative pathnames in DT_NEEDED.
+//   - Dependencies are resolved on the host or, if provided, within the [Sysroot]
+//   - Accepts the same Options as [New], except [CrossCheck()] which is not supported
+func NewFromReader(r io.ReaderAt, path string, opts ...Option) (Elf, error) {
+	options, err := newOptions(opts)
+	elf := Elf{Name: filepath.Base(path), Path: path}
+	reterr := &ErrElf{path: path}
+	if err != nil {
+		reterr.Join(err)
+		return elf, reterr
+	}
+
+	if options.sysroot != "" {
+		elf.Path = options.filesystem().host(filepath.Join("/", path))
+	} else if elf.Path, err = filepath.Abs(path); err != nil {
+		reterr.Join(err)
+		return elf, reterr
+	}
+	if options.crosscheck {
+		reterr.Join(fmt.Errorf("%w: %w when reading from an io.ReaderAt", ErrCrossCheck, errors.ErrUnsupported))
+		return elf, reterr
+	}
+
+	elffile, err := debug_elf.NewFile(r)
+	if err != nil {
+		reterr.Join(invalidElf(err))
+		return elf, reterr
+	}
+	elf, _ = parseFile(elf, elffile, options, reterr)
+	if reterr.IsError() {
+		return elf, reterr
+	}
+	return elf, nil
+}
+
+// The options from opts, with any sysroot made absolute
+func newOptions(opts []Option) (options, error) {
+	var options options
+	for _, optfn := range opts {
+		optfn(&options)
+	}
+	if options.sysroot != "" {
+		var err error
+		if options.sysroot, err = filepath.Abs(options.sysroot); err != nil {
+			return options, err
+		}
+	}
+	return options, nil
+}
+
+// Does the work for [New], [NewFromFS] & [NewGraph], additionally returning every `DT_NEEDED` edge and the
+// final options.
+func parse(path string, opts []Option) (Elf, []Edge, options, error) {
 	elf := Elf{Path: path}
 	reterr := &ErrElf{path: path} // error(s) returned from this function
 	var err error                 // individual error returned by any functions called
 	var elffile *debug_elf.File   // the opened File
+	var edges []Edge              // every DT_NEEDED entry, from the resolver
 
 	elf.Name = filepath.Base(path)
 
-	elf.Path, err = resolve(path)
+	options, err := newOptions(opts)
+	if err != nil {
+		reterr.Join(err)
+		return elf, edges, options, reterr
+	}
+	if options.fsys != nil && options.sysroot != "" {
+		reterr.Join(fmt.Errorf("%w: Sysroot with an fs.FS", errors.ErrUnsupported))
+		return elf, edges, options, reterr
+	}
+	files := options.filesystem()
+
+	elf.Path, err = resolve(files, path)
 	if err != nil {
 		if elf.Path == "" { // resolve may return "" on error
 			elf.Path = path // so we reset the path if that's happened
 		}
 		reterr.Join(err)
-		return elf, reterr
+		return elf, edges, options, reterr
 	}
 
-	elffile, err = debug_elf.Open(elf.Path)
+	elffile, err = files.openElf(elf.Path)
 	if err != nil {
-		if errors.Is(err, io.EOF) {
-			// stdlib doesn't correctly return a FormatError if the file is empty, instead we get a naked `io.EOF`
-			// See: https://github.com/golang/go/issues/76338
-			err = fmt.Errorf("no data %w", &debug_elf.FormatError{})
-		}
-		var formaterr *debug_elf.FormatError
-		if errors.As(err, &formaterr) {
-			err = fmt.Errorf("%w: %w", ErrInvalidElf, err)
-		}
-		reterr.Join(err)
-		return elf, reterr
+		reterr.Join(invalidElf(err))
+		return elf, edges, options, reterr
 	}
 	defer func() {
 		reterr.Join(
@@ -187,7 +464,41 @@ func New(path string) (Elf, error) {
 		)
 	}()
 
+	elf, edges = parseFile(elf, elffile, options, reterr)
+	if reterr.IsError() {
+		return elf, edges, options, reterr
+	}
+	return elf, edges, options, nil
+}
+
+// Wraps any [debug_elf.FormatError] in [ErrInvalidElf]
+func invalidElf(err error) error {
+	if errors.Is(err, io.EOF) {
+		// stdlib doesn't correctly return a FormatError if the file is empty, instead we get a naked `io.EOF`
+		// See: https://github.com/golang/go/issues/76338
+		err = fmt.Errorf("no data %w", &debug_elf.FormatError{})
+	}
+	var formaterr *debug_elf.FormatError
+	if errors.As(err, &formaterr) {
+		return fmt.Errorf("%w: %w", ErrInvalidElf, err)
+	}
+	return err
+}
+
+// Parses the opened elffile, whose Name & Path are already set in elf, and resolves its dependencies.
+//
+// Any errors are joined to reterr.
+func parseFile(elf Elf, elffile *debug_elf.File, options options, reterr *ErrElf) (Elf, []Edge) {
+	var err error
+	var edges []Edge
+
 	elf.Class = EI_CLASS(elffile.Class)
+	elf.Machine = elffile.Machine
+
+	metadata(&elf, elffile)
+
+	// informational unless resolving them with [Dlopen], when the resolver reports any error
+	elf.DlopenDependencies, _ = dlopenDependencies(elffile, options.guessDlopen())
 
 	elf.Interpreter, err = interpreter(elffile)
 	if err != nil {
@@ -199,25 +510,35 @@ func New(path string) (Elf, error) {
 		reterr.Join(err)
 	}
 	if elf.Type == Type(DYNEXE) && elf.Interpreter == "" {
-		err = fmt.Errorf("%w (PIE without interpreter)", ErrBadInterpreter)
+		err = fmt.Errorf("%w (dynamic executable without interpreter)", ErrBadInterpreter)
 		reterr.Join(err)
 	}
 
 	if elf.IsDyn() {
-		elf.Dependencies, err = ldd(elf.Path, elf.Interpreter)
+		elf.Libc, err = libc(elffile, elf.Interpreter)
 		if err != nil {
 			reterr.Join(err)
 		}
+		elf.Libraries, edges, err = dependencies(elf.Path, elffile, elf.Interpreter, elf.Libc, options)
+		if err != nil {
+			reterr.Join(err)
+		}
+		for _, lib := range elf.Libraries {
+			elf.Dependencies = append(elf.Dependencies, lib.Path)
+			if elf.Libc == LIBCNONE {
+				elf.Libc = libcFromSoname(lib.Soname)
+			}
+		}
 	}
 
-	if reterr.IsError() {
-		return elf, reterr
-	}
-	return elf, nil
+	return elf, edges
 }
 
-// resolve resolves symlinks and returns an absolute path.
-func resolve(path string) (string, error) {
+// resolve resolves symlinks (within the root of files, if any) and returns an absolute path.
+func resolve(files filesystem, path string) (string, error) {
+	if files.root != "" || files.fsys != nil {
+		return files.evalSymlinks(path)
+	}
 	path, err := filepath.Abs(path)
 	if err != nil {
 		return path, err
@@ -229,6 +550,59 @@ func resolve(path string) (string, error) {
 	return path, nil
 }
 
+// Fills in the details of elf which come directly from the headers and need no interpretation: OSABI, Entry,
+// Soname, Needed, Filter, Auxiliary, Audit, DepAudit, Rpath, Runpath, BuildID, Stripped, GlibcNeeded,
+// GlibcProvided, MinKernel & ISALevel.
+//
+// These are informational, so best-effort: anything which cannot be read is left empty. Entries which are
+// needed to resolve dependencies are read again, and any errors reported, by the resolver.
+func metadata(elf *Elf, elffile *debug_elf.File) {
+	dynstring := func(tag debug_elf.DynTag) []string {
+		values, _ := elffile.DynString(tag)
+		return values
+	}
+
+	elf.OSABI = elffile.OSABI
+	elf.Entry = elffile.Entry
+	if soname := dynstring(debug_elf.DT_SONAME); len(soname) > 0 {
+		elf.Soname = soname[0]
+	}
+	elf.Needed = dynstring(debug_elf.DT_NEEDED)
+	filters, _ := dynamicStrings(elffile, debug_elf.DT_FILTER, debug_elf.DT_AUXILIARY, debug_elf.DT_AUDIT, debug_elf.DT_DEPAUDIT)
+	for _, entry := range filters {
+		switch entry.tag {
+		case debug_elf.DT_FILTER:
+			elf.Filter = append(elf.Filter, entry.value)
+		case debug_elf.DT_AUXILIARY:
+			elf.Auxiliary = append(elf.Auxiliary, entry.value)
+		case debug_elf.DT_AUDIT:
+			elf.Audit = append(elf.Audit, splitPath([]string{entry.value})...)
+		case debug_elf.DT_DEPAUDIT:
+			elf.DepAudit = append(elf.DepAudit, splitPath([]string{entry.value})...)
+		}
+	}
+	elf.Rpath = splitPath(dynstring(debug_elf.DT_RPATH))
+	elf.Runpath = splitPath(dynstring(debug_elf.DT_RUNPATH))
+	elf.Stripped = elffile.Section(".symtab") == nil
+
+	elf.BuildID = buildID(elffile)
+	elf.GlibcNeeded, _ = glibcNeeded(elffile)
+	elf.GlibcProvided, _ = glibcProvided(elffile)
+	elf.MinKernel, _ = minKernel(elffile)
+	elf.ISALevel, _ = isaLevel(elffile)
+}
+
+// The hex-encoded build-id from `.note.gnu.build-id`, "" if there is none or it cannot be read
+func buildID(elffile *debug_elf.File) string {
+	buildIDs, _ := notes(elffile, ".note.gnu.build-id")
+	for _, note := range buildIDs {
+		if note.name == "GNU" && note.kind == nt_GNU_BUILD_ID {
+			return hex.EncodeToString(note.desc)
+		}
+	}
+	return ""
+}
+
 // Identifies the type of Elf (binary vs library) based upon a combination of `DT_FLAGS_1` & the claimed `e_type` in the header.
 //
 //   - Returns `Type(UNDEF), ErrUnsupportedElfType` for types we don't recognise.
@@ -247,9 +621,12 @@ func elftype(elffile *debug_elf.File) (Type, error) {
 		if err != nil {
 			return Type(DYN), err
 		}
-		if pie {
+		switch {
+		case pie && !hasInterpreter(elffile) && !hasNeeded(elffile):
+			return Type(STATICPIE), nil
+		case pie:
 			return Type(DYNEXE), nil
-		} else {
+		default:
 			return Type(DYN), nil
 		}
 
@@ -258,6 +635,17 @@ func elftype(elffile *debug_elf.File) (Type, error) {
 	}
 }
 
+// Does the ELF have a `PT_INTERP` Program header?
+func hasInterpreter(elffile *debug_elf.File) bool {
+	return slices.ContainsFunc(elffile.Progs, func(prog *debug_elf.Prog) bool { return prog.Type == debug_elf.PT_INTERP })
+}
+
+// Does the ELF have any `DT_NEEDED` entries?
+func hasNeeded(elffile *debug_elf.File) bool {
+	needed, _ := elffile.DynString(debug_elf.DT_NEEDED)
+	return len(needed) > 0
+}
+
 // Identify the interpreter requested by the ELF, based upon the `PT_INTERP` Program header.
 //
 // Returns (... ,nil):
@@ -291,44 +679,245 @@ func interpreter(elffile *debug_elf.File) (string, error) {
 	return "", nil
 }
 
-// Does the same as `ldd` under the hood - calls the interpreter with `LD_TRACE_LOADED_OBJECTS=1`;
-// then parses the output to return ONLY dependencies which the interpreter had to find.
+// Identify the libc based upon the interpreter or, for libraries, a direct dependency on libc.
+//
+// Returns LIBCNONE if neither identifies a known libc, e.g. a library which does not directly need libc.
+func libc(elffile *debug_elf.File, interpreter string) (Libc, error) {
+	switch {
+	case internal.Ld_musl_RE.MatchString(interpreter):
+		return MUSL, nil
+	case internal.Ld_linux_RE.MatchString(interpreter):
+		return GLIBC, nil
+	}
+	needed, err := elffile.DynString(debug_elf.DT_NEEDED)
+	if err != nil {
+		return LIBCNONE, fmt.Errorf("%w: reading DT_NEEDED: %w", ErrInvalidElf, err)
+	}
+	for _, soname := range needed {
+		if libc := libcFromSoname(soname); libc != LIBCNONE {
+			return libc, nil
+		}
+	}
+	return LIBCNONE, nil
+}
+
+// Which libc, if any, provides soname
+func libcFromSoname(soname string) Libc {
+	switch {
+	case soname == "libc.so.6":
+		return GLIBC
+	case strings.HasPrefix(soname, "libc.musl-"):
+		return MUSL
+	default:
+		return LIBCNONE
+	}
+}
+
+// The interpreter which would load a library of the given class, machine & libc.
+func defaultInterpreter(elffile *debug_elf.File, libc Libc) string {
+	if libc == MUSL {
+		return "/lib/ld-musl-" + muslArch(elffile) + ".so.1"
+	}
+	return archOf(elffile).interpreter
+}
+
+// Locates all dependencies natively and, if requested, cross-checks the result against [ldd].
+func dependencies(path string, elffile *debug_elf.File, interpreter string, libc Libc, options options) ([]Library, []Edge, error) {
+	loader := interpreter
+	if loader == "" {
+		loader = defaultInterpreter(elffile, libc)
+	}
+
+	resolver := newResolver(elffile, loader, libc, options)
+	libraries, err := resolver.resolve(path, elffile)
+	if err != nil || !options.crosscheck {
+		return libraries, resolver.edges, err
+	}
+	if options.sysroot != "" {
+		return libraries, resolver.edges, fmt.Errorf("%w: %w with a sysroot", ErrCrossCheck, errors.ErrUnsupported)
+	}
+	if options.fsys != nil {
+		return libraries, resolver.edges, fmt.Errorf("%w: %w with an fs.FS", ErrCrossCheck, errors.ErrUnsupported)
+	}
+	if !isNative(elffile.Machine) {
+		return libraries, resolver.edges, fmt.Errorf("%w: %w for %s on this host", ErrCrossCheck, errors.ErrUnsupported, elffile.Machine)
+	}
+
+	lddDependencies, err := ldd(path, loader)
+	var missing *MissingDependencyError
+	if errors.As(err, &missing) {
+		// ld.so ignores any DT_AUXILIARY which cannot be found, but ldd lists them
+		missing.Missing = slices.DeleteFunc(missing.Missing, func(lib Library) bool { return slices.Contains(resolver.ignored, lib.Soname) })
+		if len(missing.Missing) == 0 {
+			err = nil
+		}
+	}
+	if err != nil {
+		return libraries, resolver.edges, err
+	}
+	// audit libraries & anything loaded via dlopen() are invisible to ldd
+	if !slices.Equal(resolver.startup, lddDependencies) {
+		return libraries, resolver.edges, fmt.Errorf("%w: %v != %v", ErrCrossCheck, resolver.startup, lddDependencies)
+	}
+	return libraries, resolver.edges, nil
+}
+
+// Does the same as `ldd` under the hood - calls the interpreter with `LD_TRACE_LOADED_OBJECTS=1`
+// (or `--list` for musl); then parses the output to return ONLY dependencies which the interpreter
+// had to load (i.e. not the interpreter itself or the vdso).
+//
+// Only used to cross-check native resolution, see [CrossCheck()].
 //
 // Note:
-//   - Will not return any dependencies which contain a `/`
+//   - Runs from the directory containing path, so that any relative `DT_NEEDED` pathnames are opened
+//     in the same place as by [New]
 //   - See: https://man7.org/linux/man-pages/man8/ld.so.8.html for full details of
 //     how the search is performed.
 //   - In case of error a ErrElfLdd is returned, along with the underlying error(s)
 //   - WARNING: does no sanity chacking on the input path - make sure what you are passing refers
 //     to a valid dynamically linked ELF, which `ld-linux.so*` can parse. E.g.: passing a statically
 //     linked ELF will lead to a segfault (which gets caught and returned as an error).
-//   - WARNING: Behaviour is *undefined* for interpreters except `ld-linux.so*`
+//   - musl reports libc (and any other library it provides itself) as the interpreter, these are
+//     not returned
+//   - WARNING: Behaviour is *undefined* for interpreters except `ld-linux.so*` & `ld-musl-*.so.1`
 func ldd(path string, interpreter string) ([]string, error) {
-	if interpreter == "" {
+	abspath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s %s: %w", ErrLdd, interpreter, path, err)
+	}
+
+	var ldso *exec.Cmd
+	switch {
+	case interpreter == "":
 		interpreter = internal.P_ld_linux
-	} else if !internal.Ld_linux_64_RE.MatchString(interpreter) {
+		fallthrough
+	case internal.Ld_linux_64_RE.MatchString(interpreter), internal.Ld_linux_32_RE.MatchString(interpreter):
+		ldso = exec.Command(interpreter, abspath)
+		ldso.Env = append(ldso.Env, "LD_TRACE_LOADED_OBJECTS=1")
+	case internal.Ld_musl_RE.MatchString(interpreter):
+		ldso = exec.Command(interpreter, "--list", abspath)
+	default:
 		return nil, fmt.Errorf("%w '%s'", ErrUnsupportedInterpreter, interpreter)
 	}
+	ldso.Dir = filepath.Dir(abspath)
 
-	ldso := exec.Command(interpreter, path)
-	ldso.Env = append(ldso.Env, "LD_TRACE_LOADED_OBJECTS=1")
 	stdout, err := ldso.Output()
 	if err != nil {
 		return nil, fmt.Errorf("%w %s %s: %w", ErrLdd, interpreter, path, err)
 	}
 
-	dependencies := make([]string, 0, strings.Count(string(stdout), "=>"))
-	lines := strings.Lines(string(stdout))
-	for line := range lines {
-		if strings.Contains(line, "=>") {
-			dependencies = append(dependencies, strings.Fields(line)[2])
+	return parseLdd(path, interpreter, string(stdout))
+}
+
+// Parses the output of `ldd` for path, returning an error wrapping [ErrMissingDependency] listing any
+// dependencies which were `not found`.
+//
+// Relative pathnames are returned relative to the directory containing path.
+func parseLdd(path string, interpreter string, stdout string) ([]string, error) {
+	var missing []Library
+	dependencies := make([]string, 0, strings.Count(stdout, "=>"))
+	for line := range strings.Lines(stdout) {
+		soname, dependency, found := strings.Cut(line, "=>")
+		if !found {
+			dependency = line // loaded directly by path (or the vdso & interpreter, which are not needed)
+		}
+		fields := strings.Fields(dependency)
+		switch {
+		case len(fields) == 0:
+			continue
+		case found && strings.TrimSpace(dependency) == "not found":
+			missing = append(missing, Library{Soname: strings.TrimSpace(soname), NeededBy: path})
+		case !strings.Contains(fields[0], "/"), fields[0] == interpreter:
+			continue
+		case !filepath.IsAbs(fields[0]):
+			dependencies = append(dependencies, filepath.Join(filepath.Dir(path), fields[0]))
+		default:
+			dependencies = append(dependencies, fields[0])
 		}
 	}
 
 	slices.SortFunc(dependencies, libpathcmp)
+	if len(missing) > 0 {
+		return dependencies, &MissingDependencyError{missing}
+	}
 	return dependencies, nil
 }
 
+// options used by [New]
+type options struct {
+	crosscheck  bool     // also call the interpreter and validate that both agree
+	sysroot     string   // resolve everything relative to this root, "" for the host
+	fsys        fs.FS    // resolve everything within this, instead of the host, only set by [NewFromFS]
+	libraryPath []string // LD_LIBRARY_PATH to use during resolution
+	explain     bool     // record every location searched in Library.Candidates
+	isaLevel    int      // x86-64 micro-architecture level to resolve glibc-hwcaps for, 0 for the baseline only
+	dlopen      Priority // resolve .note.dlopen dependencies with at least this priority, "" for none
+	scanRodata  bool     // guess dlopen dependencies from .rodata if there is no .note.dlopen
+}
+
+// Option setting functions
+type Option func(*options)
+
+// Where to read everything from
+func (o options) filesystem() filesystem {
+	return filesystem{root: o.sysroot, fsys: o.fsys}
+}
+
+// Also call the interpreter, like `ldd`, and validate that it finds the same dependencies.
+//
+// Not supported with a [Sysroot] or for architectures which the host cannot execute.
+//
+// WARNING: this executes the interpreter against the file being parsed, only use with trusted files.
+func CrossCheck() Option { return func(o *options) { o.crosscheck = true } }
+
+// Record every location searched for each library, and whether it contained a compatible library, in
+// [Library].Candidates.
+//
+// Slower: the search continues past the library used, to find any others which it took precedence over.
+func Explain() Option { return func(o *options) { o.explain = true } }
+
+// Search dirs, in order, after DT_RPATH and before DT_RUNPATH, in the same way as `LD_LIBRARY_PATH`.
+//
+// The host's `LD_LIBRARY_PATH` is never used. dirs are relative to any [Sysroot].
+func LibraryPath(dirs ...string) Option {
+	return func(o *options) { o.libraryPath = append(o.libraryPath, dirs...) }
+}
+
+// Resolve every library declared in `.note.dlopen` with at least priority, as though it were an extra
+// `DT_NEEDED` entry of the object which declares it, processed after its `DT_NEEDED` entries.
+//
+//   - Only the first alternative soname which can be found is loaded
+//   - Only REQUIRED libraries are reported in a [MissingDependencyError] if none can be found
+//   - Located libraries are marked as Dlopen in Libraries & Edges
+func Dlopen(priority Priority) Option { return func(o *options) { o.dlopen = priority } }
+
+// Scan `.rodata` for strings which look like sonames (e.g. `libfoo.so.1`) in any object which does not have a
+// `.note.dlopen`, and treat them as a [DlopenDependency] which is Guessed.
+//
+// A heuristic fallback for libraries which do not declare what they `dlopen()`. Only has an effect with [Dlopen],
+// Guessed libraries are then resolved whatever the priority but never reported as missing.
+func ScanRodata() Option { return func(o *options) { o.scanRodata = true } }
+
+// Scan `.rodata` for dlopen dependencies? Only if they will be resolved, see [ScanRodata]
+func (o options) guessDlopen() bool { return o.scanRodata && o.dlopen != "" }
+
+// Resolve libraries as `ld.so` would on an x86-64 CPU supporting the micro-architecture level (see [ISALevelName]):
+// the matching `glibc-hwcaps` subdirectories (e.g. `glibc-hwcaps/x86-64-v3`) are searched, highest level first,
+// before the baseline in every search path & ld.so.cache.
+//
+// Without TargetISA, or with level 1, `glibc-hwcaps` subdirectories are ignored and the baseline libraries are
+// used, which run on any x86-64 CPU. Has no effect for other architectures or musl.
+func TargetISA(level int) Option { return func(o *options) { o.isaLevel = level } }
+
+// Resolve everything relative to root, as though it were `/`, rather than the host.
+//
+//   - The path given to [New], DT_NEEDED lookups, DT_RPATH & DT_RUNPATH, ld.so.cache, ld.so.conf and any
+//     symlinks are all resolved within root
+//   - Path, Dependencies & Libraries will contain paths on the host (i.e. including root), Interpreter will
+//     contain the value exactly as requested by the binary
+//   - Cannot be combined with [CrossCheck()], which would need to execute the interpreter inside root
+func Sysroot(root string) Option { return func(o *options) { o.sysroot = root } }
+
 // Does the file have the given DT_FLAGS_1 flag set?
 func hasDT_FLAGS_1_Flag(elffile *debug_elf.File, flag debug_elf.DynFlag1) (bool, error) {
 	dt_flags_1, err := elffile.DynValue(debug_elf.DynTag(debug_elf.DT_FLAGS_1))
@@ -344,9 +933,70 @@ func hasDT_FLAGS_1_Flag(elffile *debug_elf.File, flag debug_elf.DynFlag1) (bool,
 	return false, nil
 }
 
+// A string-valued entry in the dynamic section
+type dynamicString struct {
+	tag   debug_elf.DynTag
+	value string
+}
+
+// Every entry in the dynamic section with one of tags, in the order listed.
+//
+// Unlike [debug_elf.File.DynString], which only supports DT_NEEDED, DT_SONAME, DT_RPATH & DT_RUNPATH, this
+// supports any tag whose value is an offset into the dynamic string table.
+func dynamicStrings(elffile *debug_elf.File, tags ...debug_elf.DynTag) ([]dynamicString, error) {
+	dynamic := elffile.SectionByType(debug_elf.SHT_DYNAMIC)
+	if dynamic == nil {
+		return nil, nil // not dynamic
+	}
+	data, err := dynamic.Data()
+	if err != nil {
+		return nil, fmt.Errorf("%w: reading dynamic section: %w", ErrInvalidElf, err)
+	}
+	if int(dynamic.Link) >= len(elffile.Sections) {
+		return nil, fmt.Errorf("%w: dynamic section links to missing string table", ErrInvalidElf)
+	}
+	strtab, err := elffile.Sections[dynamic.Link].Data()
+	if err != nil {
+		return nil, fmt.Errorf("%w: reading dynamic string table: %w", ErrInvalidElf, err)
+	}
+
+	// d_tag & d_val, each 4 or 8 bytes
+	word := 4
+	if elffile.Class == debug_elf.ELFCLASS64 {
+		word = 8
+	}
+	read := func(offset int) uint64 {
+		if word == 8 {
+			return elffile.ByteOrder.Uint64(data[offset:])
+		}
+		return uint64(elffile.ByteOrder.Uint32(data[offset:]))
+	}
+
+	var entries []dynamicString
+	for offset := 0; offset+2*word <= len(data); offset += 2 * word {
+		tag := debug_elf.DynTag(read(offset))
+		if tag == debug_elf.DT_NULL {
+			break
+		}
+		if !slices.Contains(tags, tag) {
+			continue
+		}
+		value := read(offset + word)
+		if value >= uint64(len(strtab)) {
+			return entries, fmt.Errorf("%w: %s is outside the dynamic string table", ErrInvalidElf, tag)
+		}
+		str, _, _ := bytes.Cut(strtab[value:], []byte{0})
+		entries = append(entries, dynamicString{tag, string(str)})
+	}
+	return entries, nil
+}
+
 // Deeply check for diffs between two `Elf`s. Ignores differences in the Path, as long as:
 //  1. Both `Path`s are absolute
 //  2. Both `Path`s end in the same filename
+//
+// Only compares the Soname, Path & NeededBy of Libraries, how they were located depends upon the
+// configuration of the host. Needed, Rpath & Runpath are compared exactly as listed: in order and unexpanded.
 func (e Elf) Diff(o Elf) []string {
 	var diffs []string
 	elf := reflect.TypeOf(e)
@@ -362,6 +1012,10 @@ func (e Elf) Diff(o Elf) []string {
 			if libpathcmp(selfVal.(string), otherVal.(string)) != 0 {
 				diffs = append(diffs, fmt.Sprintf("%s differs for %s: %v != %v", field.Name, self.FieldByName("Name"), selfVal, otherVal))
 			}
+		case "Entry":
+			if selfVal != otherVal {
+				diffs = append(diffs, fmt.Sprintf("%s differs for %s: %#x != %#x", field.Name, self.FieldByName("Name"), selfVal, otherVal))
+			}
 		case "Dependencies":
 			selfDeps := self.FieldByIndex(field.Index).Interface().([]string)
 			otherDeps := other.FieldByIndex(field.Index).Interface().([]string)
@@ -375,6 +1029,21 @@ func (e Elf) Diff(o Elf) []string {
 					}
 				}
 			}
+		case "Libraries":
+			selfLibs := selfVal.([]Library)
+			otherLibs := otherVal.([]Library)
+			if len(selfLibs) != len(otherLibs) {
+				diffs = append(diffs, fmt.Sprintf("%s has %v libraries in left, %v libraries in right", self.FieldByName("Name"), len(selfLibs), len(otherLibs)))
+			} else {
+				for idx, selfLib := range selfLibs {
+					otherLib := otherLibs[idx]
+					if selfLib.Soname != otherLib.Soname ||
+						libpathcmp(selfLib.Path, otherLib.Path) != 0 ||
+						libpathcmp(selfLib.NeededBy, otherLib.NeededBy) != 0 {
+						diffs = append(diffs, fmt.Sprintf("library %v differs for %s: %+v != %+v", idx, self.FieldByName("Name"), selfLib, otherLib))
+					}
+				}
+			}
 		default:
 			if !reflect.DeepEqual(selfVal, otherVal) {
 				diffs = append(diffs, fmt.Sprintf("%s differs for %s: %v != %v", field.Name, self.FieldByName("Name"), selfVal, otherVal))
@@ -384,6 +1053,123 @@ func (e Elf) Diff(o Elf) []string {
 	return diffs
 }
 
+// A readable, multi-line summary in the style of `readelf`, each dependency shown as `soname => path`
+// where known
+func (e Elf) String() string {
+	var str strings.Builder
+	field := func(name string, value any) {
+		fmt.Fprintf(&str, "%-14s%v\n", name+":", value)
+	}
+	field("Name", e.Name)
+	field("Path", e.Path)
+	field("Class", e.Class)
+	field("Machine", e.Machine)
+	field("OSABI", e.OSABI)
+	field("Type", e.Type)
+	field("Entry", fmt.Sprintf("%#x", e.Entry))
+	if e.Interpreter != "" {
+		field("Interpreter", e.Interpreter)
+	}
+	field("Libc", e.Libc)
+	if e.Soname != "" {
+		field("Soname", e.Soname)
+	}
+	if len(e.Needed) > 0 {
+		field("Needed", strings.Join(e.Needed, ", "))
+	}
+	if len(e.Filter) > 0 {
+		field("Filter", strings.Join(e.Filter, ", "))
+	}
+	if len(e.Auxiliary) > 0 {
+		field("Auxiliary", strings.Join(e.Auxiliary, ", "))
+	}
+	if len(e.Audit) > 0 {
+		field("Audit", strings.Join(e.Audit, ":"))
+	}
+	if len(e.DepAudit) > 0 {
+		field("DepAudit", strings.Join(e.DepAudit, ":"))
+	}
+	for _, dependency := range e.DlopenDependencies {
+		detail := string(dependency.Priority)
+		if dependency.Guessed {
+			detail = "guessed"
+		}
+		if dependency.Feature != "" {
+			detail = dependency.Feature + ", " + detail
+		}
+		field("Dlopen", strings.Join(dependency.Sonames, " | ")+" ("+detail+")")
+	}
+	if len(e.Rpath) > 0 {
+		field("RPATH", strings.Join(e.Rpath, ":"))
+	}
+	if len(e.Runpath) > 0 {
+		field("RUNPATH", strings.Join(e.Runpath, ":"))
+	}
+	if e.BuildID != "" {
+		field("Build ID", e.BuildID)
+	}
+	field("Stripped", e.Stripped)
+	if e.GlibcNeeded != "" {
+		field("Needs glibc", e.GlibcNeeded)
+	}
+	if e.GlibcProvided != "" {
+		field("Provides", e.GlibcProvided)
+	}
+	if e.MinKernel != "" {
+		field("Min kernel", e.MinKernel)
+	}
+	if e.ISALevel > 0 {
+		field("ISA level", ISALevelName(e.ISALevel))
+	}
+	str.WriteString("Dependencies:\n")
+	for idx, dependency := range e.Dependencies {
+		if len(e.Libraries) == len(e.Dependencies) {
+			lib := e.Libraries[idx]
+			fmt.Fprintf(&str, "    %s => %s (%s)\n", lib.Soname, dependency, lib.Source)
+		} else {
+			fmt.Fprintf(&str, "    %s\n", dependency)
+		}
+	}
+	return str.String()
+}
+
+// Class, Type, Libc, Machine & OSABI are given by name (e.g. "ELF64", "DYNEXE", "glibc", "EM_X86_64") rather
+// than by value
+func (e Elf) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Name          string             `json:"name"`
+		Path          string             `json:"path"`
+		Class         EI_CLASS           `json:"class"`
+		Machine       string             `json:"machine"`
+		OSABI         string             `json:"osabi"`
+		Type          Type               `json:"type"`
+		Entry         uint64             `json:"entry"`
+		Interpreter   string             `json:"interpreter,omitempty"`
+		Libc          Libc               `json:"libc"`
+		Soname        string             `json:"soname,omitempty"`
+		Needed        []string           `json:"needed,omitempty"`
+		Filter        []string           `json:"filter,omitempty"`
+		Auxiliary     []string           `json:"auxiliary,omitempty"`
+		Audit         []string           `json:"audit,omitempty"`
+		DepAudit      []string           `json:"depAudit,omitempty"`
+		Dlopen        []DlopenDependency `json:"dlopen,omitempty"`
+		Rpath         []string           `json:"rpath,omitempty"`
+		Runpath       []string           `json:"runpath,omitempty"`
+		BuildID       string             `json:"buildId,omitempty"`
+		Stripped      bool               `json:"stripped"`
+		GlibcNeeded   string             `json:"glibcNeeded,omitempty"`
+		GlibcProvided string             `json:"glibcProvided,omitempty"`
+		MinKernel     string             `json:"minKernel,omitempty"`
+		ISALevel      int                `json:"isaLevel,omitempty"`
+		Dependencies  []string           `json:"dependencies"`
+		Libraries     []Library          `json:"libraries,omitempty"`
+	}{
+		e.Name, e.Path, e.Class, e.Machine.String(), e.OSABI.String(), e.Type, e.Entry, e.Interpreter, e.Libc,
+		e.Soname, e.Needed, e.Filter, e.Auxiliary, e.Audit, e.DepAudit, e.DlopenDependencies, e.Rpath, e.Runpath, e.BuildID, e.Stripped, e.GlibcNeeded, e.GlibcProvided, e.MinKernel,
+		e.ISALevel, e.Dependencies, e.Libraries,
+	})
+}
+
 // If both paths are absolute: compares only the filename, otherwise compares the entire path.
 func libpathcmp(path1 string, path2 string) int {
 	if filepath.IsAbs(path1) && filepath.IsAbs(path2) {
@@ -1,12 +1,20 @@
 package elf_test
 
 import (
+	"bytes"
+	debug_elf "debug/elf"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
+	"testing/fstest"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/MusicalNinjaDad/snaggle/elf"
 
+	. "github.com/MusicalNinjaDad/snaggle/internal"
 	. "github.com/MusicalNinjaDad/snaggle/internal/testing"
 )
 
@@ -25,3 +33,586 @@ func Test(t *testing.T) {
 		})
 	}
 }
+
+func TestStringAndJSON(t *testing.T) {
+	Assert := assert.New(t)
+	sysroot, err := filepath.Abs("testdata/musl/root")
+	Assert.NoError(err)
+	libgreet := filepath.Join(sysroot, "opt/musl/lib/libgreet.so")
+
+	parsed, err := elf.New("/usr/bin/hello_musl", elf.Sysroot(sysroot))
+	Assert.NoError(err)
+
+	expected := "" +
+		"Name:         hello_musl\n" +
+		"Path:         " + filepath.Join(sysroot, "usr/bin/hello_musl") + "\n" +
+		"Class:        ELF64\n" +
+		"Machine:      EM_X86_64\n" +
+		"OSABI:        ELFOSABI_NONE\n" +
+		"Type:         DYNEXE\n" +
+		"Entry:        0x1020\n" +
+		"Interpreter:  /lib/ld-musl-x86_64.so.1\n" +
+		"Libc:         musl\n" +
+		"Needed:       libgreet.so, libc.musl-x86_64.so.1, libpthread.so.0\n" +
+		"Stripped:     false\n" +
+		"Dependencies:\n" +
+		"    libgreet.so => " + libgreet + " (ld.so.conf)\n"
+	Assert.Equal(expected, parsed.String())
+
+	json, err := parsed.MarshalJSON()
+	Assert.NoError(err)
+	Assert.JSONEq(`{
+		"name": "hello_musl",
+		"path": "`+filepath.Join(sysroot, "usr/bin/hello_musl")+`",
+		"class": "ELF64",
+		"machine": "EM_X86_64",
+		"osabi": "ELFOSABI_NONE",
+		"type": "DYNEXE",
+		"entry": 4128,
+		"interpreter": "/lib/ld-musl-x86_64.so.1",
+		"libc": "musl",
+		"needed": ["libgreet.so", "libc.musl-x86_64.so.1", "libpthread.so.0"],
+		"stripped": false,
+		"dependencies": ["`+libgreet+`"],
+		"libraries": [{"soname": "libgreet.so", "path": "`+libgreet+`", "neededBy": "`+filepath.Join(sysroot, "usr/bin/hello_musl")+`", "source": "ld.so.conf"}]
+	}`, string(json))
+
+	Assert.Equal("STATICPIE", elf.Type(elf.STATICPIE).String())
+	Assert.Equal("Type(6)", elf.Type(6).String())
+	Assert.Equal("ELF32", elf.EI_CLASS(elf.ELF32).String())
+}
+
+func TestMetadata(t *testing.T) {
+	Assert := assert.New(t)
+	bin, err := filepath.Abs("testdata/rpath/bin/rpath")
+	Assert.NoError(err)
+	liba := filepath.Dir(bin) + "/../lib/liba.so"
+
+	parsed, err := elf.New(bin)
+	Assert.NoError(err)
+	Assert.Equal([]string{"$ORIGIN/../lib"}, parsed.Rpath)
+	Assert.Empty(parsed.Runpath)
+	Assert.Equal([]string{"liba.so", "libc.so.6"}, parsed.Needed)
+	Assert.Empty(parsed.Soname)
+	Assert.Len(parsed.BuildID, 40)
+
+	// also available for every node in a Graph
+	graph, err := elf.NewGraph(bin)
+	Assert.NoError(err)
+	Assert.Equal("liba.so", graph.Nodes[liba].Soname)
+	Assert.Equal([]string{"libb.so"}, graph.Nodes[liba].Needed)
+
+	other := parsed
+	other.Needed = []string{"libc.so.6", "liba.so"}
+	other.Entry = 0x10
+	Assert.Equal([]string{
+		"Entry differs for rpath: " + fmt.Sprintf("%#x", parsed.Entry) + " != 0x10",
+		"Needed differs for rpath: [liba.so libc.so.6] != [libc.so.6 liba.so]",
+	}, parsed.Diff(other))
+}
+
+func TestMalformedNotes(t *testing.T) {
+	Assert := assert.New(t)
+	bin := filepath.Join(WorkspaceTempDir(t), "hello")
+	Assert.NoError(Copy(P_hello_dynamic, bin))
+	original, err := elf.New(bin)
+	Assert.NoError(err)
+	Assert.NotEmpty(original.BuildID)
+	Assert.NotEmpty(original.MinKernel)
+
+	// descsz larger than the section
+	elffile, err := debug_elf.Open(bin)
+	Assert.NoError(err)
+	var offsets []int64
+	for _, name := range []string{".note.gnu.build-id", ".note.ABI-tag"} {
+		offsets = append(offsets, int64(elffile.Section(name).Offset)+4)
+	}
+	Assert.NoError(elffile.Close())
+	file, err := os.OpenFile(bin, os.O_WRONLY, 0)
+	Assert.NoError(err)
+	for _, offset := range offsets {
+		_, err = file.WriteAt([]byte{0xff, 0xff, 0xff, 0x0f}, offset)
+		Assert.NoError(err)
+	}
+	Assert.NoError(file.Close())
+
+	// informational, so does not stop the binary being parsed & resolved
+	parsed, err := elf.New(bin)
+	Assert.NoError(err)
+	Assert.Empty(parsed.BuildID)
+	Assert.Empty(parsed.MinKernel)
+	Assert.Equal(original.Dependencies, parsed.Dependencies)
+}
+
+func TestNoInterpreter(t *testing.T) {
+	for _, bin := range []string{"pie", "exec"} {
+		t.Run(bin, func(t *testing.T) {
+			Assert := assert.New(t)
+
+			// dynamically linked, so not a static-PIE, but cannot be run
+			parsed, err := elf.New(filepath.Join("testdata/nointerp/bin", bin))
+			Assert.ErrorIs(err, elf.ErrBadInterpreter)
+			Assert.Equal(elf.Type(elf.DYNEXE), parsed.Type)
+			Assert.Empty(parsed.Interpreter)
+			Assert.Equal([]string{"libc.so.6"}, parsed.Needed)
+		})
+	}
+}
+
+func TestCompat(t *testing.T) {
+	Assert := assert.New(t)
+	sysroot, err := filepath.Abs("testdata/compat/root")
+	Assert.NoError(err)
+
+	newer, err := elf.New("/bin/new", elf.Sysroot(sysroot))
+	Assert.NoError(err) // the interpreter is not bundled, but is not one of the Dependencies
+	Assert.Equal("GLIBC_2.17", newer.GlibcNeeded)
+	Assert.Empty(newer.GlibcProvided)
+	Assert.Equal("4.4.0", newer.MinKernel)
+	Assert.Equal(3, newer.ISALevel)
+	Assert.Equal("x86-64-v3", elf.ISALevelName(newer.ISALevel))
+
+	headers, err := elf.Headers("/bin/new", elf.Sysroot(sysroot)) // nothing is resolved
+	Assert.NoError(err)
+	Assert.Equal(filepath.Join(sysroot, "bin/new"), headers.Path)
+	Assert.Equal(newer.GlibcNeeded, headers.GlibcNeeded)
+	Assert.Equal(newer.MinKernel, headers.MinKernel)
+	Assert.Equal(newer.ISALevel, headers.ISALevel)
+	Assert.Empty(headers.Dependencies)
+
+	libc, err := elf.New("/lib64/libc.so.6", elf.Sysroot(sysroot))
+	Assert.NoError(err)
+	Assert.Empty(libc.GlibcNeeded)
+	Assert.Equal("GLIBC_2.2.5", libc.GlibcProvided)
+	Assert.Empty(libc.MinKernel)
+	Assert.Zero(libc.ISALevel)
+
+	Assert.Equal(1, elf.CompareGlibc("GLIBC_2.17", "GLIBC_2.2.5"))
+	Assert.Equal(-1, elf.CompareGlibc("GLIBC_2.3", "GLIBC_2.3.4"))
+	Assert.Equal(0, elf.CompareGlibc("GLIBC_2.34", "GLIBC_2.34"))
+	Assert.Equal(-1, elf.CompareGlibc("", "GLIBC_2.2.5"))
+	Assert.Equal(-1, elf.CompareGlibc("GLIBC_PRIVATE", "GLIBC_2.2.5"))
+	Assert.Equal(1, elf.CompareKernel("4.4.0", "3.2.0"))
+	Assert.Equal(1, elf.CompareKernel("3.10.0", "3.2.0"))
+	Assert.Equal(-1, elf.CompareKernel("", "3.2.0"))
+}
+
+func TestCrossCheck(t *testing.T) {
+	for _, details := range AllElfs() {
+		t.Run(details.Name, func(t *testing.T) {
+			Assert := assert.New(t)
+
+			parsed, err := elf.New(details.Elf.Path, elf.CrossCheck())
+
+			Assert.NoError(err)
+			Assert.Nil(parsed.Diff(details.Elf))
+		})
+	}
+}
+
+func TestI386(t *testing.T) {
+	Assert := assert.New(t)
+	sysroot, err := filepath.Abs("testdata/i386/root")
+	Assert.NoError(err)
+
+	parsed, err := elf.New("/usr/bin/hello32", elf.Sysroot(sysroot))
+	Assert.NoError(err)
+
+	Assert.Equal(elf.EI_CLASS(elf.ELF32), parsed.Class)
+	Assert.Equal(elf.Type(elf.DYNEXE), parsed.Type)
+	Assert.Equal(P_ld_linux_32, parsed.Interpreter)
+	// /lib/i386-linux-gnu/libthirtytwo.so is 64-bit
+	Assert.Equal([]string{filepath.Join(sysroot, "usr/lib/libthirtytwo.so")}, parsed.Dependencies)
+}
+
+func TestSysroot(t *testing.T) {
+	Assert := assert.New(t)
+	sysroot := BuildSysroot(t)
+
+	parsed, err := elf.New("/usr/bin/hello", elf.Sysroot(sysroot))
+	Assert.NoError(err)
+
+	Assert.Equal("hello", parsed.Name)
+	Assert.Equal(filepath.Join(sysroot, "opt/app/hello_dynamic"), parsed.Path)
+	Assert.Equal(P_ld_linux, parsed.Interpreter)
+	Assert.Equal([]string{filepath.Join(sysroot, "opt/sysroot-libs/libc.so.6")}, parsed.Dependencies)
+	Assert.Equal(elf.Source(elf.LDSOCONF), parsed.Libraries[0].Source)
+
+	_, err = elf.New("/usr/bin/hello", elf.Sysroot(sysroot), elf.CrossCheck())
+	Assert.ErrorIs(err, elf.ErrCrossCheck)
+	Assert.ErrorIs(err, errors.ErrUnsupported)
+}
+
+func TestPathname(t *testing.T) {
+	Assert := assert.New(t)
+	sysroot := BuildSysroot(t)
+	for src, dst := range map[string]string{
+		"testdata/pathneeded/bin/app":                     "opt/app/app",
+		"testdata/pathneeded/bin/plugins/libplugin.so":    "opt/app/plugins/libplugin.so",
+		"testdata/pathneeded/opt/vendor/lib/libvendor.so": "opt/vendor/lib/libvendor.so",
+	} {
+		Assert.NoError(os.MkdirAll(filepath.Dir(filepath.Join(sysroot, dst)), 0775))
+		Assert.NoError(Copy(src, filepath.Join(sysroot, dst)))
+	}
+	bin := filepath.Join(sysroot, "opt/app/app")
+
+	parsed, err := elf.New("/opt/app/app", elf.Sysroot(sysroot))
+	Assert.NoError(err)
+
+	// absolute pathnames are within the sysroot, relative pathnames are relative to the executable
+	expected := []elf.Library{
+		{Soname: "/opt/vendor/lib/libvendor.so", Path: filepath.Join(sysroot, "opt/vendor/lib/libvendor.so"), NeededBy: bin, Source: elf.PATHNAME},
+		{Soname: "plugins/libplugin.so", Path: filepath.Join(sysroot, "opt/app/plugins/libplugin.so"), NeededBy: bin, Source: elf.PATHNAME},
+	}
+	var libraries []elf.Library
+	for _, lib := range parsed.Libraries {
+		if lib.Soname != "libc.so.6" {
+			libraries = append(libraries, lib)
+		}
+	}
+	Assert.ElementsMatch(expected, libraries)
+}
+
+func TestPathnameCrossCheck(t *testing.T) {
+	Assert := assert.New(t)
+	bin, err := filepath.Abs("testdata/pathneeded/bin/relative")
+	Assert.NoError(err)
+
+	parsed, err := elf.New(bin, elf.CrossCheck())
+	Assert.NoError(err)
+	Assert.Contains(parsed.Dependencies, filepath.Join(filepath.Dir(bin), "plugins/libplugin.so"))
+
+	// the absolute pathname does not exist on the host
+	_, err = elf.New(filepath.Join(filepath.Dir(bin), "app"))
+	Assert.ErrorIs(err, elf.ErrMissingDependency)
+	Assert.ErrorContains(err, "/opt/vendor/lib/libvendor.so needed by "+filepath.Join(filepath.Dir(bin), "app"))
+}
+
+func TestMusl(t *testing.T) {
+	Assert := assert.New(t)
+	sysroot, err := filepath.Abs("testdata/musl/root")
+	Assert.NoError(err)
+
+	parsed, err := elf.New("/usr/bin/hello_musl", elf.Sysroot(sysroot))
+	Assert.NoError(err)
+
+	Assert.Equal(elf.Libc(elf.MUSL), parsed.Libc)
+	Assert.Equal("/lib/ld-musl-x86_64.so.1", parsed.Interpreter)
+	// libc.musl-x86_64.so.1 & libpthread.so.0 are provided by the interpreter, /lib is not in ld-musl-x86_64.path
+	Assert.Equal([]string{filepath.Join(sysroot, "opt/musl/lib/libgreet.so")}, parsed.Dependencies)
+	Assert.Equal(elf.Source(elf.LDSOCONF), parsed.Libraries[0].Source)
+
+	lib, err := elf.New("/opt/musl/lib/libgreet.so", elf.Sysroot(sysroot))
+	Assert.NoError(err)
+	Assert.Equal(elf.Libc(elf.MUSL), lib.Libc)
+	Assert.Empty(lib.Dependencies)
+}
+
+func TestExplain(t *testing.T) {
+	Assert := assert.New(t)
+	sysroot, err := filepath.Abs("testdata/musl/root")
+	Assert.NoError(err)
+
+	parsed, err := elf.New("/usr/bin/hello_musl", elf.Sysroot(sysroot), elf.Explain())
+	Assert.NoError(err)
+
+	// every directory in ld-musl-x86_64.path is searched, not only until the first match
+	expected := []elf.Candidate{
+		{Source: elf.LDSOCONF, SearchDir: "/opt/musl/lib", Path: filepath.Join(sysroot, "opt/musl/lib/libgreet.so"), Found: true},
+		{Source: elf.LDSOCONF, SearchDir: "/usr/local/lib", Path: filepath.Join(sysroot, "usr/local/lib/libgreet.so")},
+	}
+	Assert.Equal(expected, parsed.Libraries[0].Candidates)
+
+	// only recorded when asked for
+	parsed, err = elf.New("/usr/bin/hello_musl", elf.Sysroot(sysroot))
+	Assert.NoError(err)
+	Assert.Nil(parsed.Libraries[0].Candidates)
+}
+
+func TestTargetISA(t *testing.T) {
+	Assert := assert.New(t)
+	bin, err := filepath.Abs("testdata/hwcaps/bin/hw")
+	Assert.NoError(err)
+	libdir := filepath.Dir(bin) + "/../lib"
+
+	hw := func(opts ...elf.Option) elf.Library {
+		parsed, err := elf.New(bin, opts...)
+		Assert.NoError(err)
+		for _, lib := range parsed.Libraries {
+			if lib.Soname == "libhw.so" {
+				return lib
+			}
+		}
+		Assert.Fail("libhw.so not found", "%v", parsed.Libraries)
+		return elf.Library{}
+	}
+
+	// glibc-hwcaps are ignored by default: the baseline runs anywhere
+	Assert.Equal(libdir+"/libhw.so", hw().Path)
+	Assert.Equal(libdir+"/libhw.so", hw(elf.TargetISA(1)).Path)
+	Assert.Equal(libdir+"/glibc-hwcaps/x86-64-v2/libhw.so", hw(elf.TargetISA(2)).Path)
+	Assert.Equal("x86-64-v2", hw(elf.TargetISA(2)).HWCaps)
+
+	// the highest level available is used
+	lib := hw(elf.TargetISA(4), elf.Explain())
+	Assert.Equal(libdir+"/glibc-hwcaps/x86-64-v3/libhw.so", lib.Path)
+	Assert.Equal("x86-64-v3", lib.HWCaps)
+	Assert.Equal(elf.Source(elf.RUNPATH), lib.Source)
+	expected := []elf.Candidate{
+		{Source: elf.RUNPATH, SearchDir: "$ORIGIN/../lib", Owner: bin, Path: libdir + "/glibc-hwcaps/x86-64-v4/libhw.so"},
+		{Source: elf.RUNPATH, SearchDir: "$ORIGIN/../lib", Owner: bin, Path: libdir + "/glibc-hwcaps/x86-64-v3/libhw.so", Found: true},
+		{Source: elf.RUNPATH, SearchDir: "$ORIGIN/../lib", Owner: bin, Path: libdir + "/glibc-hwcaps/x86-64-v2/libhw.so", Found: true},
+		{Source: elf.RUNPATH, SearchDir: "$ORIGIN/../lib", Owner: bin, Path: libdir + "/libhw.so", Found: true},
+	}
+	Assert.Equal(expected, lib.Candidates[:len(expected)])
+}
+
+func TestDlopen(t *testing.T) {
+	Assert := assert.New(t)
+	bin, err := filepath.Abs("testdata/dlopen/bin/app")
+	Assert.NoError(err)
+	libdir := filepath.Dir(bin) + "/../lib"
+
+	parsed, err := elf.New(bin)
+	Assert.NoError(err)
+	Assert.Equal([]elf.DlopenDependency{
+		{Sonames: []string{"libmissing-alt.so.1", "libplugin-req.so"}, Feature: "req", Description: "Required plugin", Priority: elf.REQUIRED},
+		{Sonames: []string{"libplugin-rec.so"}, Feature: "rec", Priority: elf.RECOMMENDED}, // the default
+		{Sonames: []string{"libplugin-sug.so"}, Feature: "sug", Priority: elf.SUGGESTED},
+	}, parsed.DlopenDependencies)
+	Assert.Equal([]string{P_libc}, parsed.Dependencies) // invisible to ldd
+
+	// the first alternative which can be found, and anything it needs, with at least the priority
+	parsed, err = elf.New(bin, elf.Dlopen(elf.REQUIRED))
+	Assert.NoError(err)
+	Assert.Equal([]string{P_libc, libdir + "/libplugin-dep.so", libdir + "/libplugin-req.so"}, parsed.Dependencies)
+	for _, lib := range parsed.Libraries {
+		Assert.Equal(lib.Path != P_libc, lib.Dlopen, lib.Path)
+	}
+	_, err = elf.New(bin, elf.Dlopen(elf.REQUIRED), elf.CrossCheck()) // only what ldd loads is compared
+	Assert.NoError(err)
+
+	parsed, err = elf.New(bin, elf.Dlopen(elf.RECOMMENDED))
+	Assert.NoError(err)
+	Assert.Contains(parsed.Dependencies, libdir+"/libplugin-rec.so")
+	Assert.NotContains(parsed.Dependencies, libdir+"/libplugin-sug.so")
+
+	graph, err := elf.NewGraph(bin, elf.Dlopen(elf.SUGGESTED))
+	Assert.NoError(err)
+	Assert.Contains(graph.Root.Dependencies, libdir+"/libplugin-sug.so")
+	Assert.Contains(graph.Edges, elf.Edge{From: bin, To: libdir + "/libplugin-req.so", Soname: "libplugin-req.so", Dlopen: true})
+	Assert.Contains(graph.Edges, elf.Edge{From: libdir + "/libplugin-req.so", To: libdir + "/libplugin-dep.so", Soname: "libplugin-dep.so", Dlopen: true})
+	Assert.NotContains(graph.Needs(bin), elf.Edge{From: bin, Soname: "libmissing-alt.so.1", Dlopen: true})
+
+	// only required dependencies are missing
+	broken, err := filepath.Abs("testdata/dlopen/bin/broken")
+	Assert.NoError(err)
+	_, err = elf.New(broken, elf.Dlopen(elf.RECOMMENDED))
+	Assert.ErrorIs(err, elf.ErrMissingDependency)
+	Assert.ErrorContains(err, "libplugin-gone.so")
+
+	// guessed from .rodata, whatever the priority
+	scan, err := filepath.Abs("testdata/dlopen/bin/scan")
+	Assert.NoError(err)
+	parsed, err = elf.New(scan)
+	Assert.NoError(err)
+	Assert.Nil(parsed.DlopenDependencies)
+	parsed, err = elf.New(scan, elf.ScanRodata(), elf.Dlopen(elf.REQUIRED))
+	Assert.NoError(err)
+	Assert.Equal([]elf.DlopenDependency{{Sonames: []string{"libplugin-rodata.so"}, Guessed: true}}, parsed.DlopenDependencies)
+	Assert.Contains(parsed.Dependencies, libdir+"/libplugin-rodata.so")
+	parsed, err = elf.New(scan, elf.ScanRodata()) // nothing is guessed unless resolving
+	Assert.NoError(err)
+	Assert.Nil(parsed.DlopenDependencies)
+}
+
+func TestMalformedDlopen(t *testing.T) {
+	Assert := assert.New(t)
+	src, err := filepath.Abs("testdata/dlopen/bin/app")
+	Assert.NoError(err)
+	bin := filepath.Join(WorkspaceTempDir(t), "app")
+	Assert.NoError(Copy(src, bin))
+
+	// the JSON follows the 12-byte header & "FDO\x00"
+	elffile, err := debug_elf.Open(bin)
+	Assert.NoError(err)
+	offset := int64(elffile.Section(".note.dlopen").Offset) + 16
+	Assert.NoError(elffile.Close())
+	file, err := os.OpenFile(bin, os.O_WRONLY, 0)
+	Assert.NoError(err)
+	_, err = file.WriteAt([]byte("X"), offset)
+	Assert.NoError(err)
+	Assert.NoError(file.Close())
+
+	// informational, unless resolving dlopen dependencies
+	parsed, err := elf.New(bin)
+	Assert.NoError(err)
+	Assert.Empty(parsed.DlopenDependencies)
+	Assert.Equal([]string{P_libc}, parsed.Dependencies)
+	_, err = elf.NewGraph(bin)
+	Assert.NoError(err)
+
+	_, err = elf.New(bin, elf.Dlopen(elf.SUGGESTED))
+	Assert.ErrorIs(err, elf.ErrInvalidElf)
+	Assert.ErrorContains(err, "malformed .note.dlopen")
+}
+
+func TestFilter(t *testing.T) {
+	Assert := assert.New(t)
+	bin, err := filepath.Abs("testdata/filter/bin/filtered")
+	Assert.NoError(err)
+	libdir := filepath.Dir(bin) + "/../lib"
+
+	filter, err := elf.New("testdata/filter/lib/libfilter.so")
+	Assert.NoError(err)
+	Assert.Equal([]string{"libfiltee.so"}, filter.Filter)
+	Assert.Equal([]string{"libaux.so", "libaux-missing.so"}, filter.Auxiliary)
+
+	// a missing DT_AUXILIARY or DT_DEPAUDIT is ignored, ldd does not list audit libraries
+	parsed, err := elf.New(bin, elf.CrossCheck())
+	Assert.NoError(err)
+	Assert.Equal([]string{"libaudit.so"}, parsed.Audit)
+	Assert.Equal([]string{"libaudit-missing.so"}, parsed.DepAudit)
+	via := make(map[string]elf.Via)
+	for _, lib := range parsed.Libraries {
+		via[lib.Path] = lib.Via
+	}
+	Assert.Equal(map[string]elf.Via{
+		libdir + "/libaudit.so":  elf.AUDIT,
+		libdir + "/libaux.so":    elf.AUXILIARY,
+		P_libc:                   elf.NEEDED,
+		libdir + "/libfiltee.so": elf.FILTER,
+		libdir + "/libfilter.so": elf.NEEDED,
+	}, via)
+}
+
+func TestPreload(t *testing.T) {
+	Assert := assert.New(t)
+	sysroot := BuildSysroot(t)
+	for _, lib := range []string{"libpreload.so", "libaux.so"} {
+		Assert.NoError(Copy(filepath.Join("testdata/filter/lib", lib), filepath.Join(sysroot, "opt/sysroot-libs", lib)))
+	}
+	// $LIB is the directory containing the interpreter
+	preload := "/$LIB/libpreload.so libpreload-missing.so\n"
+	Assert.NoError(os.WriteFile(filepath.Join(sysroot, "etc/ld.so.preload"), []byte(preload), 0664))
+
+	graph, err := elf.NewGraph("/usr/bin/hello", elf.Sysroot(sysroot))
+	Assert.NoError(err) // ld.so ignores anything which cannot be preloaded
+	libdir := filepath.Join(sysroot, "opt/sysroot-libs")
+	Assert.Equal([]string{libdir + "/libaux.so", libdir + "/libc.so.6", libdir + "/libpreload.so"}, graph.Root.Dependencies)
+	Assert.Equal(elf.Edge{From: graph.Root.Path, To: libdir + "/libpreload.so", Soname: "/opt/sysroot-libs/libpreload.so", Via: elf.PRELOAD}, graph.Edges[0])
+
+	// also separated by colons
+	Assert.NoError(os.WriteFile(filepath.Join(sysroot, "etc/ld.so.preload"), []byte("/$LIB/libpreload.so:libpreload-missing.so"), 0664))
+	graph, err = elf.NewGraph("/usr/bin/hello", elf.Sysroot(sysroot))
+	Assert.NoError(err)
+	Assert.Equal([]string{libdir + "/libaux.so", libdir + "/libc.so.6", libdir + "/libpreload.so"}, graph.Root.Dependencies)
+	Assert.Equal(elf.Edge{From: graph.Root.Path, To: libdir + "/libpreload.so", Soname: "/opt/sysroot-libs/libpreload.so", Via: elf.PRELOAD}, graph.Edges[0])
+}
+
+func TestFromFS(t *testing.T) {
+	Assert := assert.New(t)
+
+	parsed, err := elf.NewFromFS(os.DirFS("testdata/musl/root"), "/usr/bin/hello_musl")
+	Assert.NoError(err)
+	Assert.Equal("/usr/bin/hello_musl", parsed.Path)
+	Assert.Equal([]string{"/opt/musl/lib/libgreet.so"}, parsed.Dependencies)
+	Assert.Equal(elf.Source(elf.LDSOCONF), parsed.Libraries[0].Source)
+
+	// nothing is read from the host, so libc cannot be found
+	fsys := fstest.MapFS{}
+	for _, file := range []string{"bin/rpath", "lib/liba.so", "lib/libb.so"} {
+		data, err := os.ReadFile(filepath.Join("testdata/rpath", file))
+		Assert.NoError(err)
+		fsys[file] = &fstest.MapFile{Data: data}
+	}
+	parsed, err = elf.NewFromFS(fsys, "bin/rpath")
+	Assert.ErrorIs(err, elf.ErrMissingDependency)
+	Assert.ErrorContains(err, "libc.so.6 needed by /bin/rpath")
+	Assert.Equal([]string{"/bin/../lib/liba.so", "/bin/../lib/libb.so"}, parsed.Dependencies)
+
+	_, err = elf.NewFromFS(fsys, "bin/rpath", elf.Sysroot("testdata/rpath"))
+	Assert.ErrorIs(err, errors.ErrUnsupported)
+}
+
+func TestFromReader(t *testing.T) {
+	Assert := assert.New(t)
+	bin, err := filepath.Abs("testdata/rpath/bin/rpath")
+	Assert.NoError(err)
+	data, err := os.ReadFile(bin)
+	Assert.NoError(err)
+
+	expected, err := elf.New(bin)
+	Assert.NoError(err)
+	parsed, err := elf.NewFromReader(bytes.NewReader(data), bin)
+	Assert.NoError(err)
+	Assert.Nil(parsed.Diff(expected))
+
+	// path does not need to exist, and is within any Sysroot
+	sysroot, err := filepath.Abs("testdata/musl/root")
+	Assert.NoError(err)
+	data, err = os.ReadFile(filepath.Join(sysroot, "usr/bin/hello_musl"))
+	Assert.NoError(err)
+	parsed, err = elf.NewFromReader(bytes.NewReader(data), "/usr/local/bin/hello", elf.Sysroot(sysroot))
+	Assert.NoError(err)
+	Assert.Equal(filepath.Join(sysroot, "usr/local/bin/hello"), parsed.Path)
+	Assert.Equal([]string{filepath.Join(sysroot, "opt/musl/lib/libgreet.so")}, parsed.Dependencies)
+
+	_, err = elf.NewFromReader(bytes.NewReader(data), "/usr/local/bin/hello", elf.CrossCheck())
+	Assert.ErrorIs(err, elf.ErrCrossCheck)
+	_, err = elf.NewFromReader(bytes.NewReader([]byte("#!/bin/sh\n")), "script")
+	Assert.ErrorIs(err, elf.ErrInvalidElf)
+}
+
+func TestMuslDefaultDirs(t *testing.T) {
+	Assert := assert.New(t)
+	sysroot := t.TempDir()
+	Assert.NoError(os.CopyFS(sysroot, os.DirFS("testdata/musl/root")))
+	Assert.NoError(os.Remove(filepath.Join(sysroot, "etc/ld-musl-x86_64.path")))
+
+	parsed, err := elf.New("/usr/bin/hello_musl", elf.Sysroot(sysroot))
+	Assert.NoError(err)
+	Assert.Equal([]string{filepath.Join(sysroot, "lib/libgreet.so")}, parsed.Dependencies)
+	Assert.Equal(elf.Source(elf.DEFAULTDIRS), parsed.Libraries[0].Source)
+}
+
+func TestForeignArch(t *testing.T) {
+	tests := map[string]struct {
+		machine     debug_elf.Machine
+		interpreter string
+		libDir      string // in root
+	}{
+		"aarch64": {debug_elf.EM_AARCH64, "/lib/ld-linux-aarch64.so.1", "/usr/lib/aarch64-linux-gnu"},
+		"riscv64": {debug_elf.EM_RISCV, "/lib/ld-linux-riscv64-lp64d.so.1", "/usr/lib64/lp64d"},
+		"ppc64le": {debug_elf.EM_PPC64, "/lib64/ld64.so.2", "/usr/lib64"},
+	}
+	for arch, tc := range tests {
+		t.Run(arch, func(t *testing.T) {
+			Assert := assert.New(t)
+			sysroot, err := filepath.Abs(filepath.Join("testdata/foreign", arch, "root"))
+			Assert.NoError(err)
+
+			parsed, err := elf.New("/usr/bin/hello", elf.Sysroot(sysroot))
+			Assert.NoError(err)
+
+			Assert.Equal(tc.machine, parsed.Machine)
+			Assert.Equal(tc.interpreter, parsed.Interpreter)
+			Assert.Equal(elf.Libc(elf.GLIBC), parsed.Libc)
+			// aarch64 has an x86_64 decoy in /lib64, which is searched first
+			Assert.Equal(sysroot+tc.libDir+"/libgreet.so", parsed.Libraries[1].Path)
+			Assert.Equal("libc.so.6", parsed.Libraries[0].Soname)
+			Assert.Equal(sysroot+tc.libDir+"/libgreet.so", parsed.Libraries[0].NeededBy)
+		})
+	}
+}
+
+func TestForeignArchCrossCheck(t *testing.T) {
+	Assert := assert.New(t)
+	libc, err := filepath.Abs("testdata/foreign/aarch64/root/lib/aarch64-linux-gnu/libc.so.6")
+	Assert.NoError(err)
+
+	parsed, err := elf.New(libc, elf.CrossCheck())
+	Assert.ErrorIs(err, elf.ErrCrossCheck)
+	Assert.ErrorIs(err, errors.ErrUnsupported)
+	Assert.Equal(debug_elf.EM_AARCH64, parsed.Machine)
+}
@@ -1,6 +1,9 @@
 package elf
 
 import (
+	"io/fs"
+	"os"
+	"path/filepath"
 	"slices"
 	"testing"
 
@@ -33,6 +36,179 @@ func TestLdd_nested(t *testing.T) {
 	AssertDependenciesEqual(t, expectedDependencies, dependencies)
 }
 
+func TestLibraryPath(t *testing.T) {
+	Assert := assert.New(t)
+	tmp := t.TempDir()
+	libc := filepath.Join(tmp, "libc.so.6")
+	Assert.NoError(Copy(P_libc, libc))
+
+	parsed, err := New(P_which, LibraryPath(tmp))
+	Assert.NoError(err)
+	Assert.Equal([]string{libc}, parsed.Dependencies)
+
+	_, err = New(P_which, LibraryPath(tmp), CrossCheck())
+	Assert.ErrorIs(err, ErrCrossCheck)
+}
+
+func TestLdSoCache(t *testing.T) {
+	Assert := assert.New(t)
+	parsed, err := New(P_which)
+	Assert.NoError(err)
+	Assert.Equal(Source(LDSOCACHE), parsed.Libraries[0].Source)
+}
+
+func TestIncompatibleLibrary(t *testing.T) {
+	Assert := assert.New(t)
+	tmp := t.TempDir()
+	Assert.NoError(Copy(P_ldd, filepath.Join(tmp, "libc.so.6")))
+
+	parsed, err := New(P_which, LibraryPath(tmp))
+	Assert.NoError(err)
+	Assert.Equal([]string{P_libc}, parsed.Dependencies)
+}
+
+func TestLdSoConf(t *testing.T) {
+	Assert := assert.New(t)
+	tmp := t.TempDir()
+	confd := filepath.Join(tmp, "ld.so.conf.d")
+	Assert.NoError(os.Mkdir(confd, 0775))
+
+	conf := "include ld.so.conf.d/*.conf\n"
+	conf += "# a comment\n"
+	conf += "\n"
+	conf += "/opt/lib # trailing comment\n"
+	conf += "include " + filepath.Join(tmp, "ld.so.conf") + "\n" // loop
+	Assert.NoError(os.WriteFile(filepath.Join(tmp, "ld.so.conf"), []byte(conf), 0664))
+	Assert.NoError(os.WriteFile(filepath.Join(confd, "b.conf"), []byte("/usr/local/lib\n"), 0664))
+	Assert.NoError(os.WriteFile(filepath.Join(confd, "a.conf"), []byte("/lib/custom=libc6\n"), 0664))
+
+	expected := []string{"/lib/custom", "/usr/local/lib", "/opt/lib"}
+	Assert.Equal(expected, ldSoConf(filesystem{}, filepath.Join(tmp, "ld.so.conf")))
+}
+
+func TestRpath(t *testing.T) {
+	Assert := assert.New(t)
+	bin, err := filepath.Abs("testdata/rpath/bin/rpath")
+	Assert.NoError(err)
+	libdir := filepath.Dir(bin) + "/../lib"
+
+	parsed, err := New(bin, CrossCheck())
+	Assert.NoError(err)
+
+	// DT_RPATH of the executable is also used for libraries which it did not request directly
+	expected := []Library{
+		{Soname: "liba.so", Path: libdir + "/liba.so", NeededBy: bin, Source: RPATH, SearchDir: "$ORIGIN/../lib", Owner: bin},
+		{Soname: "libb.so", Path: libdir + "/libb.so", NeededBy: libdir + "/liba.so", Source: RPATH, SearchDir: "$ORIGIN/../lib", Owner: bin},
+	}
+	var libraries []Library
+	for _, lib := range parsed.Libraries {
+		if lib.Soname != "libc.so.6" {
+			libraries = append(libraries, lib)
+		}
+	}
+	Assert.Equal(expected, libraries)
+}
+
+func TestRunpathNotTransitive(t *testing.T) {
+	Assert := assert.New(t)
+	bin, err := filepath.Abs("testdata/rpath/bin/runpath")
+	Assert.NoError(err)
+
+	_, err = New(bin)
+	Assert.ErrorIs(err, fs.ErrNotExist)
+	Assert.ErrorContains(err, "libb.so needed by "+filepath.Dir(bin)+"/../lib/liba.so")
+}
+
+func TestMissingDependencies(t *testing.T) {
+	Assert := assert.New(t)
+	bin, err := filepath.Abs("testdata/rpath/bin/missing")
+	Assert.NoError(err)
+	libdir := filepath.Dir(bin) + "/../lib"
+
+	_, err = New(bin)
+	Assert.ErrorIs(err, ErrMissingDependency)
+	Assert.ErrorIs(err, fs.ErrNotExist)
+
+	// every missing dependency is reported, not just the first
+	expected := []Library{
+		{Soname: "libmissing1.so", NeededBy: bin},
+		{Soname: "libmissing2.so", NeededBy: bin},
+		{Soname: "libb.so", NeededBy: libdir + "/liba.so"},
+	}
+	var missing *MissingDependencyError
+	if Assert.ErrorAs(err, &missing) {
+		Assert.Equal(expected, missing.Missing)
+	}
+}
+
+func TestParseLdd(t *testing.T) {
+	Assert := assert.New(t)
+	glibc := `	linux-vdso.so.1 (0x00007f846ca17000)
+	liba.so => /opt/lib/liba.so (0x00007f846ca05000)
+	libmissing.so => not found
+	plugins/libplugin.so (0x00007f6bcb986000)
+	libc.so.6 => /lib64/libc.so.6 (0x00007f846c81b000)
+	/lib64/ld-linux-x86-64.so.2 (0x00007f846ca19000)
+`
+	dependencies, err := parseLdd("/bin/app", "/lib64/ld-linux-x86-64.so.2", glibc)
+	Assert.Equal([]string{"/opt/lib/liba.so", "/lib64/libc.so.6", "/bin/plugins/libplugin.so"}, dependencies)
+	Assert.ErrorIs(err, ErrMissingDependency)
+	Assert.EqualError(err, "missing dependency: libmissing.so needed by /bin/app")
+
+	musl := `	/lib/ld-musl-x86_64.so.1 (0x7f0c1e6a8000)
+	libgreet.so => /opt/musl/lib/libgreet.so (0x7f0c1e69e000)
+	libc.musl-x86_64.so.1 => /lib/ld-musl-x86_64.so.1 (0x7f0c1e6a8000)
+`
+	dependencies, err = parseLdd("/bin/app", "/lib/ld-musl-x86_64.so.1", musl)
+	Assert.NoError(err)
+	Assert.Equal([]string{"/opt/musl/lib/libgreet.so"}, dependencies)
+}
+
+func TestExpand(t *testing.T) {
+	r := resolver{lib: "lib64", platform: "x86_64"}
+	tests := map[string]string{
+		"/usr/lib":                 "/usr/lib",
+		"$ORIGIN":                  "/opt/app/bin",
+		"${ORIGIN}/../lib":         "/opt/app/bin/../lib",
+		"$ORIGIN/../$LIB":          "/opt/app/bin/../lib64",
+		"/opt/${LIB}/$PLATFORM":    "/opt/lib64/x86_64",
+		"/opt/$PLATFORM/${ORIGIN}": "/opt/x86_64//opt/app/bin",
+	}
+	for dir, expected := range tests {
+		assert.Equal(t, expected, r.expand(dir, "/opt/app/bin"), dir)
+	}
+}
+
+func TestExpandMusl(t *testing.T) {
+	r := resolver{lib: "lib", platform: "x86_64", libc: MUSL}
+	assert.Equal(t, "/opt/app/bin/../$LIB/$PLATFORM", r.expand("$ORIGIN/../$LIB/$PLATFORM", "/opt/app/bin"))
+}
+
+func TestMuslPath(t *testing.T) {
+	Assert := assert.New(t)
+	root := t.TempDir()
+
+	dirs, found := muslPath(filesystem{root: root}, "/lib/ld-musl-x86_64.so.1")
+	Assert.False(found)
+	Assert.Nil(dirs)
+
+	Assert.NoError(os.MkdirAll(filepath.Join(root, "usr/local/musl/etc"), 0775))
+	path := filepath.Join(root, "usr/local/musl/etc/ld-musl-aarch64.path")
+	Assert.NoError(os.WriteFile(path, []byte("/opt/lib:/usr/lib\n/lib\n"), 0664))
+	dirs, found = muslPath(filesystem{root: root}, "/usr/local/musl/lib/ld-musl-aarch64.so.1")
+	Assert.True(found)
+	Assert.Equal([]string{"/opt/lib", "/usr/lib", "/lib"}, dirs)
+}
+
+func TestMuslReserved(t *testing.T) {
+	for _, soname := range []string{"libc.musl-x86_64.so.1", "libc.so", "libpthread.so.0", "libm.so.6", "libdl.so.2"} {
+		assert.True(t, isMuslReserved(soname), soname)
+	}
+	for _, soname := range []string{"libcrypto.so.3", "libmagic.so.1", "libgreet.so"} {
+		assert.False(t, isMuslReserved(soname), soname)
+	}
+}
+
 func TestLibpathcmp(t *testing.T) {
 	fedora := "/lib64/libc.so.6"
 	ubuntu := "/lib64/x86_64-linux-gnu/libc.so.6"
@@ -0,0 +1,96 @@
+package elf
+
+import (
+	"bytes"
+	debug_elf "debug/elf"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/MusicalNinjaDad/snaggle/internal"
+)
+
+// Where everything is read from: the host, a [Sysroot] on the host, or an [fs.FS] (see [NewFromFS]).
+//
+// Unless stated otherwise, paths passed to & returned by the methods are "host paths": on the host (i.e.
+// including any sysroot). There is no host for an fs.FS, so host paths are absolute within it,
+// e.g. "/usr/lib/libc.so.6".
+type filesystem struct {
+	root string // the sysroot, "" for the host or an fs.FS
+	fsys fs.FS  // nil unless reading from an fs.FS
+}
+
+// The path on the host to path within the root, without following any symlinks
+func (f filesystem) host(path string) string {
+	return f.root + path
+}
+
+// The path within the root to host path, the inverse of [filesystem.host]
+func (f filesystem) inRoot(path string) string {
+	return internal.InRoot(f.root, path)
+}
+
+// Resolves every symlink in path (within the root), returning the host path
+func (f filesystem) evalSymlinks(path string) (string, error) {
+	if f.fsys != nil {
+		return internal.EvalSymlinksInFS(f.fsys, path)
+	}
+	return internal.EvalSymlinksIn(f.root, path)
+}
+
+// Opens the ELF at host path. Files in an fs.FS are read into memory.
+func (f filesystem) openElf(path string) (*debug_elf.File, error) {
+	if f.fsys == nil {
+		return debug_elf.Open(path)
+	}
+	data, err := fs.ReadFile(f.fsys, internal.FSPath(path))
+	if err != nil {
+		return nil, err
+	}
+	return debug_elf.NewFile(bytes.NewReader(data))
+}
+
+// The contents of the file at host path
+func (f filesystem) readFile(path string) ([]byte, error) {
+	if f.fsys != nil {
+		return fs.ReadFile(f.fsys, internal.FSPath(path))
+	}
+	return os.ReadFile(path)
+}
+
+// Stats the file at host path
+func (f filesystem) stat(path string) (fs.FileInfo, error) {
+	if f.fsys != nil {
+		return fs.Stat(f.fsys, internal.FSPath(path))
+	}
+	return os.Stat(path)
+}
+
+// Every path (within the root) matching pattern (within the root)
+func (f filesystem) glob(pattern string) []string {
+	var matches []string
+	if f.fsys != nil {
+		matches, _ = fs.Glob(f.fsys, internal.FSPath(pattern)) // only possible returned error is ErrBadPattern
+		for idx, match := range matches {
+			matches[idx] = "/" + match
+		}
+		return matches
+	}
+	matches, _ = filepath.Glob(f.root + pattern) // only possible returned error is ErrBadPattern
+	for idx, match := range matches {
+		matches[idx] = internal.InRoot(f.root, match)
+	}
+	return matches
+}
+
+// The parsed `/etc/ld.so.cache`
+func (f filesystem) cache() (*LdSoCache, error) {
+	if f.fsys == nil {
+		return OpenCache(rootOrHost(f.root))
+	}
+	data, err := f.readFile(p_ld_so_cache)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCache(data)
+}
@@ -0,0 +1,213 @@
+package elf
+
+import (
+	"path/filepath"
+)
+
+// The full dependency tree of an ELF: every object which ld.so would load and which `DT_NEEDED` entry
+// caused each to be loaded.
+//
+// Unlike [Elf.Dependencies], which is a flat list sorted by filename, a Graph keeps the relationship between
+// each object and the libraries it requested. Use [Graph.LoadOrder] for the order in which they are loaded.
+type Graph struct {
+	// The object the graph was built for, exactly as returned by [New]
+	Root *Elf
+	// The interpreter (PT_INTERP) of Root, nil if Root has no interpreter
+	Interpreter *Elf
+	// Every object in the graph (including Root & Interpreter), by the Path it is loaded from.
+	//
+	// Dependencies & Libraries of each contain everything loaded because of it, located as they would
+	// be when loading Root.
+	Nodes map[string]*Elf
+	// Every `DT_NEEDED` entry, breadth-first in the same order as ld.so processes them
+	Edges []Edge
+
+	files filesystem // where Nodes were read from
+}
+
+// A `DT_NEEDED` entry in a [Graph]
+type Edge struct {
+	// Path of the object containing the `DT_NEEDED` entry
+	From string
+	// Path of the object which satisfied it, "" if it could not be found
+	To string
+	// The requested soname (or pathname), as listed in `DT_NEEDED`
+	Soname string
+	// How it was requested, if not `DT_NEEDED`
+	Via Via
+	// From `.note.dlopen` (or Guessed), or `DT_NEEDED` by a library loaded via `dlopen()`. See [Dlopen].
+	Dlopen bool
+}
+
+// Construct the [Graph] of all dependencies of the file located at path, any error will be an [ErrElf]
+//
+//   - Accepts the same Options as [New] & returns a best-effort result on error
+//   - Any dependencies which cannot be found are included in Edges (with To == "") and returned as a
+//     [MissingDependencyError]
+func NewGraph(path string, opts ...Option) (*Graph, error) {
+	root, edges, options, err := parse(path, opts)
+	g := &Graph{
+		Root:  &root,
+		Nodes: map[string]*Elf{root.Path: &root},
+		Edges: edges,
+		files: options.filesystem(),
+	}
+	if root.Class == EI_CLASS(ELFNONE) {
+		return g, err // could not even open root
+	}
+
+	reterr, ok := err.(*ErrElf) // add any errors from the nodes to those from root
+	if !ok {
+		reterr = &ErrElf{path: path}
+	}
+
+	for _, edge := range edges {
+		if _, exists := g.Nodes[edge.To]; exists || edge.To == "" {
+			continue
+		}
+		node, err := newNode(options.filesystem(), edge.To, root.Libc, options.guessDlopen())
+		reterr.Join(err)
+		g.Nodes[edge.To] = node
+	}
+
+	if root.Interpreter != "" {
+		interpreter := options.filesystem().host(root.Interpreter) // the same path as used by the resolver
+		if _, exists := g.Nodes[interpreter]; !exists {
+			node, err := newNode(options.filesystem(), interpreter, root.Libc, options.guessDlopen())
+			reterr.Join(err)
+			g.Nodes[interpreter] = node
+		}
+		g.Interpreter = g.Nodes[interpreter]
+	}
+
+	needs := g.adjacency()
+	for path, node := range g.Nodes {
+		if node == g.Root {
+			continue
+		}
+		reachable := reachable(path, needs)
+		for _, lib := range root.Libraries {
+			if reachable[lib.Path] {
+				node.Dependencies = append(node.Dependencies, lib.Path)
+				node.Libraries = append(node.Libraries, lib)
+			}
+		}
+	}
+
+	if reterr.IsError() {
+		return g, reterr
+	}
+	return g, nil
+}
+
+// A node containing only the details available from the ELF headers of the file at (host) path; and its
+// DlopenDependencies, scanning `.rodata` if scanRodata
+func newNode(files filesystem, path string, libc Libc, scanRodata bool) (*Elf, error) {
+	node := &Elf{Name: filepath.Base(path), Path: path, Libc: libc}
+	reterr := &ErrElf{path: path}
+
+	resolved, err := files.evalSymlinks(files.inRoot(path))
+	if err != nil {
+		reterr.Join(err)
+		return node, reterr
+	}
+	elffile, err := files.openElf(resolved)
+	if err != nil {
+		reterr.Join(err)
+		return node, reterr
+	}
+	defer func() {
+		reterr.Join(
+			elffile.Close(),
+		)
+	}()
+
+	node.Class = EI_CLASS(elffile.Class)
+	node.Machine = elffile.Machine
+
+	metadata(node, elffile)
+
+	node.DlopenDependencies, _ = dlopenDependencies(elffile, scanRodata) // any error is reported by the resolver
+
+	node.Interpreter, err = interpreter(elffile)
+	reterr.Join(err)
+
+	node.Type, err = elftype(elffile)
+	reterr.Join(err)
+
+	if reterr.IsError() {
+		return node, reterr
+	}
+	return node, nil
+}
+
+// The `DT_NEEDED` entries of the object at path, in the order they are listed
+func (g *Graph) Needs(path string) []Edge {
+	var edges []Edge
+	for _, edge := range g.Edges {
+		if edge.From == path {
+			edges = append(edges, edge)
+		}
+	}
+	return edges
+}
+
+// The `DT_NEEDED` entries, from any object, which were satisfied by the object at path
+func (g *Graph) NeededBy(path string) []Edge {
+	var edges []Edge
+	for _, edge := range g.Edges {
+		if edge.To == path {
+			edges = append(edges, edge)
+		}
+	}
+	return edges
+}
+
+// Every object in the order ld.so adds it to the link map, which is also the order in which symbols are
+// looked up: Root; then each library, breadth-first; and finally the Interpreter, which is already loaded.
+func (g *Graph) LoadOrder() []*Elf {
+	order := []*Elf{g.Root}
+	added := map[*Elf]bool{g.Root: true}
+	for _, edge := range g.Edges {
+		node, found := g.Nodes[edge.To]
+		if !found || node == g.Interpreter || added[node] {
+			continue
+		}
+		added[node] = true
+		order = append(order, node)
+	}
+	if g.Interpreter != nil {
+		order = append(order, g.Interpreter)
+	}
+	return order
+}
+
+// The paths of the objects found for the `DT_NEEDED` entries of each object, by its path
+func (g *Graph) adjacency() map[string][]string {
+	needs := make(map[string][]string)
+	for _, edge := range g.Edges {
+		if edge.To != "" {
+			needs[edge.From] = append(needs[edge.From], edge.To)
+		}
+	}
+	return needs
+}
+
+// Every object loaded because of from (directly or indirectly), given the adjacency of the graph
+func reachable(from string, needs map[string][]string) map[string]bool {
+	seen := map[string]bool{from: true}
+	reached := make(map[string]bool)
+	queue := []string{from}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, to := range needs[current] {
+			reached[to] = true
+			if !seen[to] {
+				seen[to] = true
+				queue = append(queue, to)
+			}
+		}
+	}
+	return reached
+}
@@ -0,0 +1,115 @@
+package elf_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/MusicalNinjaDad/snaggle/elf"
+
+	. "github.com/MusicalNinjaDad/snaggle/internal"
+	. "github.com/MusicalNinjaDad/snaggle/internal/testing"
+)
+
+func names(nodes []*elf.Elf) []string {
+	var names []string
+	for _, node := range nodes {
+		names = append(names, node.Name)
+	}
+	return names
+}
+
+func sonames(edges []elf.Edge) []string {
+	var sonames []string
+	for _, edge := range edges {
+		sonames = append(sonames, edge.Soname)
+	}
+	return sonames
+}
+
+func TestGraph(t *testing.T) {
+	Assert := assert.New(t)
+	bin, err := filepath.Abs("testdata/rpath/bin/rpath")
+	Assert.NoError(err)
+	liba := filepath.Dir(bin) + "/../lib/liba.so"
+	libb := filepath.Dir(bin) + "/../lib/libb.so"
+
+	graph, err := elf.NewGraph(bin)
+	Assert.NoError(err)
+
+	parsed, err := elf.New(bin)
+	Assert.NoError(err)
+	Assert.Nil(graph.Root.Diff(parsed))
+
+	Assert.Equal([]string{"liba.so", "libc.so.6"}, sonames(graph.Needs(bin)))
+	Assert.Equal([]elf.Edge{{From: liba, To: libb, Soname: "libb.so"}}, graph.NeededBy(libb))
+
+	// breadth-first, with the interpreter last
+	Assert.Equal([]string{"rpath", "liba.so", "libc.so.6", "libb.so", "ld-linux-x86-64.so.2"}, names(graph.LoadOrder()))
+	Assert.Equal(P_ld_linux, graph.Interpreter.Path)
+
+	Assert.Equal([]string{libb}, graph.Nodes[liba].Dependencies)
+	Assert.Equal(elf.Type(elf.DYN), graph.Nodes[liba].Type)
+	Assert.Empty(graph.Nodes[libb].Dependencies)
+}
+
+func TestGraphStatic(t *testing.T) {
+	Assert := assert.New(t)
+
+	graph, err := elf.NewGraph(P_hello_static)
+	Assert.NoError(err)
+
+	Assert.Nil(graph.Interpreter)
+	Assert.Empty(graph.Edges)
+	Assert.Nil(graph.Root.Diff(TestData[P_hello_static].Elf))
+	Assert.Equal([]*elf.Elf{graph.Root}, graph.LoadOrder())
+}
+
+func TestGraphMissing(t *testing.T) {
+	Assert := assert.New(t)
+	bin, err := filepath.Abs("testdata/rpath/bin/missing")
+	Assert.NoError(err)
+
+	graph, err := elf.NewGraph(bin)
+	Assert.ErrorIs(err, elf.ErrMissingDependency)
+
+	Assert.Contains(graph.Needs(bin), elf.Edge{From: bin, Soname: "libmissing1.so"})
+	Assert.Contains(graph.Needs(bin), elf.Edge{From: bin, Soname: "libmissing2.so"})
+	Assert.NotContains(names(graph.LoadOrder()), "libb.so")
+}
+
+func TestGraphMusl(t *testing.T) {
+	Assert := assert.New(t)
+	sysroot, err := filepath.Abs("testdata/musl/root")
+	Assert.NoError(err)
+	interpreter := filepath.Join(sysroot, "lib/ld-musl-x86_64.so.1")
+
+	graph, err := elf.NewGraph("/usr/bin/hello_musl", elf.Sysroot(sysroot))
+	Assert.NoError(err)
+
+	// libc is provided by the interpreter
+	Assert.Contains(graph.NeededBy(interpreter), elf.Edge{From: graph.Root.Path, To: interpreter, Soname: "libc.musl-x86_64.so.1"})
+	Assert.Equal([]string{"hello_musl", "libgreet.so", "ld-musl-x86_64.so.1"}, names(graph.LoadOrder()))
+}
+
+func TestVerifySymbols(t *testing.T) {
+	Assert := assert.New(t)
+	newer, err := filepath.Abs("testdata/symbols/bin/newer")
+	Assert.NoError(err)
+	rpath, err := filepath.Abs("testdata/rpath/bin/rpath")
+	Assert.NoError(err)
+
+	graph, err := elf.NewGraph(newer)
+	Assert.NoError(err)
+	err = graph.VerifySymbols()
+	Assert.ErrorIs(err, elf.ErrUnresolvedSymbol)
+	var unresolved *elf.UnresolvedSymbolError
+	if Assert.ErrorAs(err, &unresolved) {
+		Assert.Equal([]elf.Symbol{{Name: "newfunc", Version: "SYM_2.0", Library: "libsym.so", NeededBy: newer}}, unresolved.Unresolved)
+	}
+
+	graph, err = elf.NewGraph(rpath)
+	Assert.NoError(err)
+	Assert.NoError(graph.VerifySymbols())
+}
@@ -0,0 +1,318 @@
+package elf
+
+import (
+	"bytes"
+	debug_elf "debug/elf"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// A parsed `ld.so.cache`, as generated by `ldconfig`.
+//
+// Both the old `ld.so-1.7.0` and the new `glibc-ld.so.cache1.1` formats are supported, as well as the
+// combination of both which `ldconfig -c compat` generates.
+//
+//   - See https://sourceware.org/git/?p=glibc.git;a=blob;f=sysdeps/generic/dl-cache.h for the format
+type LdSoCache struct {
+	// All entries, in the same order as `ld.so` searches them
+	Entries []CacheEntry
+	// The version of `ldconfig` which generated the cache, "" if not recorded (e.g. old format)
+	Generator string
+}
+
+// A single library listed in an [LdSoCache]
+type CacheEntry struct {
+	// The soname which `ld.so` will look up
+	Soname string
+	// Path to the library, relative to the root which the cache describes
+	Path string
+	// Type & architecture of the library, see [CacheFlags]
+	Flags int32
+	// Minimum kernel version required by the library, 0 if none
+	OSVersion uint32
+	// Legacy hwcap bitmask, 0 if none. Ignored by `ld.so` since glibc 2.37
+	HWCap uint64
+	// glibc-hwcaps subdirectory which contains the library (e.g. "x86-64-v3"), "" if none
+	HWCaps string
+}
+
+// # Values for the type of library in [CacheEntry.Flags]
+const (
+	FLAG_LIBC4     = 0x0000
+	FLAG_ELF       = 0x0001
+	FLAG_ELF_LIBC5 = 0x0002
+	FLAG_ELF_LIBC6 = 0x0003
+)
+
+// # Values for the architecture of library in [CacheEntry.Flags]
+const (
+	FLAG_ANY_ARCH               = 0x0000 // i386 and any other architecture without a specific flag
+	FLAG_SPARC_LIB64            = 0x0100
+	FLAG_IA64_LIB64             = 0x0200
+	FLAG_X8664_LIB64            = 0x0300
+	FLAG_S390_LIB64             = 0x0400
+	FLAG_POWERPC_LIB64          = 0x0500
+	FLAG_MIPS64_LIBN32          = 0x0600
+	FLAG_MIPS64_LIBN64          = 0x0700
+	FLAG_X8664_LIBX32           = 0x0800
+	FLAG_ARM_LIBHF              = 0x0900
+	FLAG_AARCH64_LIB64          = 0x0a00
+	FLAG_ARM_LIBSF              = 0x0b00
+	FLAG_RISCV_FLOAT_ABI_SOFT   = 0x0f00
+	FLAG_RISCV_FLOAT_ABI_DOUBLE = 0x1000
+)
+
+// # Masks for [CacheEntry.Flags]
+const (
+	FLAG_TYPE_MASK = 0x00ff
+	FLAG_ARCH_MASK = 0xff00
+)
+
+const (
+	cacheMagicOld       = "ld.so-1.7.0"
+	cacheMagicNew       = "glibc-ld.so.cache1.1"
+	cacheExtensionMagic = 0xeaa42174
+	// Tags for the sections in the extension directory
+	cacheExtensionGenerator   = 0
+	cacheExtensionGlibcHwcaps = 1
+	// Upper 32 bits of hwcap which mark the lower 32 bits as an index into the glibc-hwcaps section
+	hwcapExtension = 1 << 30
+)
+
+// # Sizes of the structures in `ld.so.cache`
+const (
+	cacheHeaderOld = 16 // magic[11] + padding, nlibs
+	cacheEntryOld  = 12 // flags, key, value
+	cacheHeaderNew = 48 // magic[17], version[3], nlibs, len_strings, flags, padding[3], extension_offset, unused[3]
+	cacheEntryNew  = 24 // flags, key, value, osversion, hwcap
+)
+
+// Path to ld.so.cache, relative to root
+const p_ld_so_cache = "/etc/ld.so.cache"
+
+// Open & parse `/etc/ld.so.cache` under root (e.g. "/" for the host, or the root of a sysroot).
+//
+// The paths in the returned [CacheEntry]s are relative to root, in the same way as `ldconfig -r` records them.
+func OpenCache(root string) (*LdSoCache, error) {
+	path := filepath.Join(root, p_ld_so_cache)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cache, err := ParseCache(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cache, nil
+}
+
+// Parse the contents of an `ld.so.cache`.
+//
+//   - Any error will wrap [ErrInvalidCache]
+//   - The old format does not record its byte order, it is assumed to be little-endian
+func ParseCache(data []byte) (*LdSoCache, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte(cacheMagicNew)):
+		return parseNewCache(data, 0)
+	case bytes.HasPrefix(data, []byte(cacheMagicOld)):
+		if len(data) < cacheHeaderOld {
+			return nil, fmt.Errorf("%w: truncated header", ErrInvalidCache)
+		}
+		nlibs := uint64(binary.LittleEndian.Uint32(data[12:]))
+		entries := uint64(cacheHeaderOld) + nlibs*cacheEntryOld
+		if entries > uint64(len(data)) {
+			return nil, fmt.Errorf("%w: %v entries do not fit in %v bytes", ErrInvalidCache, nlibs, len(data))
+		}
+		// compat format: the new format follows, aligned, after the old entries & is a superset of them
+		if start := (entries + 7) &^ 7; start < uint64(len(data)) && bytes.HasPrefix(data[start:], []byte(cacheMagicNew)) {
+			return parseNewCache(data, int(start))
+		}
+		return parseOldCache(data, int(nlibs))
+	default:
+		return nil, fmt.Errorf("%w: unknown format", ErrInvalidCache)
+	}
+}
+
+// Parse the old format, in which strings are located relative to the end of the entries
+func parseOldCache(data []byte, nlibs int) (*LdSoCache, error) {
+	stringTable := cacheHeaderOld + nlibs*cacheEntryOld
+	cache := &LdSoCache{Entries: make([]CacheEntry, 0, nlibs)}
+	for i := range nlibs {
+		entry := data[cacheHeaderOld+i*cacheEntryOld:]
+		soname, err := cstring(data, stringTable, binary.LittleEndian.Uint32(entry[4:]))
+		if err != nil {
+			return nil, err
+		}
+		path, err := cstring(data, stringTable, binary.LittleEndian.Uint32(entry[8:]))
+		if err != nil {
+			return nil, err
+		}
+		cache.Entries = append(cache.Entries, CacheEntry{
+			Soname: soname,
+			Path:   path,
+			Flags:  int32(binary.LittleEndian.Uint32(entry)),
+		})
+	}
+	return cache, nil
+}
+
+// Parse the new format which starts at data[start:].
+//
+// Strings are located relative to start, the extension directory & its sections relative to the beginning of data.
+func parseNewCache(data []byte, start int) (*LdSoCache, error) {
+	header := data[start:]
+	if len(header) < cacheHeaderNew {
+		return nil, fmt.Errorf("%w: truncated header", ErrInvalidCache)
+	}
+
+	var byteOrder binary.ByteOrder = binary.LittleEndian
+	if header[28]&3 == 3 {
+		byteOrder = binary.BigEndian
+	}
+
+	nlibs := uint64(byteOrder.Uint32(header[20:]))
+	if uint64(cacheHeaderNew)+nlibs*cacheEntryNew > uint64(len(header)) {
+		return nil, fmt.Errorf("%w: %v entries do not fit in %v bytes", ErrInvalidCache, nlibs, len(header))
+	}
+
+	cache := &LdSoCache{Entries: make([]CacheEntry, 0, nlibs)}
+	hwcaps, err := cache.parseExtensions(data, start, uint64(byteOrder.Uint32(header[32:])), byteOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range int(nlibs) {
+		entry := header[cacheHeaderNew+i*cacheEntryNew:]
+		soname, err := cstring(data, start, byteOrder.Uint32(entry[4:]))
+		if err != nil {
+			return nil, err
+		}
+		path, err := cstring(data, start, byteOrder.Uint32(entry[8:]))
+		if err != nil {
+			return nil, err
+		}
+		parsed := CacheEntry{
+			Soname:    soname,
+			Path:      path,
+			Flags:     int32(byteOrder.Uint32(entry)),
+			OSVersion: byteOrder.Uint32(entry[12:]),
+			HWCap:     byteOrder.Uint64(entry[16:]),
+		}
+		if parsed.HWCap>>32 == hwcapExtension {
+			idx := uint32(parsed.HWCap)
+			if uint64(idx) >= uint64(len(hwcaps)) {
+				return nil, fmt.Errorf("%w: %s has glibc-hwcaps index %v, only %v listed", ErrInvalidCache, path, idx, len(hwcaps))
+			}
+			parsed.HWCaps = hwcaps[idx]
+			parsed.HWCap = 0
+		}
+		cache.Entries = append(cache.Entries, parsed)
+	}
+	return cache, nil
+}
+
+// Parse the extension directory at offset (if any), setting the Generator & returning the glibc-hwcaps subdirectories.
+func (c *LdSoCache) parseExtensions(data []byte, start int, offset uint64, byteOrder binary.ByteOrder) ([]string, error) {
+	if offset == 0 {
+		return nil, nil // no extensions
+	}
+	if offset+8 > uint64(len(data)) || byteOrder.Uint32(data[offset:]) != cacheExtensionMagic {
+		return nil, fmt.Errorf("%w: no extension directory at %#x", ErrInvalidCache, offset)
+	}
+	count := uint64(byteOrder.Uint32(data[offset+4:]))
+	if offset+8+count*16 > uint64(len(data)) {
+		return nil, fmt.Errorf("%w: %v extensions do not fit in %v bytes", ErrInvalidCache, count, len(data))
+	}
+
+	var hwcaps []string
+	for i := range count {
+		section := data[offset+8+i*16:]
+		tag := byteOrder.Uint32(section)
+		sectionOffset := uint64(byteOrder.Uint32(section[8:]))
+		size := uint64(byteOrder.Uint32(section[12:]))
+		if sectionOffset+size > uint64(len(data)) {
+			return nil, fmt.Errorf("%w: extension %v does not fit in %v bytes", ErrInvalidCache, i, len(data))
+		}
+		contents := data[sectionOffset : sectionOffset+size]
+		switch tag {
+		case cacheExtensionGenerator:
+			c.Generator = string(contents)
+		case cacheExtensionGlibcHwcaps:
+			for idx := 0; idx+4 <= len(contents); idx += 4 {
+				subdir, err := cstring(data, start, byteOrder.Uint32(contents[idx:]))
+				if err != nil {
+					return nil, err
+				}
+				hwcaps = append(hwcaps, subdir)
+			}
+		default:
+			continue // ignore unknown extensions, in the same way as ld.so
+		}
+	}
+	return hwcaps, nil
+}
+
+// The null-terminated string at data[base+offset:]
+func cstring(data []byte, base int, offset uint32) (string, error) {
+	start := uint64(base) + uint64(offset)
+	if start >= uint64(len(data)) {
+		return "", fmt.Errorf("%w: string at %#x is beyond end of file", ErrInvalidCache, start)
+	}
+	end := bytes.IndexByte(data[start:], 0)
+	if end < 0 {
+		return "", fmt.Errorf("%w: unterminated string at %#x", ErrInvalidCache, start)
+	}
+	return string(data[start : start+uint64(end)]), nil
+}
+
+// Find the library which `ld.so` would load for soname.
+//
+//   - flags must match exactly, use [CacheFlags] to identify the correct value for a given binary
+//   - hwcaps lists the glibc-hwcaps subdirectories which are supported, in order of preference. Libraries
+//     in any other glibc-hwcaps subdirectory, or with a legacy hwcap, are ignored
+//   - Returns false if no matching entry exists
+func (c *LdSoCache) Lookup(soname string, flags int32, hwcaps ...string) (CacheEntry, bool) {
+	var best CacheEntry
+	bestPriority := -1
+	for _, entry := range c.Entries {
+		if entry.Soname != soname || entry.Flags != flags || entry.HWCap != 0 {
+			continue
+		}
+		priority := len(hwcaps) // lowest priority: not in a glibc-hwcaps subdirectory
+		if entry.HWCaps != "" {
+			priority = slices.Index(hwcaps, entry.HWCaps)
+			if priority < 0 {
+				continue // unsupported
+			}
+		}
+		if bestPriority < 0 || priority < bestPriority {
+			best, bestPriority = entry, priority
+		}
+	}
+	return best, bestPriority >= 0
+}
+
+// The value for [CacheEntry.Flags] which `ld.so` requires for libraries loaded by a binary of the given class & machine
+func CacheFlags(class EI_CLASS, machine debug_elf.Machine) int32 {
+	var arch int32 = FLAG_ANY_ARCH
+	switch {
+	case machine == debug_elf.EM_X86_64 && class == EI_CLASS(ELF64):
+		arch = FLAG_X8664_LIB64
+	case machine == debug_elf.EM_X86_64 && class == EI_CLASS(ELF32):
+		arch = FLAG_X8664_LIBX32
+	case machine == debug_elf.EM_AARCH64:
+		arch = FLAG_AARCH64_LIB64
+	case machine == debug_elf.EM_PPC64:
+		arch = FLAG_POWERPC_LIB64
+	case machine == debug_elf.EM_S390 && class == EI_CLASS(ELF64):
+		arch = FLAG_S390_LIB64
+	case machine == debug_elf.EM_IA_64:
+		arch = FLAG_IA64_LIB64
+	case machine == debug_elf.EM_RISCV && class == EI_CLASS(ELF64):
+		arch = FLAG_RISCV_FLOAT_ABI_DOUBLE // lp64d, as used by all major distributions
+	}
+	return FLAG_ELF_LIBC6 | arch
+}
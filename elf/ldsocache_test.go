@@ -0,0 +1,103 @@
+package elf_test
+
+import (
+	debug_elf "debug/elf"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/MusicalNinjaDad/snaggle/elf"
+	. "github.com/MusicalNinjaDad/snaggle/internal"
+)
+
+const x86_64 = elf.FLAG_ELF_LIBC6 | elf.FLAG_X8664_LIB64
+
+func TestCacheFormats(t *testing.T) {
+	for _, format := range []string{"new", "old", "compat"} {
+		t.Run(format, func(t *testing.T) {
+			Assert := assert.New(t)
+			data, err := os.ReadFile("testdata/ldsocache/root/etc/ld.so.cache." + format)
+			Assert.NoError(err)
+
+			cache, err := elf.ParseCache(data)
+			Assert.NoError(err)
+			Assert.Len(cache.Entries, 3)
+
+			entry, found := cache.Lookup("libb.so", x86_64)
+			Assert.True(found)
+			Assert.Equal(elf.CacheEntry{Soname: "libb.so", Path: "/usr/lib64/libb.so", Flags: x86_64}, entry)
+
+			_, found = cache.Lookup("libb.so", elf.FLAG_ELF_LIBC6|elf.FLAG_AARCH64_LIB64)
+			Assert.False(found)
+
+			_, found = cache.Lookup("libc.so.6", x86_64)
+			Assert.False(found)
+
+			if format == "old" {
+				Assert.Empty(cache.Generator)
+			} else {
+				Assert.Contains(cache.Generator, "ldconfig")
+			}
+		})
+	}
+}
+
+func TestCacheHwcaps(t *testing.T) {
+	for _, format := range []string{"new", "compat"} {
+		t.Run(format, func(t *testing.T) {
+			Assert := assert.New(t)
+			data, err := os.ReadFile("testdata/ldsocache/root/etc/ld.so.cache." + format)
+			Assert.NoError(err)
+			cache, err := elf.ParseCache(data)
+			Assert.NoError(err)
+
+			entry, found := cache.Lookup("liba.so", x86_64)
+			Assert.True(found)
+			Assert.Equal("/usr/lib64/liba.so", entry.Path)
+			Assert.Empty(entry.HWCaps)
+
+			entry, found = cache.Lookup("liba.so", x86_64, "x86-64-v4", "x86-64-v3", "x86-64-v2")
+			Assert.True(found)
+			Assert.Equal("/usr/lib64/glibc-hwcaps/x86-64-v3/liba.so", entry.Path)
+			Assert.Equal("x86-64-v3", entry.HWCaps)
+			Assert.Zero(entry.HWCap)
+		})
+	}
+}
+
+func TestOpenCache(t *testing.T) {
+	Assert := assert.New(t)
+
+	cache, err := elf.OpenCache("testdata/ldsocache/root")
+	Assert.NoError(err)
+	_, found := cache.Lookup("liba.so", x86_64)
+	Assert.True(found)
+
+	_, err = elf.OpenCache(t.TempDir())
+	Assert.ErrorIs(err, os.ErrNotExist)
+}
+
+func TestHostCache(t *testing.T) {
+	Assert := assert.New(t)
+	cache, err := elf.OpenCache("/")
+	Assert.NoError(err)
+	entry, found := cache.Lookup("libc.so.6", elf.CacheFlags(elf.EI_CLASS(elf.ELF64), debug_elf.EM_X86_64))
+	Assert.True(found)
+	Assert.Equal(P_libc, entry.Path)
+}
+
+func TestInvalidCache(t *testing.T) {
+	Assert := assert.New(t)
+	data, err := os.ReadFile("testdata/ldsocache/root/etc/ld.so.cache.new")
+	Assert.NoError(err)
+
+	for desc, corrupt := range map[string][]byte{
+		"empty":     {},
+		"not cache": []byte("\x7fELF"),
+		"truncated": data[:60],
+	} {
+		_, err := elf.ParseCache(corrupt)
+		Assert.ErrorIs(err, elf.ErrInvalidCache, desc)
+	}
+}
@@ -0,0 +1,111 @@
+package elf
+
+import (
+	debug_elf "debug/elf"
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// Default musl search path, if there is no `/etc/ld-musl-<arch>.path`
+var muslDefaultDirs = []string{"/lib", "/usr/local/lib", "/usr/lib"}
+
+// Libraries which musl provides itself & never searches for: `libc`, `libpthread`, `librt`, `libm`, `libdl`,
+// `libutil` & `libxnet` with any suffix. E.g. `libc.musl-x86_64.so.1` or `libpthread.so.0`.
+var muslReserved = []string{"libc.", "libpthread.", "librt.", "libm.", "libdl.", "libutil.", "libxnet."}
+
+// Is soname provided by musl itself?
+func isMuslReserved(soname string) bool {
+	for _, reserved := range muslReserved {
+		if strings.HasPrefix(soname, reserved) {
+			return true
+		}
+	}
+	return false
+}
+
+// The `<arch>` used by musl in `ld-musl-<arch>.so.1` and `/etc/ld-musl-<arch>.path`.
+func muslArch(elffile *debug_elf.File) string {
+	switch elffile.Machine {
+	case debug_elf.EM_386:
+		return "i386"
+	case debug_elf.EM_AARCH64:
+		if elffile.ByteOrder == binary.BigEndian {
+			return "aarch64_be"
+		}
+		return "aarch64"
+	case debug_elf.EM_RISCV:
+		if elffile.Class == debug_elf.ELFCLASS32 {
+			return "riscv32"
+		}
+		return "riscv64"
+	case debug_elf.EM_PPC64:
+		if elffile.ByteOrder == binary.LittleEndian {
+			return "powerpc64le"
+		}
+		return "powerpc64"
+	case debug_elf.EM_S390:
+		return "s390x"
+	default:
+		return "x86_64"
+	}
+}
+
+// The directories musl searches after LD_LIBRARY_PATH & DT_RUNPATH/DT_RPATH, and whether they were read
+// from a path file.
+//
+// Read from `<prefix>/etc/ld-musl-<arch>.path`, where prefix is the directory above the one containing
+// the interpreter (e.g. "" for `/lib/ld-musl-x86_64.so.1`). Entries are separated by newlines or colons.
+// In the same way as musl: if the file does not exist [muslDefaultDirs] should be used instead, if it
+// cannot be read nothing is searched.
+func muslPath(files filesystem, interpreter string) ([]string, bool) {
+	prefix := strings.TrimSuffix(filepath.Dir(filepath.Dir(interpreter)), "/")
+	arch := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(interpreter), "ld-musl-"), ".so.1")
+	resolved, err := files.evalSymlinks(prefix + "/etc/ld-musl-" + arch + ".path")
+	if err == nil {
+		var contents []byte
+		contents, err = files.readFile(resolved)
+		if err == nil {
+			return strings.FieldsFunc(string(contents), func(c rune) bool { return c == ':' || c == '\n' }), true
+		}
+	}
+	return nil, !errors.Is(err, fs.ErrNotExist)
+}
+
+// musl's search order (see `load_library` in musl's ldso/dynlink.c):
+//  1. LD_LIBRARY_PATH (only if provided via [LibraryPath], the host environment is not used)
+//  2. DT_RUNPATH, or DT_RPATH if there is no DT_RUNPATH, of the requesting object and then of each
+//     object in the chain which loaded it
+//  3. The directories listed in `/etc/ld-musl-<arch>.path`, or the defaults: `/lib:/usr/local/lib:/usr/lib`
+func (r *resolver) muslSearchOrder(requester *object) []searchDir {
+	var dirs []searchDir
+
+	for _, dir := range r.libraryPath {
+		dirs = append(dirs, searchDir{LD_LIBRARY_PATH, dir, nil})
+	}
+
+	for obj := requester; obj != nil; obj = obj.loader {
+		switch {
+		case len(obj.runpath) != 0:
+			for _, dir := range obj.runpath {
+				dirs = append(dirs, searchDir{RUNPATH, dir, obj})
+			}
+		default:
+			for _, dir := range obj.rpath {
+				dirs = append(dirs, searchDir{RPATH, dir, obj})
+			}
+		}
+	}
+
+	for _, dir := range r.conf {
+		dirs = append(dirs, searchDir{LDSOCONF, dir, nil})
+	}
+
+	for _, dir := range r.defaultDirs {
+		dirs = append(dirs, searchDir{DEFAULTDIRS, dir, nil})
+	}
+
+	return dirs
+}
@@ -0,0 +1,804 @@
+package elf
+
+import (
+	"bufio"
+	"bytes"
+	debug_elf "debug/elf"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/MusicalNinjaDad/snaggle/internal"
+)
+
+// A library requested via `DT_NEEDED` (or see Via) and how it was located.
+type Library struct {
+	// The requested soname, as listed in `DT_NEEDED`
+	Soname string `json:"soname"`
+	// Where it was found
+	Path string `json:"path"`
+	// Path of the object which first requested it
+	NeededBy string `json:"neededBy"`
+	// Which step in the search order located it
+	Source Source `json:"source"`
+	// The search path entry which located it, before expanding any `$ORIGIN`, `$LIB` or `$PLATFORM`
+	//  - "" if Source is not RPATH, LD_LIBRARY_PATH or RUNPATH
+	SearchDir string `json:"searchDir,omitempty"`
+	// Path of the object whose DT_RPATH or DT_RUNPATH contained SearchDir, "" if not from DT_RPATH or DT_RUNPATH
+	Owner string `json:"owner,omitempty"`
+	// The `glibc-hwcaps` subdirectory it was found in (e.g. `x86-64-v3`), "" for the baseline. See [TargetISA].
+	HWCaps string `json:"hwcaps,omitempty"`
+	// How it was requested, if not `DT_NEEDED`
+	Via Via `json:"via,omitempty"`
+	// Loaded at runtime via `dlopen()`, rather than by ld.so at startup: Soname is from the `.note.dlopen` of
+	// NeededBy, or is `DT_NEEDED` by such a library. See [Dlopen].
+	Dlopen bool `json:"dlopen,omitempty"`
+	// Every location searched, in order, only recorded with [Explain]. The first which Found is the one used, also
+	// recorded for each library in a [MissingDependencyError].
+	Candidates []Candidate `json:"candidates,omitempty"`
+}
+
+// A location searched for a [Library]
+type Candidate struct {
+	// Which step in the search order
+	Source Source `json:"source"`
+	// The search path entry, before expanding any `$ORIGIN`, `$LIB` or `$PLATFORM`; "" for LDSOCACHE
+	SearchDir string `json:"searchDir,omitempty"`
+	// Path of the object whose DT_RPATH or DT_RUNPATH contained SearchDir
+	Owner string `json:"owner,omitempty"`
+	// Where the library was looked for, "" if not listed in ld.so.cache
+	Path string `json:"path"`
+	// Is there a compatible library at Path?
+	Found bool `json:"found"`
+}
+
+// How a [Library] was requested
+type Via string
+
+// # Values for [Via]
+const (
+	NEEDED    Via = ""              // `DT_NEEDED` of the requesting object
+	FILTER    Via = "DT_FILTER"     // `DT_FILTER` of the requesting object: a standard filtee
+	AUXILIARY Via = "DT_AUXILIARY"  // `DT_AUXILIARY` of the requesting object: an auxiliary filtee, ignored if it cannot be found
+	AUDIT     Via = "DT_AUDIT"      // `DT_AUDIT` of the executable: an audit library, ignored if it cannot be found
+	DEPAUDIT  Via = "DT_DEPAUDIT"   // `DT_DEPAUDIT` of the executable: as AUDIT
+	PRELOAD   Via = "ld.so.preload" // Listed in /etc/ld.so.preload, ignored if it cannot be found
+)
+
+// Which step in the search order located a [Library]
+type Source byte
+
+// # Values for [Source], in order of precedence
+const (
+	UNRESOLVED      = 0 // Not located
+	RPATH           = 1 // DT_RPATH of the requesting object, or of one of the objects which caused it to be loaded
+	LD_LIBRARY_PATH = 2 // Provided via [LibraryPath()]
+	RUNPATH         = 3 // DT_RUNPATH of the requesting object
+	LDSOCACHE       = 4 // Listed in /etc/ld.so.cache
+	LDSOCONF        = 5 // Directories listed in /etc/ld.so.conf, only used if /etc/ld.so.cache cannot be read; or in /etc/ld-musl-<arch>.path
+	DEFAULTDIRS     = 6 // Default system directories
+	PATHNAME        = 7 // Not searched for: `DT_NEEDED` contains a `/` and is loaded directly from that path
+)
+
+func (s Source) String() string {
+	switch s {
+	case UNRESOLVED:
+		return "unresolved"
+	case RPATH:
+		return "DT_RPATH"
+	case LD_LIBRARY_PATH:
+		return "LD_LIBRARY_PATH"
+	case RUNPATH:
+		return "DT_RUNPATH"
+	case LDSOCACHE:
+		return "ld.so.cache"
+	case LDSOCONF:
+		return "ld.so.conf"
+	case DEFAULTDIRS:
+		return "default directories"
+	case PATHNAME:
+		return "pathname"
+	default:
+		return fmt.Sprintf("Source(%d)", byte(s))
+	}
+}
+
+func (s Source) MarshalJSON() ([]byte, error) { return json.Marshal(s.String()) }
+
+// Locates dependencies in the same way as `ld.so`, without executing anything.
+//
+// The search order for each `DT_NEEDED` entry follows https://man7.org/linux/man-pages/man8/ld.so.8.html:
+//  1. DT_RPATH of the requesting object and then of each object in the chain which loaded it, unless
+//     the requesting object has DT_RUNPATH
+//  2. LD_LIBRARY_PATH (only if provided via [LibraryPath], the host environment is not used)
+//  3. DT_RUNPATH of the requesting object
+//  4. /etc/ld.so.cache, or the directories listed in /etc/ld.so.conf (from which `ldconfig` builds the cache)
+//     if the cache cannot be read
+//  5. The default system directories
+//
+// `$ORIGIN`, `$LIB` and `$PLATFORM` (or `${ORIGIN}` etc.) are expanded in all search paths.
+//
+// For glibc, the search is the same for:
+//   - `DT_FILTER` & `DT_AUXILIARY`, which are processed alongside `DT_NEEDED` in the order listed
+//   - each library listed in `/etc/ld.so.preload`, which are loaded before any `DT_NEEDED`, as though requested
+//     by the executable
+//   - `DT_AUDIT` & `DT_DEPAUDIT` of the executable, which are loaded into a separate namespace, as though
+//     requested by the executable
+//
+// With [Dlopen], the libraries declared in each object's `.note.dlopen` are searched for in the same way, as
+// though requested by that object, after all `DT_NEEDED` entries have been loaded.
+//
+// With a [TargetISA], each search path's `glibc-hwcaps` subdirectories for that level are searched before the
+// search path itself, and ld.so.cache entries in those subdirectories take precedence.
+//
+// musl uses a different search order, see [resolver.muslSearchOrder].
+//
+// If a [Sysroot] is provided, all search paths (including those from ld.so.cache & ld.so.conf) are
+// relative to it & all paths returned are paths on the host. The same applies to an fs.FS, see [NewFromFS].
+type resolver struct {
+	files       filesystem
+	root        string // sysroot, "" for the host or an fs.FS
+	class       debug_elf.Class
+	machine     debug_elf.Machine
+	byteOrder   binary.ByteOrder
+	libc        Libc
+	lib         string   // expansion of $LIB
+	platform    string   // expansion of $PLATFORM
+	libraryPath []string // LD_LIBRARY_PATH
+	cache       *LdSoCache
+	cacheFlags  int32    // required flags for entries in cache
+	hwcaps      []string // glibc-hwcaps subdirectories to search, in order of preference
+	explain     bool     // record every location searched
+	dlopen      Priority // resolve .note.dlopen dependencies with at least this priority, "" for none
+	scanRodata  bool     // guess dlopen dependencies from .rodata if there is no .note.dlopen
+	conf        []string // directories from /etc/ld.so.conf, only if cache == nil; or from /etc/ld-musl-<arch>.path
+	defaultDirs []string
+
+	loaded      map[string]string // soname -> path of every object which ld.so would already have loaded
+	loadedFiles []fs.FileInfo     // every object already loaded, ld.so will not load the same file twice
+	loadedPaths []string          // the path each of loadedFiles was loaded from
+	resolved    []string          // the fully resolved path of each of loadedFiles
+	interpreter string            // path on the host, provides musl's reserved libraries
+	edges       []Edge            // every DT_NEEDED (& .note.dlopen etc.) entry processed, in order
+	startup     []string          // path of every library loaded at startup into the executable's namespace, like ldd
+	ignored     []string          // every DT_AUXILIARY which could not be found, ldd lists these as not found
+}
+
+// A loaded object whose DT_NEEDED entries still need to be resolved
+type object struct {
+	path    string
+	file    *debug_elf.File
+	loader  *object  // the object which caused this one to be loaded, nil for the object being resolved
+	rpath   []string // DT_RPATH
+	runpath []string // DT_RUNPATH
+}
+
+// Path to ld.so.conf
+const p_ld_so_conf = "/etc/ld.so.conf"
+
+// Path to ld.so.preload
+const p_ld_so_preload = "/etc/ld.so.preload"
+
+func newResolver(elffile *debug_elf.File, interpreter string, libc Libc, options options) *resolver {
+	r := &resolver{
+		files:       options.filesystem(),
+		root:        options.sysroot,
+		class:       elffile.Class,
+		machine:     elffile.Machine,
+		byteOrder:   elffile.ByteOrder,
+		libc:        libc,
+		lib:         dstLib(options.filesystem(), interpreter, elffile),
+		platform:    archOf(elffile).platform,
+		libraryPath: options.libraryPath,
+		cacheFlags:  CacheFlags(EI_CLASS(elffile.Class), elffile.Machine),
+		explain:     options.explain,
+		dlopen:      options.dlopen,
+		scanRodata:  options.scanRodata,
+	}
+	if libc != MUSL {
+		r.hwcaps = hwcaps(elffile, options.isaLevel)
+	}
+	// the interpreter is always loaded before anything else
+	r.interpreter = r.host(interpreter)
+	r.loaded = map[string]string{filepath.Base(interpreter): r.interpreter}
+	switch {
+	case libc == MUSL: // musl has no ld.so.cache
+		var found bool
+		r.conf, found = muslPath(r.files, interpreter)
+		if !found {
+			r.defaultDirs = muslDefaultDirs
+		}
+	default:
+		if cache, err := r.files.cache(); err == nil {
+			r.cache = cache
+		} else {
+			r.conf = ldSoConf(r.files, p_ld_so_conf)
+		}
+		r.defaultDirs = defaultDirs(elffile)
+	}
+	r.alreadyLoaded(r.interpreter) // marks the interpreter as loaded
+	return r
+}
+
+// root, or "/" for the host
+func rootOrHost(root string) string {
+	if root == "" {
+		return "/"
+	}
+	return root
+}
+
+// The path on the host to a path within the sysroot.
+//
+// Keeps the path exactly as given (i.e. not Cleaned), open files via [resolver.open] to avoid following
+// symlinks out of the sysroot.
+func (r *resolver) host(path string) string {
+	return r.files.host(path)
+}
+
+// `$LIB` expands to the directory containing the interpreter, relative to `/` or `/usr`.
+//
+// E.g. `lib64` on Fedora or `lib/x86_64-linux-gnu` on Debian. Defaults to the directory used by
+// upstream glibc if the interpreter cannot be found.
+func dstLib(files filesystem, interpreter string, elffile *debug_elf.File) string {
+	resolved, err := files.evalSymlinks(interpreter)
+	if err != nil {
+		return archOf(elffile).slibdir
+	}
+	dir := filepath.Dir(internal.InRoot(files.root, resolved))
+	if usrdir, ok := strings.CutPrefix(dir, "/usr/"); ok {
+		return usrdir
+	}
+	return strings.TrimPrefix(dir, "/")
+}
+
+// Resolves all dependencies of elffile, breadth-first, in the same order as ld.so would load them.
+//
+//   - Returns every dependency except the interpreter, including those loaded directly by path ([PATHNAME])
+//   - With [Dlopen], also resolves `.note.dlopen` dependencies once everything `DT_NEEDED` has been loaded
+//   - Continues past any dependencies which cannot be found, returning them all as a [MissingDependencyError]
+func (r *resolver) resolve(path string, elffile *debug_elf.File) ([]Library, error) {
+	var libraries []Library
+	var missing []Library
+
+	root, err := newObject(path, elffile, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// ld.so loads everything needed at startup, then the audit libraries in their own namespace.
+	// Libraries loaded via dlopen() are only loaded later, at runtime.
+	const (
+		startup = iota
+		auditing
+		dlopening
+	)
+	phase := startup
+
+	// Adds the edge from requester to lib & queues lib, unless it is already loaded.
+	// libfile == nil if lib (with only Soname, Via & Dlopen set) could not be found.
+	var queue []*object
+	load := func(requester *object, lib Library, libfile *debug_elf.File) error {
+		edge := Edge{From: requester.path, Soname: lib.Soname, Via: lib.Via, Dlopen: lib.Dlopen}
+		if libfile == nil {
+			lib.NeededBy = requester.path
+			lib.Source = UNRESOLVED
+			missing = append(missing, lib)
+			r.loaded[lib.Soname] = "" // only report each soname once
+			r.edges = append(r.edges, edge)
+			return nil
+		}
+		if loaded, ok := r.alreadyLoaded(lib.Path); ok {
+			_ = libfile.Close() // same file requested under another name
+			r.loaded[lib.Soname] = loaded
+			edge.To = loaded
+			r.edges = append(r.edges, edge)
+			return nil
+		}
+		r.loaded[lib.Soname] = lib.Path
+		edge.To = lib.Path
+		r.edges = append(r.edges, edge)
+		libraries = append(libraries, lib)
+		if phase == startup {
+			r.startup = append(r.startup, lib.Path)
+		}
+		obj, err := newObject(lib.Path, libfile, requester)
+		if err != nil {
+			_ = libfile.Close()
+			return err
+		}
+		queue = append(queue, obj)
+		return nil
+	}
+
+	// Loads soname, as requested by requester via, unless it is already loaded or provided by the interpreter.
+	// If optional, nothing is recorded if soname cannot be found. Returns whether soname is (now) loaded.
+	request := func(requester *object, soname string, via Via, optional bool) (bool, error) {
+		if loaded, ok := r.loaded[soname]; ok && (loaded != "" || !optional) {
+			r.edges = append(r.edges, Edge{From: requester.path, To: loaded, Soname: soname, Via: via, Dlopen: phase == dlopening})
+			return loaded != "", nil
+		}
+		if r.libc == MUSL && isMuslReserved(soname) {
+			r.edges = append(r.edges, Edge{From: requester.path, To: r.interpreter, Soname: soname, Via: via, Dlopen: phase == dlopening})
+			return true, nil // provided by the interpreter
+		}
+		lib, libfile := r.locate(soname, requester, root)
+		if libfile == nil && optional {
+			if via == AUXILIARY {
+				r.ignored = append(r.ignored, soname)
+			}
+			return false, nil
+		}
+		lib.Soname = soname
+		lib.Via = via
+		lib.Dlopen = phase == dlopening
+		return libfile != nil, load(requester, lib, libfile)
+	}
+
+	// Loads the first alternative in dependency which is already loaded or can be found, if it has at least the
+	// priority requested via [Dlopen] (or was Guessed). Only REQUIRED dependencies, which are not Guessed, are
+	// reported as missing if no alternative can be found.
+	dlopen := func(requester *object, dependency DlopenDependency) error {
+		if len(dependency.Sonames) == 0 || !dependency.Guessed && !dependency.Priority.atLeast(r.dlopen) {
+			return nil
+		}
+		for _, soname := range dependency.Sonames {
+			if found, err := request(requester, soname, NEEDED, true); found || err != nil {
+				return err
+			}
+		}
+		if dependency.Priority == REQUIRED && !dependency.Guessed {
+			_, err := request(requester, dependency.Sonames[0], NEEDED, false)
+			return err
+		}
+		return nil
+	}
+
+	// .note.dlopen dependencies are only loaded at runtime, after everything else
+	type dlopenRequest struct {
+		requester    *object
+		dependencies []DlopenDependency
+	}
+	var dlopens []dlopenRequest
+
+	// The requests processed for each object, in the order listed
+	tags := []debug_elf.DynTag{debug_elf.DT_NEEDED}
+	var audits []dynamicString
+	if r.libc != MUSL {
+		tags = append(tags, debug_elf.DT_FILTER, debug_elf.DT_AUXILIARY)
+		audits, err = dynamicStrings(elffile, debug_elf.DT_AUDIT, debug_elf.DT_DEPAUDIT)
+		if err != nil {
+			return nil, fmt.Errorf("reading DT_AUDIT from %s: %w", path, err)
+		}
+	}
+
+	queue = append(queue, root)
+	if r.libc != MUSL {
+		for _, preload := range r.preloads(root) {
+			if _, err := request(root, preload, PRELOAD, true); err != nil {
+				closeAll(queue[1:])
+				return libraries, err
+			}
+		}
+	}
+
+	for len(queue) > 0 || phase == startup || len(dlopens) > 0 {
+		if len(queue) == 0 {
+			switch {
+			case phase == startup:
+				phase = auditing
+				for _, audit := range audits {
+					for _, soname := range splitPath([]string{audit.value}) {
+						via := AUDIT
+						if audit.tag == debug_elf.DT_DEPAUDIT {
+							via = DEPAUDIT
+						}
+						if _, err := request(root, soname, via, true); err != nil {
+							closeAll(queue)
+							return libraries, err
+						}
+					}
+				}
+			default:
+				phase = dlopening
+				for _, request := range dlopens {
+					for _, dependency := range request.dependencies {
+						if err := dlopen(request.requester, dependency); err != nil {
+							closeAll(queue)
+							return libraries, err
+						}
+					}
+				}
+				dlopens = nil
+			}
+			continue
+		}
+
+		requester := queue[0]
+		queue = queue[1:]
+
+		entries, err := dynamicStrings(requester.file, tags...)
+		if err != nil {
+			closeAll(queue)
+			return libraries, fmt.Errorf("reading DT_NEEDED from %s: %w", requester.path, err)
+		}
+
+		for _, entry := range entries {
+			via := NEEDED
+			switch entry.tag {
+			case debug_elf.DT_FILTER:
+				via = FILTER
+			case debug_elf.DT_AUXILIARY:
+				via = AUXILIARY
+			}
+			if _, err := request(requester, entry.value, via, via == AUXILIARY); err != nil {
+				closeAll(queue)
+				return libraries, err
+			}
+		}
+
+		if r.dlopen != "" {
+			dependencies, err := dlopenDependencies(requester.file, r.scanRodata)
+			if err != nil {
+				closeAll(queue)
+				return libraries, fmt.Errorf("reading .note.dlopen from %s: %w", requester.path, err)
+			}
+			if len(dependencies) > 0 {
+				dlopens = append(dlopens, dlopenRequest{requester, dependencies})
+			}
+		}
+
+		if requester != root {
+			_ = requester.file.Close() // read-only, nothing to lose
+		}
+	}
+
+	slices.SortFunc(libraries, func(a Library, b Library) int { return libpathcmp(a.Path, b.Path) })
+	slices.SortFunc(r.startup, libpathcmp)
+	if len(missing) > 0 {
+		return libraries, &MissingDependencyError{missing}
+	}
+	return libraries, nil
+}
+
+// The libraries listed in `/etc/ld.so.preload`, which ld.so loads as though requested by executable. Any
+// `$ORIGIN`, `$LIB` or `$PLATFORM` in pathnames are expanded.
+//
+// Returns nil if the file does not exist or cannot be read, which ld.so silently ignores.
+func (r *resolver) preloads(executable *object) []string {
+	resolved, err := r.files.evalSymlinks(p_ld_so_preload)
+	if err != nil {
+		return nil
+	}
+	contents, err := r.files.readFile(resolved)
+	if err != nil {
+		return nil
+	}
+	var preloads []string
+	separator := func(r rune) bool { return strings.ContainsRune(" \t\n:", r) } // as ld.so
+	for _, preload := range strings.FieldsFunc(string(contents), separator) {
+		if strings.Contains(preload, "/") {
+			preload = r.expand(preload, dirname(internal.InRoot(r.root, executable.path)))
+		}
+		preloads = append(preloads, preload)
+	}
+	return preloads
+}
+
+// Locates soname, as requested by requester: directly if it is a pathname, otherwise by searching.
+//
+// Returns (Library{}, nil) if soname cannot be found.
+func (r *resolver) locate(soname string, requester *object, executable *object) (Library, *debug_elf.File) {
+	if strings.Contains(soname, "/") {
+		return r.direct(soname, requester, executable)
+	}
+	return r.search(soname, requester)
+}
+
+func newObject(path string, file *debug_elf.File, loader *object) (*object, error) {
+	rpath, err := file.DynString(debug_elf.DT_RPATH)
+	if err != nil {
+		return nil, fmt.Errorf("%w: reading DT_RPATH from %s: %w", ErrInvalidElf, path, err)
+	}
+	runpath, err := file.DynString(debug_elf.DT_RUNPATH)
+	if err != nil {
+		return nil, fmt.Errorf("%w: reading DT_RUNPATH from %s: %w", ErrInvalidElf, path, err)
+	}
+	return &object{
+		path:    path,
+		file:    file,
+		loader:  loader,
+		rpath:   splitPath(rpath),
+		runpath: splitPath(runpath),
+	}, nil
+}
+
+// Has ld.so already loaded the file at path (on the host, possibly via a different path or name)?
+// If so, also returns the path it was originally loaded from.
+//
+// Marks path as loaded if not.
+func (r *resolver) alreadyLoaded(path string) (string, bool) {
+	resolved, err := r.files.evalSymlinks(internal.InRoot(r.root, path))
+	if err != nil {
+		return "", false
+	}
+	info, err := r.files.stat(resolved)
+	if err != nil {
+		return "", false
+	}
+	for idx, loaded := range r.loadedFiles {
+		// an fs.FS may not provide the details needed by SameFile, in which case hard links are not detected
+		if os.SameFile(info, loaded) || resolved == r.resolved[idx] {
+			return r.loadedPaths[idx], true
+		}
+	}
+	r.loadedFiles = append(r.loadedFiles, info)
+	r.loadedPaths = append(r.loadedPaths, path)
+	r.resolved = append(r.resolved, resolved)
+	return "", false
+}
+
+// A directory to search, and where it came from
+type searchDir struct {
+	source Source
+	dir    string  // unexpanded, "" for LDSOCACHE
+	owner  *object // object whose DT_RPATH or DT_RUNPATH contained dir
+}
+
+// Search for soname, as requested by requester, returning the details and opened ELF of the first compatible match.
+//
+// Returns (Library{}, nil) if soname cannot be found.
+func (r *resolver) search(soname string, requester *object) (Library, *debug_elf.File) {
+	var used Library
+	var usedFile *debug_elf.File
+	var candidates []Candidate
+	for _, search := range r.searchOrder(requester) {
+		type location struct{ path, hwcaps string }
+		var locations []location
+		switch search.source {
+		case LDSOCACHE:
+			entry, found := r.cache.Lookup(soname, r.cacheFlags, r.hwcaps...)
+			if !found {
+				if r.explain {
+					candidates = append(candidates, Candidate{Source: LDSOCACHE})
+				}
+				continue
+			}
+			locations = []location{{entry.Path, entry.HWCaps}}
+		default:
+			dir := search.dir
+			switch {
+			case search.owner != nil:
+				dir = r.expand(dir, dirname(internal.InRoot(r.root, search.owner.path)))
+			case r.libc != MUSL: // musl only expands DT_RUNPATH & DT_RPATH
+				dir = r.expand(dir, "")
+			}
+			dir = strings.TrimRight(dir, "/") // don't Clean: keep the path exactly as ld.so would
+			for _, hwcaps := range r.hwcaps {
+				locations = append(locations, location{dir + "/glibc-hwcaps/" + hwcaps + "/" + soname, hwcaps})
+			}
+			locations = append(locations, location{dir + "/" + soname, ""})
+		}
+		for _, location := range locations {
+			libfile := r.open(location.path)
+			if r.explain {
+				candidate := Candidate{Source: search.source, SearchDir: search.dir, Path: r.host(location.path), Found: libfile != nil}
+				if search.source == RPATH || search.source == RUNPATH {
+					candidate.Owner = search.owner.path
+				}
+				candidates = append(candidates, candidate)
+			}
+			switch {
+			case libfile == nil:
+				continue
+			case usedFile != nil: // only when explaining: lower precedence than the one used
+				_ = libfile.Close()
+				continue
+			}
+			used = Library{
+				Soname:   soname,
+				Path:     r.host(location.path),
+				NeededBy: requester.path,
+				Source:   search.source,
+				HWCaps:   location.hwcaps,
+			}
+			usedFile = libfile
+			switch search.source {
+			case RPATH, RUNPATH:
+				used.Owner = search.owner.path
+				used.SearchDir = search.dir
+			case LD_LIBRARY_PATH:
+				used.SearchDir = search.dir
+			}
+			if !r.explain {
+				break
+			}
+		}
+		if usedFile != nil && !r.explain {
+			break
+		}
+	}
+	used.Candidates = candidates // also where a missing library was searched for
+	return used, usedFile
+}
+
+// Opens pathname, as requested by requester, without searching. Returns (Library{}, nil) if it cannot be found.
+//
+// ld.so opens a relative pathname from the current working directory, this assumes that is the directory
+// containing executable.
+func (r *resolver) direct(pathname string, requester *object, executable *object) (Library, *debug_elf.File) {
+	path := pathname
+	if !strings.HasPrefix(pathname, "/") {
+		path = filepath.Join(filepath.Dir(internal.InRoot(r.root, executable.path)), pathname)
+	}
+	libfile := r.open(path)
+	if libfile == nil {
+		return Library{}, nil
+	}
+	return Library{Soname: pathname, Path: r.host(path), NeededBy: requester.path, Source: PATHNAME}, libfile
+}
+
+// All directories to search, in order, when requester needs a library
+func (r *resolver) searchOrder(requester *object) []searchDir {
+	if r.libc == MUSL {
+		return r.muslSearchOrder(requester)
+	}
+
+	var dirs []searchDir
+
+	// DT_RPATH is ignored if the requester has DT_RUNPATH
+	if len(requester.runpath) == 0 {
+		for obj := requester; obj != nil; obj = obj.loader {
+			if len(obj.runpath) != 0 {
+				continue // as are DT_RPATHs of any other objects in the chain which have DT_RUNPATH
+			}
+			for _, dir := range obj.rpath {
+				dirs = append(dirs, searchDir{RPATH, dir, obj})
+			}
+		}
+	}
+
+	// $ORIGIN in LD_LIBRARY_PATH refers to the executable
+	executable := requester
+	for executable.loader != nil {
+		executable = executable.loader
+	}
+	for _, dir := range r.libraryPath {
+		dirs = append(dirs, searchDir{LD_LIBRARY_PATH, dir, executable})
+	}
+
+	for _, dir := range requester.runpath {
+		dirs = append(dirs, searchDir{RUNPATH, dir, requester})
+	}
+
+	if r.cache != nil {
+		dirs = append(dirs, searchDir{source: LDSOCACHE})
+	}
+	for _, dir := range r.conf {
+		dirs = append(dirs, searchDir{LDSOCONF, dir, nil})
+	}
+
+	for _, dir := range r.defaultDirs {
+		dirs = append(dirs, searchDir{DEFAULTDIRS, dir, nil})
+	}
+
+	return dirs
+}
+
+// Expand the dynamic string tokens `$ORIGIN`, `$LIB` & `$PLATFORM` (and `${ORIGIN}` etc.) in dir.
+//
+// musl only supports `$ORIGIN`.
+func (r *resolver) expand(dir string, origin string) string {
+	if !strings.Contains(dir, "$") {
+		return dir
+	}
+	if r.libc == MUSL {
+		return strings.NewReplacer("${ORIGIN}", origin, "$ORIGIN", origin).Replace(dir)
+	}
+	return strings.NewReplacer(
+		"${ORIGIN}", origin,
+		"$ORIGIN", origin,
+		"${LIB}", r.lib,
+		"$LIB", r.lib,
+		"${PLATFORM}", r.platform,
+		"$PLATFORM", r.platform,
+	).Replace(dir)
+}
+
+// Directory part of path, without cleaning, in the same way as ld.so determines `$ORIGIN`.
+func dirname(path string) string {
+	return path[:max(strings.LastIndex(path, "/"), 0)]
+}
+
+// Opens path (within the sysroot) if it is an ELF which is compatible with the one being resolved,
+// otherwise returns nil.
+func (r *resolver) open(path string) *debug_elf.File {
+	resolved, err := r.files.evalSymlinks(path)
+	if err != nil {
+		return nil
+	}
+	lib, err := r.files.openElf(resolved)
+	if err != nil {
+		return nil
+	}
+	if lib.Class != r.class || lib.Machine != r.machine || lib.ByteOrder != r.byteOrder {
+		_ = lib.Close()
+		return nil
+	}
+	return lib
+}
+
+// Split the colon-separated entries from DT_RPATH or DT_RUNPATH into individual directories.
+func splitPath(entries []string) []string {
+	var dirs []string
+	for _, entry := range entries {
+		for dir := range strings.SplitSeq(entry, ":") {
+			if dir != "" {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	return dirs
+}
+
+func closeAll(objects []*object) {
+	for _, obj := range objects {
+		_ = obj.file.Close()
+	}
+}
+
+// Directories listed in the ld.so.conf at path within root, following any `include` directives.
+//
+// Unreadable files are silently ignored, in the same way as `ldconfig`.
+func ldSoConf(files filesystem, path string) []string {
+	return parseLdSoConf(files, path, make(map[string]bool))
+}
+
+func parseLdSoConf(files filesystem, path string, seen map[string]bool) []string {
+	if seen[path] {
+		return nil // avoid include loops
+	}
+	seen[path] = true
+
+	resolved, err := files.evalSymlinks(path)
+	if err != nil {
+		return nil
+	}
+	conf, err := files.readFile(resolved)
+	if err != nil {
+		return nil
+	}
+
+	var dirs []string
+	lines := bufio.NewScanner(bytes.NewReader(conf))
+	for lines.Scan() {
+		line, _, _ := strings.Cut(lines.Text(), "#")
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+			continue
+		case fields[0] == "include":
+			for _, pattern := range fields[1:] {
+				if !filepath.IsAbs(pattern) {
+					pattern = filepath.Join(filepath.Dir(path), pattern)
+				}
+				for _, include := range files.glob(pattern) {
+					dirs = append(dirs, parseLdSoConf(files, include, seen)...)
+				}
+			}
+		case fields[0] == "hwcap":
+			continue // obsolete & ignored by modern ldconfig
+		default:
+			for _, dir := range fields {
+				dir, _, _ = strings.Cut(dir, "=") // strip obsolete `dir=TYPE` suffix
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	return dirs
+}
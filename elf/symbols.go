@@ -0,0 +1,133 @@
+package elf
+
+import (
+	debug_elf "debug/elf"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// An undefined dynamic symbol, required by an object in a [Graph]
+type Symbol struct {
+	// The symbol name, e.g. `memcpy`
+	Name string
+	// The required GNU symbol version, e.g. `GLIBC_2.34`, "" if unversioned
+	Version string
+	// The library expected to provide Version, as recorded when linking, e.g. `libc.so.6`; "" if unversioned
+	Library string
+	// Path of the object which requires the symbol
+	NeededBy string
+}
+
+// Every symbol which would be unresolved when loading a [Graph].
+//
+// Wraps [ErrUnresolvedSymbol]. To list the unresolved symbols use [errors.As]:
+//
+//	var unresolved *UnresolvedSymbolError
+//	if errors.As(err, &unresolved) {
+//	     unresolved.Unresolved
+//	}
+//
+// .
+type UnresolvedSymbolError struct {
+	Unresolved []Symbol
+}
+
+func (e *UnresolvedSymbolError) Error() string {
+	symbols := make([]string, 0, len(e.Unresolved))
+	for _, sym := range e.Unresolved {
+		symbols = append(symbols, sym.String()+" needed by "+sym.NeededBy)
+	}
+	return ErrUnresolvedSymbol.Error() + ": " + strings.Join(symbols, ", ")
+}
+
+func (e *UnresolvedSymbolError) Unwrap() error {
+	return ErrUnresolvedSymbol
+}
+
+// The symbol as written by `ldd -r`, e.g. `memcpy@GLIBC_2.14 (libc.so.6)`
+func (s Symbol) String() string {
+	if s.Version == "" {
+		return s.Name
+	}
+	return s.Name + "@" + s.Version + " (" + s.Library + ")"
+}
+
+// Checks, like `ldd -r`, that every undefined dynamic symbol in the graph is exported by some object in
+// the graph, with the required GNU symbol version. Any error will be an [ErrElf].
+//
+//   - Unresolved symbols are returned as an [UnresolvedSymbolError]
+//   - Weak undefined symbols are ignored, they do not need to be defined
+//   - Objects which could not be found are ignored, see [MissingDependencyError]
+//   - Does not check that each symbol is exported by the specific library it was linked against, as
+//     `ld.so` binds to the first definition in [Graph.LoadOrder]
+func (g *Graph) VerifySymbols() error {
+	reterr := &ErrElf{path: g.Root.Path}
+
+	type definition struct{ name, version string }
+	defined := make(map[definition]bool) // version "" for any version
+	unversioned := make(map[string]bool) // defined without any version information, satisfy any version
+	var undefined []Symbol
+
+	for _, obj := range g.LoadOrder() {
+		symbols, err := g.dynamicSymbols(obj.Path)
+		if err != nil {
+			reterr.Join(err)
+			continue
+		}
+		for _, sym := range symbols {
+			bind := debug_elf.ST_BIND(sym.Info)
+			switch {
+			case sym.Section == debug_elf.SHN_UNDEF && bind != debug_elf.STB_WEAK:
+				undefined = append(undefined, Symbol{Name: sym.Name, Version: sym.Version, Library: sym.Library, NeededBy: obj.Path})
+			case sym.Section == debug_elf.SHN_UNDEF, bind == debug_elf.STB_LOCAL:
+				continue
+			case debug_elf.ST_VISIBILITY(sym.Other) == debug_elf.STV_HIDDEN, debug_elf.ST_VISIBILITY(sym.Other) == debug_elf.STV_INTERNAL:
+				continue
+			case sym.Version == "":
+				unversioned[sym.Name] = true
+				defined[definition{sym.Name, ""}] = true
+			default:
+				defined[definition{sym.Name, sym.Version}] = true
+				defined[definition{sym.Name, ""}] = true
+			}
+		}
+	}
+
+	unresolved := new(UnresolvedSymbolError)
+	for _, sym := range undefined {
+		if !defined[definition{sym.Name, sym.Version}] && !unversioned[sym.Name] {
+			unresolved.Unresolved = append(unresolved.Unresolved, sym)
+		}
+	}
+	if len(unresolved.Unresolved) > 0 {
+		reterr.Join(unresolved)
+	}
+
+	if reterr.IsError() {
+		return reterr
+	}
+	return nil
+}
+
+// The dynamic symbols of the object at (host) path, nil if it has none
+func (g *Graph) dynamicSymbols(path string) ([]debug_elf.Symbol, error) {
+	resolved, err := g.files.evalSymlinks(g.files.inRoot(path))
+	if err != nil {
+		return nil, err
+	}
+	elffile, err := g.files.openElf(resolved)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = elffile.Close() }()
+
+	symbols, err := elffile.DynamicSymbols()
+	if errors.Is(err, debug_elf.ErrNoSymbols) {
+		return nil, nil // e.g. a static binary
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: reading dynamic symbols from %s: %w", ErrInvalidElf, path, err)
+	}
+	return symbols, nil
+}
@@ -0,0 +1,53 @@
+#!/usr/bin/env bash
+# Builds a snagged root whose bundled libc.so.6 is older than its binaries need, like snagging binaries
+# from a newer distribution alongside an older glibc:
+#   - root/lib64/libc.so.6 provides GLIBC_2.2.5 only
+#   - root/bin/old needs GLIBC_2.2.5
+#   - root/bin/new needs GLIBC_2.17, Linux 4.4.0 (.note.ABI-tag) & x86-64-v3 (GNU_PROPERTY_X86_ISA_1_NEEDED)
+# Nothing here is a real glibc, nor is it meant to be run.
+set -euo pipefail
+
+SCRIPT_DIR="$(cd -- "$(dirname -- "${BASH_SOURCE[0]:-$0}")" && pwd)"
+cd "$SCRIPT_DIR" || exit 1
+
+SRC="$(mktemp -d)"
+trap 'rm -rf "$SRC"' EXIT
+
+cat > "$SRC/libc.c" <<'C'
+int oldfunc(void) { return 1; }
+int newfunc(void) { return 2; }
+C
+
+cat > "$SRC/old.map" <<'MAP'
+GLIBC_2.2.5 { global: oldfunc; local: *; };
+MAP
+
+cat > "$SRC/new.map" <<'MAP'
+GLIBC_2.2.5 { global: oldfunc; local: *; };
+GLIBC_2.17 { global: newfunc; } GLIBC_2.2.5;
+MAP
+
+cat > "$SRC/old.c" <<'C'
+int oldfunc(void);
+void _start(void) { oldfunc(); }
+C
+
+cat > "$SRC/new.c" <<'C'
+int newfunc(void);
+void _start(void) { newfunc(); }
+/* os (0 = Linux), major, minor, subminor */
+__asm__(".section .note.ABI-tag, \"a\", @note\n"
+        ".p2align 2\n"
+        ".long 4, 16, 1\n"
+        ".asciz \"GNU\"\n"
+        ".long 0, 4, 4, 0\n"
+        ".previous\n");
+C
+
+mkdir -p root/bin root/lib64
+
+gcc -shared -fPIC -nostdlib -Wl,-soname,libc.so.6 -Wl,--version-script,"$SRC/old.map" -o root/lib64/libc.so.6 "$SRC/libc.c"
+gcc -shared -fPIC -nostdlib -Wl,-soname,libc.so.6 -Wl,--version-script,"$SRC/new.map" -o "$SRC/libc.so.6" "$SRC/libc.c"
+
+gcc -nostdlib -o root/bin/old "$SRC/old.c" "$SRC/libc.so.6"
+gcc -nostdlib -march=x86-64-v3 -mneeded -o root/bin/new "$SRC/new.c" "$SRC/libc.so.6"
@@ -0,0 +1,66 @@
+#!/usr/bin/env bash
+# Builds executables which dlopen() plugins, declared in a `.note.dlopen` (see
+# https://uapi-group.org/specifications/specs/elf_dlopen_metadata/) or only found by scanning `.rodata`.
+#
+#   - bin/app declares a required plugin (with an alternative soname which does not exist), a recommended &
+#     a suggested plugin. The required plugin needs libplugin-dep.so.
+#   - bin/broken declares a required plugin which does not exist
+#   - bin/scan has no `.note.dlopen`, the soname passed to dlopen() is only in `.rodata`
+set -euo pipefail
+
+SCRIPT_DIR="$(cd -- "$(dirname -- "${BASH_SOURCE[0]:-$0}")" && pwd)"
+cd "$SCRIPT_DIR" || exit 1
+
+SRC="$(mktemp -d)"
+trap 'rm -rf "$SRC"' EXIT
+
+# a `.note.dlopen` containing the JSON in $1, in the same layout as systemd's ELF_NOTE_DLOPEN
+note() {
+    cat <<C
+#include <stdint.h>
+#define DLOPEN_JSON "$1"
+__attribute__((used, aligned(4), section(".note.dlopen"))) static const struct {
+    uint32_t namesz, descsz, type;
+    char name[4];
+    char desc[sizeof(DLOPEN_JSON)];
+} dlopen_note = {4, sizeof(DLOPEN_JSON), 0x407c0c0a, "FDO", DLOPEN_JSON};
+C
+}
+
+note '[{\"soname\":[\"libmissing-alt.so.1\",\"libplugin-req.so\"],\"feature\":\"req\",\"description\":\"Required plugin\",\"priority\":\"required\"},{\"soname\":[\"libplugin-rec.so\"],\"feature\":\"rec\"},{\"soname\":[\"libplugin-sug.so\"],\"feature\":\"sug\",\"priority\":\"suggested\"}]' \
+    > "$SRC/app.c"
+note '[{\"soname\":[\"libplugin-gone.so\"],\"feature\":\"gone\",\"priority\":\"required\"}]' > "$SRC/broken.c"
+for main in app broken; do
+    echo 'int main(void) { return 0; }' >> "$SRC/$main.c"
+done
+
+cat > "$SRC/scan.c" <<'C'
+#include <dlfcn.h>
+int main(void) { return dlopen("libplugin-rodata.so", RTLD_NOW) == 0; }
+C
+
+cat > "$SRC/plugin.c" <<'C'
+int plugin(void) { return 0; }
+C
+
+cat > "$SRC/plugin-dep.c" <<'C'
+int plugin_dep(void) { return 0; }
+C
+
+cat > "$SRC/plugin-req.c" <<'C'
+int plugin_dep(void);
+int plugin(void) { return plugin_dep(); }
+C
+
+mkdir -p bin lib
+
+gcc -shared -fPIC -Wl,-soname,libplugin-dep.so -o lib/libplugin-dep.so "$SRC/plugin-dep.c"
+for plugin in rec sug rodata; do
+    gcc -shared -fPIC -Wl,-soname,libplugin-"$plugin".so -o lib/libplugin-"$plugin".so "$SRC/plugin.c"
+done
+gcc -shared -fPIC -Wl,-soname,libplugin-req.so -o lib/libplugin-req.so "$SRC/plugin-req.c" \
+    -Llib -lplugin-dep -Wl,-rpath,'$ORIGIN'
+
+for main in app broken scan; do
+    gcc -o bin/"$main" "$SRC/$main.c" -Wl,-rpath,'$ORIGIN/../lib'
+done
@@ -0,0 +1,29 @@
+#!/usr/bin/env bash
+# Builds binaries whose dependencies are two levels above their own directory, so would be snagged outside
+# DESTINATION if their relative layout were kept when the binary is snagged to DESTINATION/bin:
+#   - opt/app/bin/origin finds opt/lib/liborigin.so via DT_RUNPATH $ORIGIN/../../lib
+#   - opt/app/bin/pathname needs ../../lib/libpathname.so (a relative pathname)
+set -euo pipefail
+
+SCRIPT_DIR="$(cd -- "$(dirname -- "${BASH_SOURCE[0]:-$0}")" && pwd)"
+cd "$SCRIPT_DIR" || exit 1
+
+SRC="$(mktemp -d)"
+trap 'rm -rf "$SRC"' EXIT
+
+cat > "$SRC/lib.c" <<'C'
+int lib(void) { return 0; }
+C
+
+cat > "$SRC/main.c" <<'C'
+int main(void) { return 0; }
+C
+
+mkdir -p opt/app/bin opt/lib
+
+gcc -shared -fPIC -Wl,-soname,liborigin.so -o opt/lib/liborigin.so "$SRC/lib.c"
+# A soname containing a `/` is used verbatim as DT_NEEDED by anything linking against it
+gcc -shared -fPIC -Wl,-soname,../../lib/libpathname.so -o opt/lib/libpathname.so "$SRC/lib.c"
+
+gcc -o opt/app/bin/origin "$SRC/main.c" -Wl,--no-as-needed -Lopt/lib -lorigin -Wl,-rpath,'$ORIGIN/../../lib'
+gcc -o opt/app/bin/pathname "$SRC/main.c" -Wl,--no-as-needed opt/lib/libpathname.so
@@ -0,0 +1,45 @@
+#!/usr/bin/env bash
+# Builds an executable which uses a filter library & an audit library, plus a library to list in ld.so.preload:
+#   - lib/libfilter.so has DT_FILTER=libfiltee.so, DT_AUXILIARY=libaux.so & DT_AUXILIARY=libaux-missing.so
+#     (which does not exist), all found via DT_RUNPATH=$ORIGIN
+#   - bin/filtered needs libfilter.so via DT_RUNPATH=$ORIGIN/../lib and has DT_AUDIT=libaudit.so, also found via
+#     DT_RUNPATH, and DT_DEPAUDIT=libaudit-missing.so, which does not exist
+#   - lib/libpreload.so needs libaux.so via DT_RUNPATH=$ORIGIN
+set -euo pipefail
+
+SCRIPT_DIR="$(cd -- "$(dirname -- "${BASH_SOURCE[0]:-$0}")" && pwd)"
+cd "$SCRIPT_DIR" || exit 1
+
+SRC="$(mktemp -d)"
+trap 'rm -rf "$SRC"' EXIT
+
+cat > "$SRC/filter.c" <<'C'
+int filtered(void) { return FILTEE; }
+C
+
+cat > "$SRC/audit.c" <<'C'
+unsigned int la_version(unsigned int version) { return version; }
+C
+
+cat > "$SRC/preload.c" <<'C'
+int filtered(void);
+int preloaded(void) { return filtered(); }
+C
+
+cat > "$SRC/main.c" <<'C'
+int filtered(void);
+int main(void) { return filtered(); }
+C
+
+mkdir -p bin lib
+
+gcc -shared -fPIC -DFILTEE=0 -Wl,-soname,libfiltee.so -o lib/libfiltee.so "$SRC/filter.c"
+gcc -shared -fPIC -DFILTEE=2 -Wl,-soname,libaux.so -o lib/libaux.so "$SRC/filter.c"
+gcc -shared -fPIC -DFILTEE=1 -Wl,-soname,libfilter.so -o lib/libfilter.so "$SRC/filter.c" \
+    -Wl,-F,libfiltee.so -Wl,-f,libaux.so -Wl,-f,libaux-missing.so -Wl,-rpath,'$ORIGIN'
+gcc -shared -fPIC -Wl,-soname,libaudit.so -o lib/libaudit.so "$SRC/audit.c"
+gcc -shared -fPIC -Wl,-soname,libpreload.so -o lib/libpreload.so "$SRC/preload.c" \
+    -Llib -Wl,--no-as-needed -laux -Wl,-rpath,'$ORIGIN'
+
+gcc -o bin/filtered "$SRC/main.c" -Llib -lfilter -Wl,-rpath,'$ORIGIN/../lib' \
+    -Wl,--audit,libaudit.so -Wl,--depaudit,libaudit-missing.so
@@ -0,0 +1,82 @@
+#!/usr/bin/env bash
+# Builds minimal root filesystems for foreign architectures, without needing a cross toolchain.
+# Everything is assembled for x86_64 and then e_machine (& e_flags) are patched, so these can be
+# parsed but not executed:
+#
+# aarch64/root (Debian-like, multiarch):
+#   /usr/bin/hello                                  needs libgreet.so, interpreter /lib/ld-linux-aarch64.so.1
+#   /lib/ld-linux-aarch64.so.1                      -> aarch64-linux-gnu/ld-linux-aarch64.so.1
+#   /lib/aarch64-linux-gnu/ld-linux-aarch64.so.1
+#   /lib/aarch64-linux-gnu/libc.so.6
+#   /usr/lib/aarch64-linux-gnu/libgreet.so          needs libc.so.6
+#   /lib64/libgreet.so                              x86_64 decoy, which must be skipped
+#
+# riscv64/root (Fedora-like, lp64d):
+#   /usr/bin/hello                                  needs libgreet.so, interpreter /lib/ld-linux-riscv64-lp64d.so.1
+#   /lib/ld-linux-riscv64-lp64d.so.1                -> ../usr/lib64/lp64d/ld-linux-riscv64-lp64d.so.1
+#   /usr/lib64/lp64d/ld-linux-riscv64-lp64d.so.1
+#   /usr/lib64/lp64d/libc.so.6
+#   /usr/lib64/lp64d/libgreet.so                    needs libc.so.6
+#
+# ppc64le/root:
+#   /usr/bin/hello                                  needs libgreet.so, interpreter /lib64/ld64.so.2
+#   /lib64/ld64.so.2
+#   /usr/lib64/libc.so.6
+#   /usr/lib64/libgreet.so                          needs libc.so.6
+set -euo pipefail
+
+SCRIPT_DIR="$(cd -- "$(dirname -- "${BASH_SOURCE[0]:-$0}")" && pwd)"
+cd "$SCRIPT_DIR" || exit 1
+
+SRC="$(mktemp -d)"
+trap 'rm -rf "$SRC"' EXIT
+
+cat > "$SRC/lib.s" <<'S'
+.globl greet
+.type greet, @function
+greet:
+  ret
+S
+
+cat > "$SRC/main.s" <<'S'
+.globl _start
+_start:
+  call greet@PLT
+  hlt
+S
+
+as --64 -o "$SRC/lib.o" "$SRC/lib.s"
+as --64 -o "$SRC/main.o" "$SRC/main.s"
+
+# patch FILE MACHINE FLAGS: overwrite e_machine & e_flags (little-endian, as all targets here are)
+patch() {
+  printf "$2" | dd of="$1" bs=1 seek=18 conv=notrunc status=none
+  printf "$3" | dd of="$1" bs=1 seek=48 conv=notrunc status=none
+}
+
+# build ROOT INTERP SLIBDIR USRLIBDIR MACHINE FLAGS
+#   interpreter & libc in SLIBDIR, libgreet.so in USRLIBDIR
+build() {
+  local root="$1" interp="$2" slibdir="$3" usrlibdir="$4" machine="$5" flags="$6"
+  rm -rf "$root"
+  mkdir -p "$root/usr/bin" "$root$slibdir" "$root$usrlibdir"
+  ld -shared -soname "$(basename "$interp")" -o "$root$slibdir/$(basename "$interp")" "$SRC/lib.o"
+  ld -shared -soname libc.so.6 -o "$root$slibdir/libc.so.6" "$SRC/lib.o"
+  ld -shared -soname libgreet.so -o "$root$usrlibdir/libgreet.so" "$SRC/lib.o" "$root$slibdir/libc.so.6"
+  ld -pie -dynamic-linker "$interp" -o "$root/usr/bin/hello" "$SRC/main.o" "$root$usrlibdir/libgreet.so"
+  for elf in "$root$slibdir/$(basename "$interp")" "$root$slibdir/libc.so.6" "$root$usrlibdir/libgreet.so" "$root/usr/bin/hello"; do
+    patch "$elf" "$machine" "$flags"
+  done
+}
+
+build aarch64/root /lib/ld-linux-aarch64.so.1 /lib/aarch64-linux-gnu /usr/lib/aarch64-linux-gnu '\xb7\x00' '\x00\x00\x00\x00'
+ln -s aarch64-linux-gnu/ld-linux-aarch64.so.1 aarch64/root/lib/ld-linux-aarch64.so.1
+mkdir -p aarch64/root/lib64
+ld -shared -soname libgreet.so -o aarch64/root/lib64/libgreet.so "$SRC/lib.o"
+
+build riscv64/root /lib/ld-linux-riscv64-lp64d.so.1 /usr/lib64/lp64d /usr/lib64/lp64d '\xf3\x00' '\x05\x00\x00\x00'
+mkdir -p riscv64/root/lib
+ln -s ../usr/lib64/lp64d/ld-linux-riscv64-lp64d.so.1 riscv64/root/lib/ld-linux-riscv64-lp64d.so.1
+
+build ppc64le/root /lib64/ld64.so.2 /lib64 /usr/lib64 '\x15\x00' '\x02\x00\x00\x00'
+mv ppc64le/root/lib64/libc.so.6 ppc64le/root/usr/lib64/libc.so.6
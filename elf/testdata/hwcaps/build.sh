@@ -0,0 +1,29 @@
+#!/usr/bin/env bash
+# Builds an executable which finds libhw.so via DT_RUNPATH, alongside glibc-hwcaps variants of libhw.so built for
+# x86-64-v2 & x86-64-v3, like those installed by distributions which optimise for newer CPUs
+set -euo pipefail
+
+SCRIPT_DIR="$(cd -- "$(dirname -- "${BASH_SOURCE[0]:-$0}")" && pwd)"
+cd "$SCRIPT_DIR" || exit 1
+
+SRC="$(mktemp -d)"
+trap 'rm -rf "$SRC"' EXIT
+
+cat > "$SRC/libhw.c" <<'C'
+int hw(void) { return LEVEL; }
+C
+
+cat > "$SRC/main.c" <<'C'
+int hw(void);
+int main(void) { return hw(); }
+C
+
+mkdir -p bin lib/glibc-hwcaps/x86-64-v2 lib/glibc-hwcaps/x86-64-v3
+
+gcc -shared -fPIC -DLEVEL=1 -Wl,-soname,libhw.so -o lib/libhw.so "$SRC/libhw.c"
+for level in 2 3; do
+    gcc -shared -fPIC -DLEVEL="$level" -march=x86-64-v"$level" -mneeded -Wl,-soname,libhw.so \
+        -o lib/glibc-hwcaps/x86-64-v"$level"/libhw.so "$SRC/libhw.c"
+done
+
+gcc -o bin/hw "$SRC/main.c" -Llib -lhw -Wl,-rpath,'$ORIGIN/../lib'
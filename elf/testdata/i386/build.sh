@@ -0,0 +1,41 @@
+#!/usr/bin/env bash
+# Builds a minimal i386 root filesystem, without needing a 32-bit libc or toolchain:
+#   /usr/bin/hello32                   needs libthirtytwo.so, interpreter /lib/ld-linux.so.2
+#   /lib/ld-linux.so.2                 stand-in for the 32-bit interpreter
+#   /usr/lib/libthirtytwo.so
+#   /lib/i386-linux-gnu/libthirtytwo.so 64-bit decoy, which must be skipped
+# These can be parsed but not executed.
+set -euo pipefail
+
+SCRIPT_DIR="$(cd -- "$(dirname -- "${BASH_SOURCE[0]:-$0}")" && pwd)"
+cd "$SCRIPT_DIR" || exit 1
+
+SRC="$(mktemp -d)"
+trap 'rm -rf "$SRC"' EXIT
+
+cat > "$SRC/lib.s" <<'S'
+.globl thirtytwo
+.type thirtytwo, @function
+thirtytwo:
+  ret
+S
+
+cat > "$SRC/main.s" <<'S'
+.globl _start
+_start:
+  call thirtytwo@PLT
+  hlt
+S
+
+rm -rf root
+mkdir -p root/usr/bin root/usr/lib root/lib/i386-linux-gnu
+
+as --32 -o "$SRC/lib32.o" "$SRC/lib.s"
+as --64 -o "$SRC/lib64.o" "$SRC/lib.s"
+as --32 -o "$SRC/main.o" "$SRC/main.s"
+
+ld -m elf_i386 -shared -soname ld-linux.so.2 -o root/lib/ld-linux.so.2 "$SRC/lib32.o"
+ld -m elf_i386 -shared -soname libthirtytwo.so -o root/usr/lib/libthirtytwo.so "$SRC/lib32.o"
+ld -m elf_x86_64 -shared -soname libthirtytwo.so -o root/lib/i386-linux-gnu/libthirtytwo.so "$SRC/lib64.o"
+ld -m elf_i386 -pie -dynamic-linker /lib/ld-linux.so.2 -o root/usr/bin/hello32 "$SRC/main.o" \
+  -Lroot/usr/lib -lthirtytwo
@@ -0,0 +1,18 @@
+#!/usr/bin/env bash
+# Builds a minimal sysroot and ld.so.cache in each of the formats which ldconfig can generate
+set -euo pipefail
+
+SCRIPT_DIR="$(cd -- "$(dirname -- "${BASH_SOURCE[0]:-$0}")" && pwd)"
+cd "$SCRIPT_DIR" || exit 1
+
+rm -rf root
+mkdir -p root/etc root/usr/lib64/glibc-hwcaps/x86-64-v3
+
+cp ../rpath/lib/liba.so ../rpath/lib/libb.so root/usr/lib64/
+cp ../rpath/lib/liba.so root/usr/lib64/glibc-hwcaps/x86-64-v3/
+echo "/usr/lib64" > root/etc/ld.so.conf
+
+for format in new old compat; do
+  ldconfig -X -r root -c "$format" -C "/etc/ld.so.cache.$format"
+done
+cp root/etc/ld.so.cache.new root/etc/ld.so.cache
@@ -0,0 +1,50 @@
+#!/usr/bin/env bash
+# Builds a minimal Alpine-like (musl) root filesystem, without needing a musl toolchain:
+#   /usr/bin/hello_musl              needs libgreet.so, libc.musl-x86_64.so.1 & libpthread.so.0,
+#                                    interpreter /lib/ld-musl-x86_64.so.1
+#   /lib/ld-musl-x86_64.so.1         stand-in for musl, which is both the interpreter and libc
+#   /lib/libc.musl-x86_64.so.1       -> ld-musl-x86_64.so.1
+#   /etc/ld-musl-x86_64.path         /opt/musl/lib & /usr/local/lib
+#   /opt/musl/lib/libgreet.so        needs libc.musl-x86_64.so.1
+#   /lib/libgreet.so                 decoy, /lib is not in ld-musl-x86_64.path so must be skipped
+# libpthread.so.0 is provided by musl itself and therefore not present.
+# These can be parsed but not executed.
+set -euo pipefail
+
+SCRIPT_DIR="$(cd -- "$(dirname -- "${BASH_SOURCE[0]:-$0}")" && pwd)"
+cd "$SCRIPT_DIR" || exit 1
+
+SRC="$(mktemp -d)"
+trap 'rm -rf "$SRC"' EXIT
+
+cat > "$SRC/lib.s" <<'S'
+.globl greet
+.type greet, @function
+greet:
+  ret
+S
+
+cat > "$SRC/main.s" <<'S'
+.globl _start
+_start:
+  call greet@PLT
+  hlt
+S
+
+rm -rf root
+mkdir -p root/usr/bin root/lib root/etc root/opt/musl/lib
+
+as --64 -o "$SRC/lib.o" "$SRC/lib.s"
+as --64 -o "$SRC/main.o" "$SRC/main.s"
+
+ld -shared -soname libc.musl-x86_64.so.1 -o root/lib/ld-musl-x86_64.so.1 "$SRC/lib.o"
+ln -s ld-musl-x86_64.so.1 root/lib/libc.musl-x86_64.so.1
+ld -shared -soname libpthread.so.0 -o "$SRC/libpthread.so" "$SRC/lib.o"
+
+ld -shared -soname libgreet.so -o root/opt/musl/lib/libgreet.so "$SRC/lib.o" root/lib/ld-musl-x86_64.so.1
+ld -shared -soname libgreet.so -o root/lib/libgreet.so "$SRC/lib.o"
+
+ld -pie -dynamic-linker /lib/ld-musl-x86_64.so.1 -o root/usr/bin/hello_musl "$SRC/main.o" \
+  root/opt/musl/lib/libgreet.so root/lib/ld-musl-x86_64.so.1 "$SRC/libpthread.so"
+
+printf '/opt/musl/lib\n/usr/local/lib\n' > root/etc/ld-musl-x86_64.path
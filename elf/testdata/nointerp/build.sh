@@ -0,0 +1,21 @@
+#!/usr/bin/env bash
+# Builds dynamically linked executables which are missing PT_INTERP, so cannot be run:
+#   - bin/pie is a PIE (DF_1_PIE) which still needs libc.so.6, so is not a static-PIE
+#   - bin/exec is an ET_EXEC with dynamic symbols
+set -euo pipefail
+
+SCRIPT_DIR="$(cd -- "$(dirname -- "${BASH_SOURCE[0]:-$0}")" && pwd)"
+cd "$SCRIPT_DIR" || exit 1
+
+SRC="$(mktemp -d)"
+trap 'rm -rf "$SRC"' EXIT
+
+cat > "$SRC/main.c" <<'C'
+#include <stdio.h>
+int main(void) { puts("unreachable"); return 0; }
+C
+
+mkdir -p bin
+
+gcc -fPIE -pie -o bin/pie "$SRC/main.c" -Wl,--no-dynamic-linker
+gcc -fno-PIE -no-pie -o bin/exec "$SRC/main.c" -Wl,--no-dynamic-linker
@@ -0,0 +1,28 @@
+#!/usr/bin/env bash
+# Builds binaries whose DT_NEEDED entries are paths, rather than sonames to search for:
+#   - bin/app needs /opt/vendor/lib/libvendor.so (absolute) & plugins/libplugin.so (relative)
+#   - bin/relative only needs plugins/libplugin.so, so it can be cross-checked on the host
+set -euo pipefail
+
+SCRIPT_DIR="$(cd -- "$(dirname -- "${BASH_SOURCE[0]:-$0}")" && pwd)"
+cd "$SCRIPT_DIR" || exit 1
+
+SRC="$(mktemp -d)"
+trap 'rm -rf "$SRC"' EXIT
+
+cat > "$SRC/lib.c" <<'C'
+int lib(void) { return 0; }
+C
+
+cat > "$SRC/main.c" <<'C'
+int main(void) { return 0; }
+C
+
+mkdir -p bin/plugins opt/vendor/lib
+
+# A soname containing a `/` is used verbatim as DT_NEEDED by anything linking against it
+gcc -shared -fPIC -Wl,-soname,/opt/vendor/lib/libvendor.so -o opt/vendor/lib/libvendor.so "$SRC/lib.c"
+gcc -shared -fPIC -Wl,-soname,plugins/libplugin.so -o bin/plugins/libplugin.so "$SRC/lib.c"
+
+gcc -o bin/app "$SRC/main.c" -Wl,--no-as-needed opt/vendor/lib/libvendor.so bin/plugins/libplugin.so
+gcc -o bin/relative "$SRC/main.c" -Wl,--no-as-needed bin/plugins/libplugin.so
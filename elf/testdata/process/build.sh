@@ -0,0 +1,46 @@
+#!/usr/bin/env bash
+# Builds an executable which dlopen()s a plugin, which is not declared anywhere, then waits to be killed.
+#
+#   - bin/plugged loads lib/libplugged-plugin.so (via RUNPATH $ORIGIN/../lib), which needs lib/libplugged-dep.so,
+#     then prints "ready" and pauses
+set -euo pipefail
+
+SCRIPT_DIR="$(cd -- "$(dirname -- "${BASH_SOURCE[0]:-$0}")" && pwd)"
+cd "$SCRIPT_DIR" || exit 1
+
+SRC="$(mktemp -d)"
+trap 'rm -rf "$SRC"' EXIT
+
+cat > "$SRC/plugged.c" <<'C'
+#include <dlfcn.h>
+#include <stdio.h>
+#include <unistd.h>
+int main(void) {
+    char plugin[] = "libplugged-";
+    char name[64];
+    snprintf(name, sizeof(name), "%splugin.so", plugin); /* not visible in .rodata */
+    if (dlopen(name, RTLD_NOW) == 0) {
+        return 1;
+    }
+    puts("ready");
+    fflush(stdout);
+    pause();
+    return 0;
+}
+C
+
+cat > "$SRC/plugin-dep.c" <<'C'
+int plugged_dep(void) { return 0; }
+C
+
+cat > "$SRC/plugin.c" <<'C'
+int plugged_dep(void);
+int plugged(void) { return plugged_dep(); }
+C
+
+mkdir -p bin lib
+
+gcc -shared -fPIC -Wl,-soname,libplugged-dep.so -o lib/libplugged-dep.so "$SRC/plugin-dep.c"
+gcc -shared -fPIC -Wl,-soname,libplugged-plugin.so -o lib/libplugged-plugin.so "$SRC/plugin.c" \
+    -Llib -lplugged-dep -Wl,-rpath,'$ORIGIN'
+gcc -o bin/plugged "$SRC/plugged.c" -Wl,-rpath,'$ORIGIN/../lib'
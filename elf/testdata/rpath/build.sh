@@ -0,0 +1,40 @@
+#!/usr/bin/env bash
+# Builds binaries which locate their libraries via DT_RPATH (transitive) and DT_RUNPATH (not transitive),
+# and one which needs libraries which do not exist
+set -euo pipefail
+
+SCRIPT_DIR="$(cd -- "$(dirname -- "${BASH_SOURCE[0]:-$0}")" && pwd)"
+cd "$SCRIPT_DIR" || exit 1
+
+SRC="$(mktemp -d)"
+trap 'rm -rf "$SRC"' EXIT
+
+cat > "$SRC/b.c" <<'C'
+int b(void) { return 0; }
+C
+
+cat > "$SRC/a.c" <<'C'
+int b(void);
+int a(void) { return b(); }
+C
+
+cat > "$SRC/main.c" <<'C'
+int a(void);
+int main(void) { return a(); }
+C
+
+mkdir -p bin lib
+
+gcc -shared -fPIC -Wl,-soname,libb.so -o lib/libb.so "$SRC/b.c"
+
+# No DT_RPATH or DT_RUNPATH of its own, libb.so can only be found via the executable's DT_RPATH
+gcc -shared -fPIC -Wl,-soname,liba.so -o lib/liba.so "$SRC/a.c" -Llib -lb
+
+gcc -o bin/rpath "$SRC/main.c" -Llib -la -Wl,--disable-new-dtags,-rpath,'$ORIGIN/../lib'
+
+gcc -o bin/runpath "$SRC/main.c" -Llib -la -Wl,--enable-new-dtags,-rpath,'$ORIGIN/../lib'
+
+# Needs two libraries which do not exist anywhere
+gcc -shared -fPIC -Wl,-soname,libmissing1.so -o "$SRC/libmissing1.so" "$SRC/b.c"
+gcc -shared -fPIC -Wl,-soname,libmissing2.so -o "$SRC/libmissing2.so" "$SRC/b.c"
+gcc -o bin/missing "$SRC/main.c" -Wl,--no-as-needed -Llib -la -L"$SRC" -lmissing1 -lmissing2 -Wl,-rpath,'$ORIGIN/../lib'
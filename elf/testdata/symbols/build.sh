@@ -0,0 +1,42 @@
+#!/usr/bin/env bash
+# Builds an executable against a newer version of libsym.so than the one it will load: it needs
+# newfunc@SYM_2.0 which the bundled libsym.so does not provide, like a binary linked against a newer glibc
+set -euo pipefail
+
+SCRIPT_DIR="$(cd -- "$(dirname -- "${BASH_SOURCE[0]:-$0}")" && pwd)"
+cd "$SCRIPT_DIR" || exit 1
+
+SRC="$(mktemp -d)"
+trap 'rm -rf "$SRC"' EXIT
+
+cat > "$SRC/old.c" <<'C'
+int oldfunc(void) { return 1; }
+C
+
+cat > "$SRC/old.map" <<'MAP'
+SYM_1.0 { global: oldfunc; local: *; };
+MAP
+
+cat > "$SRC/new.c" <<'C'
+int oldfunc(void) { return 1; }
+int newfunc(void) { return 2; }
+C
+
+cat > "$SRC/new.map" <<'MAP'
+SYM_1.0 { global: oldfunc; local: *; };
+SYM_2.0 { global: newfunc; } SYM_1.0;
+MAP
+
+cat > "$SRC/main.c" <<'C'
+int oldfunc(void);
+int newfunc(void);
+int main(void) { return oldfunc() + newfunc(); }
+C
+
+mkdir -p bin lib
+
+gcc -shared -fPIC -Wl,-soname,libsym.so -Wl,--version-script,"$SRC/old.map" -o lib/libsym.so "$SRC/old.c"
+gcc -shared -fPIC -Wl,-soname,libsym.so -Wl,--version-script,"$SRC/new.map" -o "$SRC/libsym.so" "$SRC/new.c"
+
+# Links against the new libsym.so, but finds the old one at runtime
+gcc -o bin/newer "$SRC/main.c" -L"$SRC" -lsym -Wl,-rpath,'$ORIGIN/../lib'
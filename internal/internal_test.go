@@ -7,7 +7,9 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"syscall"
 	"testing"
+	"testing/fstest"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -178,3 +180,68 @@ and me
 
 	Assert.Equal(string(expected), string(updated))
 }
+
+func TestEvalSymlinksIn(t *testing.T) {
+	Assert := assert.New(t)
+	root := t.TempDir()
+	Assert.NoError(os.MkdirAll(filepath.Join(root, "usr/lib"), 0775))
+	Assert.NoError(os.WriteFile(filepath.Join(root, "usr/lib/libfoo.so"), nil, 0664))
+	Assert.NoError(os.Symlink("/usr/lib", filepath.Join(root, "lib")))          // absolute, within root
+	Assert.NoError(os.Symlink("../../../../../usr", filepath.Join(root, "up"))) // escapes root
+	Assert.NoError(os.Symlink("loop", filepath.Join(root, "loop")))
+
+	expected := filepath.Join(root, "usr/lib/libfoo.so")
+	for _, path := range []string{"/lib/libfoo.so", "lib/libfoo.so", "/../../lib/libfoo.so", "/up/lib/libfoo.so"} {
+		resolved, err := EvalSymlinksIn(root, path)
+		Assert.NoError(err, path)
+		Assert.Equal(expected, resolved, path)
+		Assert.Equal("/usr/lib/libfoo.so", InRoot(root, resolved))
+	}
+
+	_, err := EvalSymlinksIn(root, "/loop")
+	Assert.ErrorIs(err, syscall.ELOOP)
+
+	_, err = EvalSymlinksIn(root, "/lib/libbar.so")
+	Assert.ErrorIs(err, fs.ErrNotExist)
+}
+
+func TestEvalSymlinksInFS(t *testing.T) {
+	Assert := assert.New(t)
+	fsys := fstest.MapFS{
+		"usr/lib/libfoo.so": {},
+		"lib":               {Data: []byte("/usr/lib"), Mode: fs.ModeSymlink},
+		"up":                {Data: []byte("../../../../../usr"), Mode: fs.ModeSymlink},
+		"loop":              {Data: []byte("loop"), Mode: fs.ModeSymlink},
+	}
+
+	for _, path := range []string{"/lib/libfoo.so", "lib/libfoo.so", "/../../lib/libfoo.so", "/up/lib/libfoo.so"} {
+		resolved, err := EvalSymlinksInFS(fsys, path)
+		Assert.NoError(err, path)
+		Assert.Equal("/usr/lib/libfoo.so", resolved, path)
+	}
+
+	_, err := EvalSymlinksInFS(fsys, "/loop")
+	Assert.ErrorIs(err, syscall.ELOOP)
+
+	_, err = EvalSymlinksInFS(fsys, "/lib/libbar.so")
+	Assert.ErrorIs(err, fs.ErrNotExist)
+}
+
+func TestResolveIn(t *testing.T) {
+	Assert := assert.New(t)
+	root := t.TempDir()
+	Assert.NoError(os.MkdirAll(filepath.Join(root, "usr/lib64"), 0775))
+	Assert.NoError(os.Symlink("usr/lib64", filepath.Join(root, "lib64"))) // usr-merge
+
+	tests := map[string]string{
+		"/lib64":                        "usr/lib64",
+		"/lib64/ld-linux-x86-64.so.2":   "usr/lib64/ld-linux-x86-64.so.2",
+		"/lib/ld-musl-x86_64.so.1":      "lib/ld-musl-x86_64.so.1",
+		"/opt/missing/../../../../etc/": "etc",
+	}
+	for path, expected := range tests {
+		resolved, err := ResolveIn(root, path)
+		Assert.NoError(err, path)
+		Assert.Equal(filepath.Join(root, expected), resolved, path)
+	}
+}
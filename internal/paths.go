@@ -8,9 +8,22 @@ import (
 // Regex to check if this is a 64-bit version of `ld-linux*.so`, matches /lib64(/more/directories)/ld-linux*.so(.*)
 var Ld_linux_64_RE = regexp.MustCompile(`^\/lib64(?:\/.+|)\/ld-linux.*\.so(?:\..+|)$`)
 
+// Regex to check if this is a 32-bit version of `ld-linux*.so`, matches /lib(32)(/more/directories)/ld-linux.so.2
+var Ld_linux_32_RE = regexp.MustCompile(`^\/lib(?:32|)(?:\/.+|)\/ld-linux\.so\.2$`)
+
+// Regex to check if this is a glibc interpreter for any architecture, matches (/more/directories)/ld-linux*.so.N or
+// (/more/directories)/ld64.so.N (ppc64 & s390x)
+var Ld_linux_RE = regexp.MustCompile(`^(?:\/.+|)\/ld(?:-linux[^/]*|64)\.so\.[0-9]+$`)
+
+// Regex to check if this is musl's `ld-musl-<arch>.so.1`, matches (/more/directories)/ld-musl-<arch>.so.1
+var Ld_musl_RE = regexp.MustCompile(`^(?:\/.+|)\/ld-musl-[^/]+\.so\.1$`)
+
 // Path to interpreter
 const P_ld_linux = "/lib64/ld-linux-x86-64.so.2"
 
+// Path to 32-bit (i386) interpreter
+const P_ld_linux_32 = "/lib/ld-linux.so.2"
+
 var P_ld_linux_resolved, _ = filepath.EvalSymlinks(P_ld_linux)
 
 // Paths to common libraries
@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Maximum number of symlinks to follow before giving up, the same as the linux kernel
+const maxSymlinks = 40
+
+// Like [filepath.EvalSymlinks] but treats root as `/`, in the same way as chroot.
+//
+//   - path is relative to root, even if it is absolute, and can never escape it via `..` or a symlink
+//   - Returns the resolved path on the host, i.e. including root
+//   - If root is "" this is identical to [filepath.EvalSymlinks]
+func EvalSymlinksIn(root string, path string) (string, error) {
+	if root == "" {
+		return filepath.EvalSymlinks(path)
+	}
+	return evalSymlinksIn(root, path, false)
+}
+
+// Like [EvalSymlinksIn] but within fsys, treating it as `/`.
+//
+//   - Returns the resolved path as an absolute path within fsys, e.g. "/usr/lib/libc.so.6"
+//   - Symlinks can only be followed if fsys implements [fs.ReadLinkFS], otherwise they are treated as the
+//     files they point to
+func EvalSymlinksInFS(fsys fs.FS, path string) (string, error) {
+	return walkSymlinks(path, false,
+		func(path string) (fs.FileInfo, error) { return fs.Lstat(fsys, FSPath(path)) },
+		func(path string) (string, error) { return fs.ReadLink(fsys, FSPath(path)) },
+	)
+}
+
+// The name used by fsys for the absolute path, within fsys, path. E.g. "usr/lib" for "/usr/lib"
+func FSPath(path string) string {
+	name := strings.TrimPrefix(filepath.Clean("/"+path), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+// Like [EvalSymlinksIn] but path does not need to exist: symlinks are followed as far as possible and
+// any missing components are appended unchanged.
+//
+// Use this to find where a file should be created under root, e.g. following usr-merge symlinks such as
+// `lib64 -> usr/lib64`.
+func ResolveIn(root string, path string) (string, error) {
+	return evalSymlinksIn(root, path, true)
+}
+
+func evalSymlinksIn(root string, path string, allowMissing bool) (string, error) {
+	resolved, err := walkSymlinks(path, allowMissing,
+		func(path string) (fs.FileInfo, error) { return os.Lstat(filepath.Join(root, path)) },
+		func(path string) (string, error) { return os.Readlink(filepath.Join(root, path)) },
+	)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, resolved), nil
+}
+
+// Follows every symlink in path, returning the resolved path. lstat & readlink are given paths which
+// are absolute within whatever is being treated as `/`.
+func walkSymlinks(path string, allowMissing bool, lstat func(string) (fs.FileInfo, error), readlink func(string) (string, error)) (string, error) {
+	resolved := "/"
+	remaining := strings.Split(path, "/")
+	links := 0
+	missing := false
+	for len(remaining) > 0 {
+		component := remaining[0]
+		remaining = remaining[1:]
+
+		switch component {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved) // Dir("/") == "/"
+			continue
+		}
+
+		next := filepath.Join(resolved, component)
+		if missing {
+			resolved = next
+			continue
+		}
+		info, err := lstat(next)
+		if allowMissing && errors.Is(err, fs.ErrNotExist) {
+			missing = true // nothing further can exist, but still never escape root
+			resolved = next
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&fs.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", &fs.PathError{Op: "lstat", Path: path, Err: syscall.ELOOP}
+		}
+		target, err := readlink(next)
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			resolved = "/"
+		}
+		remaining = append(strings.Split(target, "/"), remaining...)
+	}
+	return resolved, nil
+}
+
+// The path within root which refers to host, i.e. the inverse of `filepath.Join(root, path)`.
+//
+//   - Returns host unchanged if root is ""
+func InRoot(root string, host string) string {
+	if root == "" {
+		return host
+	}
+	return "/" + strings.TrimLeft(strings.TrimPrefix(host, root), "/")
+}
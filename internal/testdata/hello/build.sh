@@ -13,5 +13,8 @@ CGO_ENABLED=0 GOOS=linux GOARCH=amd64 \
 CGO_ENABLED=1 GOOS=linux GOARCH=amd64 \
   go build -ldflags '-linkmode=external' -buildmode=exe -o ../hello_dynamic hello.go
 
+CGO_ENABLED=1 GOOS=linux GOARCH=amd64 \
+  go build -ldflags '-s -w -linkmode=external -extldflags=-static-pie' -buildmode=pie -o ../hello_static_pie hello.go
+
 CGO_ENABLED=1 \
   go build -ldflags '-linkmode=external' -buildmode=pie .
@@ -0,0 +1,34 @@
+#!/usr/bin/env bash
+# Builds a binary which locates its libraries via DT_RUNPATH, relative to $ORIGIN
+set -euo pipefail
+
+SCRIPT_DIR="$(cd -- "$(dirname -- "${BASH_SOURCE[0]:-$0}")" && pwd)"
+cd "$SCRIPT_DIR" || exit 1
+
+SRC="$(mktemp -d)"
+trap 'rm -rf "$SRC"' EXIT
+
+cat > "$SRC/greet.c" <<'C'
+#include <stdio.h>
+void greet(const char *name) { printf("hello %s\n", name); }
+C
+
+cat > "$SRC/hello.c" <<'C'
+void greet(const char *name);
+void hello(void) { greet("runpath"); }
+C
+
+cat > "$SRC/main.c" <<'C'
+void hello(void);
+int main(void) { hello(); return 0; }
+C
+
+mkdir -p bin lib64
+
+gcc -shared -fPIC -Wl,-soname,libgreet.so -o lib64/libgreet.so "$SRC/greet.c"
+
+gcc -shared -fPIC -Wl,-soname,libhello.so -o lib64/libhello.so "$SRC/hello.c" \
+  -Llib64 -lgreet -Wl,--enable-new-dtags,-rpath,'${ORIGIN}'
+
+gcc -o bin/hello_runpath "$SRC/main.c" \
+  -Llib64 -lhello -Wl,--enable-new-dtags,-rpath,'$ORIGIN/../lib64'
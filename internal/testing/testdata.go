@@ -3,6 +3,8 @@
 package testing
 
 import (
+	debug_elf "debug/elf"
+	"path/filepath"
 	"slices"
 	"strings"
 
@@ -42,10 +44,18 @@ type TestDetails struct {
 	HasInterpreter bool
 	Elf            elf.Elf
 	StdErr         string
+	LibDirs        map[string]string // Directory, relative to the snagged file, for dependencies located via $ORIGIN
 }
 
 type testListing = map[string]TestDetails
 
+// Dependencies located via `$ORIGIN` keep the unclean path, exactly as ld.so would report it
+var (
+	p_runpath_libgreet  = filepath.Dir(P_hello_runpath) + "/../lib64/libgreet.so"
+	p_runpath_libhello  = filepath.Dir(P_hello_runpath) + "/../lib64/libhello.so"
+	p_libhello_libgreet = filepath.Dir(P_libhello) + "/libgreet.so"
+)
+
 var TestData = testListing{
 	P_ctypes_so: {
 		Name:           "dyn_lib",
@@ -60,9 +70,18 @@ var TestData = testListing{
 			Name:         "_ctypes_test.cpython-314-x86_64-linux-gnu.so",
 			Path:         P_ctypes_so,
 			Class:        elf.EI_CLASS(elf.ELF64),
+			Machine:      debug_elf.EM_X86_64,
 			Type:         elf.Type(elf.DYN),
 			Interpreter:  "",
+			Libc:         elf.GLIBC,
+			Needed:       []string{"libm.so.6", "libpthread.so.0", "libc.so.6", "ld-linux-x86-64.so.2"},
+			GlibcNeeded:  "GLIBC_2.14",
 			Dependencies: []string{P_libc, P_libm, P_libpthread},
+			Libraries: []elf.Library{
+				{Soname: "libc.so.6", Path: P_libc, NeededBy: P_ctypes_so},
+				{Soname: "libm.so.6", Path: P_libm, NeededBy: P_ctypes_so},
+				{Soname: "libpthread.so.0", Path: P_libpthread, NeededBy: P_ctypes_so},
+			},
 		},
 	},
 	P_empty: {
@@ -94,9 +113,20 @@ var TestData = testListing{
 			Name:         "hello",
 			Path:         P_hello_pie_cgo,
 			Class:        elf.EI_CLASS(elf.ELF64),
+			Machine:      debug_elf.EM_X86_64,
 			Type:         elf.DYNEXE,
+			Entry:        0x670,
 			Interpreter:  P_ld_linux,
+			Libc:         elf.GLIBC,
+			Needed:       []string{"libc.so.6"},
+			BuildID:      "21136447ead673b02b8e173c7de9d93573a9ad4e",
+			GlibcNeeded:  "GLIBC_2.34",
+			MinKernel:    "3.2.0",
+			ISALevel:     1,
 			Dependencies: []string{P_libc},
+			Libraries: []elf.Library{
+				{Soname: "libc.so.6", Path: P_libc, NeededBy: P_hello_pie_cgo},
+			},
 		},
 		Dynamic:        true,
 		Exe:            true,
@@ -117,9 +147,20 @@ var TestData = testListing{
 			Name:         "hello_dynamic",
 			Path:         P_hello_dynamic,
 			Class:        elf.EI_CLASS(elf.ELF64),
+			Machine:      debug_elf.EM_X86_64,
 			Type:         elf.DYNEXE,
+			Entry:        0x400670,
 			Interpreter:  P_ld_linux,
+			Libc:         elf.GLIBC,
+			Needed:       []string{"libc.so.6"},
+			BuildID:      "f002f878774ea37309162cb8b78bb50cbae93005",
+			GlibcNeeded:  "GLIBC_2.34",
+			MinKernel:    "3.2.0",
+			ISALevel:     1,
 			Dependencies: []string{P_libc},
+			Libraries: []elf.Library{
+				{Soname: "libc.so.6", Path: P_libc, NeededBy: P_hello_dynamic},
+			},
 		},
 		Dynamic:        true,
 		Exe:            true,
@@ -135,8 +176,13 @@ var TestData = testListing{
 			Name:         "hello_pie",
 			Path:         P_hello_pie,
 			Class:        elf.EI_CLASS(elf.ELF64),
+			Machine:      debug_elf.EM_X86_64,
 			Type:         elf.Type(elf.DYNEXE),
+			Entry:        0x46f5e0,
 			Interpreter:  P_ld_linux,
+			Libc:         elf.GLIBC,
+			BuildID:      "612230d484b75e3578ce71ec0b3f3f252d97c029",
+			Stripped:     true,
 			Dependencies: nil,
 		},
 		Dynamic:        true,
@@ -153,8 +199,36 @@ var TestData = testListing{
 			Name:         "hello_static",
 			Path:         P_hello_static,
 			Class:        elf.EI_CLASS(elf.ELF64),
+			Machine:      debug_elf.EM_X86_64,
 			Type:         elf.Type(elf.EXEC),
+			Entry:        0x46f200,
+			Interpreter:  "",
+			BuildID:      "05f53f62d1976cfd9f02f1146625142c52337d6f",
+			Stripped:     true,
+			Dependencies: nil,
+		},
+		Dynamic:        false,
+		Exe:            true,
+		Lib:            false,
+		HasInterpreter: false,
+	},
+	P_hello_static_pie: {
+		Name:   "static_PIE",
+		Path:   P_hello_static_pie,
+		SnagTo: "bin",
+		SnagAs: "hello_static_pie",
+		Elf: elf.Elf{
+			Name:         "hello_static_pie",
+			Path:         P_hello_static_pie,
+			Class:        elf.EI_CLASS(elf.ELF64),
+			Machine:      debug_elf.EM_X86_64,
+			Type:         elf.Type(elf.STATICPIE),
+			Entry:        0x2f6f0,
 			Interpreter:  "",
+			BuildID:      "318c866f939ee9de8c6c2ff8d1db2f02cb205de9",
+			Stripped:     true,
+			MinKernel:    "3.2.0",
+			ISALevel:     1,
 			Dependencies: nil,
 		},
 		Dynamic:        false,
@@ -171,9 +245,23 @@ var TestData = testListing{
 			Name:         "id",
 			Path:         P_id,
 			Class:        elf.EI_CLASS(elf.ELF64),
+			Machine:      debug_elf.EM_X86_64,
 			Type:         elf.Type(elf.DYNEXE),
+			Entry:        0x1a60,
 			Interpreter:  P_ld_linux,
+			Libc:         elf.GLIBC,
+			Needed:       []string{"libselinux.so.1", "libc.so.6"},
+			BuildID:      "2e73c291a805d29d1a4e589b68c99a17217727ba",
+			Stripped:     true,
+			GlibcNeeded:  "GLIBC_2.38",
+			MinKernel:    "3.2.0",
+			ISALevel:     1,
 			Dependencies: []string{P_libc, P_libpcre2_8, P_libselinux},
+			Libraries: []elf.Library{
+				{Soname: "libc.so.6", Path: P_libc, NeededBy: P_id},
+				{Soname: "libpcre2-8.so.0", Path: P_libpcre2_8, NeededBy: P_libselinux},
+				{Soname: "libselinux.so.1", Path: P_libselinux, NeededBy: P_id},
+			},
 		},
 		Dynamic:        true,
 		Exe:            true,
@@ -198,6 +286,108 @@ var TestData = testListing{
 		HasInterpreter: false,
 		StdErr:         "invalid ELF file: bad magic number '[35 33 47 117]' in record at byte 0x0",
 	},
+	P_hello_runpath: {
+		Name:     "runpath",
+		Path:     P_hello_runpath,
+		SnagTo:   "bin",
+		SnagAs:   "hello_runpath",
+		InSubdir: true,
+		Elf: elf.Elf{
+			Name:         "hello_runpath",
+			Path:         P_hello_runpath,
+			Class:        elf.EI_CLASS(elf.ELF64),
+			Machine:      debug_elf.EM_X86_64,
+			Type:         elf.Type(elf.DYNEXE),
+			Entry:        0x1050,
+			Interpreter:  P_ld_linux,
+			Libc:         elf.GLIBC,
+			Needed:       []string{"libhello.so", "libc.so.6"},
+			Runpath:      []string{"$ORIGIN/../lib64"},
+			BuildID:      "a79769e43a7bcfa62fb79a86d958002a3cf13957",
+			GlibcNeeded:  "GLIBC_2.34",
+			MinKernel:    "3.2.0",
+			ISALevel:     1,
+			Dependencies: []string{P_libc, p_runpath_libgreet, p_runpath_libhello},
+			Libraries: []elf.Library{
+				{Soname: "libc.so.6", Path: P_libc, NeededBy: P_hello_runpath},
+				{Soname: "libgreet.so", Path: p_runpath_libgreet, NeededBy: p_runpath_libhello},
+				{Soname: "libhello.so", Path: p_runpath_libhello, NeededBy: P_hello_runpath},
+			},
+		},
+		LibDirs: map[string]string{
+			p_runpath_libgreet: "../lib64",
+			p_runpath_libhello: "../lib64",
+		},
+		Dynamic:        true,
+		Exe:            true,
+		Lib:            false,
+		HasInterpreter: true,
+	},
+	P_libgreet: {
+		Name:     "rpath_lib",
+		Path:     P_libgreet,
+		SnagTo:   "lib64",
+		SnagAs:   "libgreet.so",
+		InSubdir: true,
+		Elf: elf.Elf{
+			Name:         "libgreet.so",
+			Path:         P_libgreet,
+			Class:        elf.EI_CLASS(elf.ELF64),
+			Machine:      debug_elf.EM_X86_64,
+			Type:         elf.Type(elf.DYN),
+			Interpreter:  "",
+			Libc:         elf.GLIBC,
+			Soname:       "libgreet.so",
+			Needed:       []string{"libc.so.6"},
+			BuildID:      "00835dc12ebee16cad2a048a4d975b44fed51864",
+			GlibcNeeded:  "GLIBC_2.2.5",
+			Dependencies: []string{P_libc},
+			Libraries: []elf.Library{
+				{Soname: "libc.so.6", Path: P_libc, NeededBy: P_libgreet},
+			},
+		},
+		Dynamic:        true,
+		Exe:            false,
+		Lib:            true,
+		HasInterpreter: false,
+	},
+	P_libhello: {
+		Name:     "runpath_lib",
+		Path:     P_libhello,
+		SnagTo:   "lib64",
+		SnagAs:   "libhello.so",
+		InSubdir: true,
+		Elf: elf.Elf{
+			Name:         "libhello.so",
+			Path:         P_libhello,
+			Class:        elf.EI_CLASS(elf.ELF64),
+			Machine:      debug_elf.EM_X86_64,
+			Type:         elf.Type(elf.DYN),
+			Interpreter:  "",
+			Libc:         elf.GLIBC,
+			Soname:       "libhello.so",
+			Needed:       []string{"libgreet.so"},
+			Runpath:      []string{"${ORIGIN}"},
+			BuildID:      "e22fc5a7d26d8962484c8ae80df57be555438851",
+			Dependencies: []string{P_libc, p_libhello_libgreet},
+			Libraries: []elf.Library{
+				{Soname: "libc.so.6", Path: P_libc, NeededBy: P_libgreet},
+				{Soname: "libgreet.so", Path: p_libhello_libgreet, NeededBy: P_libhello},
+			},
+		},
+		LibDirs: map[string]string{
+			p_libhello_libgreet: ".",
+		},
+		Dynamic:        true,
+		Exe:            false,
+		Lib:            true,
+		HasInterpreter: false,
+	},
+	P_rpath_sh: {
+		Path:     P_rpath_sh,
+		InSubdir: true,
+		NonElf:   true,
+	},
 	P_symlinked_build_sh: {
 		Path:     P_symlinked_build_sh,
 		Elf:      elf.Elf{Path: P_build_sh},
@@ -218,9 +408,20 @@ var TestData = testListing{
 			Name:         "hello",
 			Path:         P_hello_pie_cgo,
 			Class:        elf.EI_CLASS(elf.ELF64),
+			Machine:      debug_elf.EM_X86_64,
 			Type:         elf.DYNEXE,
+			Entry:        0x670,
 			Interpreter:  P_ld_linux,
+			Libc:         elf.GLIBC,
+			Needed:       []string{"libc.so.6"},
+			BuildID:      "21136447ead673b02b8e173c7de9d93573a9ad4e",
+			GlibcNeeded:  "GLIBC_2.34",
+			MinKernel:    "3.2.0",
+			ISALevel:     1,
 			Dependencies: []string{P_libc},
+			Libraries: []elf.Library{
+				{Soname: "libc.so.6", Path: P_libc, NeededBy: P_hello_pie_cgo},
+			},
 		},
 		Dynamic:        true,
 		Exe:            true,
@@ -245,9 +446,23 @@ var TestData = testListing{
 			Name:         "id",
 			Path:         P_id,
 			Class:        elf.EI_CLASS(elf.ELF64),
+			Machine:      debug_elf.EM_X86_64,
 			Type:         elf.Type(elf.DYNEXE),
+			Entry:        0x1a60,
 			Interpreter:  P_ld_linux,
+			Libc:         elf.GLIBC,
+			Needed:       []string{"libselinux.so.1", "libc.so.6"},
+			BuildID:      "2e73c291a805d29d1a4e589b68c99a17217727ba",
+			Stripped:     true,
+			GlibcNeeded:  "GLIBC_2.38",
+			MinKernel:    "3.2.0",
+			ISALevel:     1,
 			Dependencies: []string{P_libc, P_libpcre2_8, P_libselinux},
+			Libraries: []elf.Library{
+				{Soname: "libc.so.6", Path: P_libc, NeededBy: P_id},
+				{Soname: "libpcre2-8.so.0", Path: P_libpcre2_8, NeededBy: P_libselinux},
+				{Soname: "libselinux.so.1", Path: P_libselinux, NeededBy: P_id},
+			},
 		},
 		Dynamic:        true,
 		Exe:            true,
@@ -263,9 +478,21 @@ var TestData = testListing{
 			Name:         "which",
 			Path:         P_which,
 			Class:        elf.EI_CLASS(elf.ELF64),
+			Machine:      debug_elf.EM_X86_64,
 			Type:         elf.Type(elf.DYNEXE),
+			Entry:        0x1600,
 			Interpreter:  P_ld_linux,
+			Libc:         elf.GLIBC,
+			Needed:       []string{"libc.so.6"},
+			BuildID:      "8e95b428cff90579621d76109c6e5ab498091817",
+			Stripped:     true,
+			GlibcNeeded:  "GLIBC_2.34",
+			MinKernel:    "3.2.0",
+			ISALevel:     1,
 			Dependencies: []string{P_libc},
+			Libraries: []elf.Library{
+				{Soname: "libc.so.6", Path: P_libc, NeededBy: P_which},
+			},
 		},
 		Dynamic:        true,
 		Exe:            true,
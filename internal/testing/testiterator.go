@@ -117,17 +117,21 @@ func generateOutput(tc *TestCase, options testOptions, bins ...TestDetails) {
 	for _, bin := range bins {
 		var copy_bin bool
 		var snaggedBin string
+		var binDir string // where the bin is, or would be, snagged to
 		switch {
 		case bin.NonElf && !options.includes(copy_option):
 			copy_bin = false
 		case options.includes(inplace):
 			copy_bin = false
+			binDir = filepath.Dir(bin.Elf.Path)
 		case options.includes(copy_option):
 			copy_bin = true
 			snaggedBin = filepath.Join(tc.Dest, bin.Path)
+			binDir = filepath.Dir(snaggedBin)
 		default:
 			copy_bin = true
 			snaggedBin = filepath.Join(tc.Dest, bin.SnagTo, bin.SnagAs)
+			binDir = filepath.Dir(snaggedBin)
 		}
 
 		if copy_bin {
@@ -159,6 +163,9 @@ func generateOutput(tc *TestCase, options testOptions, bins ...TestDetails) {
 
 		for _, lib := range bin.Elf.Dependencies {
 			snaggedLib := filepath.Join(tc.Dest, "lib64", filepath.Base(lib))
+			if libDir, ok := bin.LibDirs[lib]; ok {
+				snaggedLib = filepath.Join(binDir, libDir, filepath.Base(lib))
+			}
 			resolved, _ := filepath.EvalSymlinks(lib)
 			if lib != resolved {
 				tc.ExpectedStdout = append(tc.ExpectedStdout,
@@ -169,7 +176,9 @@ func generateOutput(tc *TestCase, options testOptions, bins ...TestDetails) {
 					lib+" -> "+snaggedLib,
 				)
 			}
-			tc.ExpectedFiles[lib] = snaggedLib
+			if snaggedLib != filepath.Clean(lib) { // already in place
+				tc.ExpectedFiles[lib] = snaggedLib
+			}
 		}
 		if bin.StdErr != "" {
 			tc.ExpectedStderr = []string{"Error: parsing " + tc.Src + ":", bin.StdErr}
@@ -11,14 +11,20 @@ import (
 
 // Paths to our test binaries
 var (
-	P_ctypes_so     = TestdataPath("_ctypes_test.cpython-314-x86_64-linux-gnu.so")
-	P_empty         = TestdataPath("empty")
-	P_hello_dynamic = TestdataPath("hello_dynamic")
-	P_hello_pie     = TestdataPath("hello_pie")
-	P_hello_static  = TestdataPath("hello_static")
-	P_id            = TestdataPath("id")
-	P_ldd           = TestdataPath("ldd")
-	P_which         = TestdataPath("which")
+	P_ctypes_so        = TestdataPath("_ctypes_test.cpython-314-x86_64-linux-gnu.so")
+	P_empty            = TestdataPath("empty")
+	P_hello_dynamic    = TestdataPath("hello_dynamic")
+	P_hello_pie        = TestdataPath("hello_pie")
+	P_hello_static     = TestdataPath("hello_static")
+	P_hello_static_pie = TestdataPath("hello_static_pie")
+	P_id               = TestdataPath("id")
+	P_ldd              = TestdataPath("ldd")
+	P_which            = TestdataPath("which")
+
+	P_hello_runpath = TestdataPath("rpath/bin/hello_runpath")
+	P_libgreet      = TestdataPath("rpath/lib64/libgreet.so")
+	P_libhello      = TestdataPath("rpath/lib64/libhello.so")
+	P_rpath_sh      = TestdataPath("rpath/build.sh")
 
 	P_build_sh      = TestdataPath("hello/build.sh")
 	P_hello_pie_cgo = TestdataPath("hello/hello")
@@ -70,3 +76,45 @@ func TestdataPath(path string) string {
 	internalDir := filepath.Dir(thisfile)
 	return filepath.Join(internalDir, "testdata", path)
 }
+
+// Test helper: Constructs a minimal sysroot containing a dynamically linked executable & its dependencies
+// in locations which do not exist on the host:
+//
+//	/usr/bin/hello -> /opt/app/hello_dynamic
+//	/opt/app/hello_dynamic
+//	/lib64/ld-linux-x86-64.so.2 -> /opt/sysroot-libs/ld-linux-x86-64.so.2
+//	/opt/sysroot-libs/ld-linux-x86-64.so.2
+//	/opt/sysroot-libs/libc.so.6
+//	/etc/ld.so.conf (containing /opt/sysroot-libs)
+func BuildSysroot(t testing.TB) string {
+	t.Helper()
+	root := WorkspaceTempDir(t)
+	for _, dir := range []string{"usr/bin", "opt/app", "lib64", "opt/sysroot-libs", "etc"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0775); err != nil {
+			t.Fatal(err)
+		}
+	}
+	copies := map[string]string{
+		P_hello_dynamic:     "opt/app/hello_dynamic",
+		P_ld_linux_resolved: "opt/sysroot-libs/ld-linux-x86-64.so.2",
+		P_libc:              "opt/sysroot-libs/libc.so.6",
+	}
+	for src, dst := range copies {
+		if err := Copy(src, filepath.Join(root, dst)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	symlinks := map[string]string{
+		"usr/bin/hello":              "/opt/app/hello_dynamic",
+		"lib64/ld-linux-x86-64.so.2": "/opt/sysroot-libs/ld-linux-x86-64.so.2",
+	}
+	for link, target := range symlinks {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "etc/ld.so.conf"), []byte("/opt/sysroot-libs\n"), 0664); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
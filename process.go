@@ -0,0 +1,138 @@
+package snaggle
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Where to find `/proc/PID/exe` & `/proc/PID/maps`
+const procfs = "/proc"
+
+// Error returned if [FromProcess] is given an Option which only makes sense for files
+var ErrProcessOption = errors.New("cannot snag a process in-place, by copying, recursively or from a sysroot")
+
+// Error returned if [FromProcess] is given a process which sees a different filesystem, e.g. in a container
+var ErrProcessNamespace = errors.New("cannot snag a process with a different mount namespace or root directory")
+
+// FromProcess snags the executable running as pid and every file it has mapped as executable, into root. Unlike
+// [Snaggle] this includes any libraries the process has loaded via `dlopen()`, which cannot be found otherwise.
+//
+// Every mapped file is snagged along with its own dependencies, with the same layout as [Snaggle]:
+//   - `/proc/PID/exe` -> root/bin, plus its interpreter & dependencies
+//   - Every other file-backed executable mapping in `/proc/PID/maps` -> root/lib64 (etc.), unless it was
+//     already snagged as a dependency of the executable or an earlier mapping
+//   - Anonymous mappings, `[vdso]` etc. and deleted files are ignored
+//
+// The Options [InPlace()], [Copy()], [Recursive()] & [Sysroot()] return an error wrapping [ErrProcessOption].
+// The paths of the process are only meaningful if it shares our mount namespace & root directory, an error
+// wrapping [ErrProcessNamespace] is returned otherwise (e.g. for a process in a container or chroot).
+// Reading the mappings of another user's process needs the same permission as `ptrace`.
+func FromProcess(pid int, root string, opts ...Option) error {
+	options, snaggerrs, checker, restore := setup(opts)
+	defer restore()
+
+	proc := filepath.Join(procfs, strconv.Itoa(pid))
+	if options.inplace || options.copy || options.recursive || options.sysroot != "" {
+		return &InvocationError{Path: proc, Target: root, err: ErrProcessOption}
+	}
+	same, err := sameFilesystem(proc)
+	switch {
+	case err != nil:
+		return &SnaggleError{Src: proc, Dst: root, err: err}
+	case !same:
+		return &SnaggleError{Src: proc, Dst: root, err: ErrProcessNamespace}
+	}
+
+	exe, err := os.Readlink(filepath.Join(proc, "exe"))
+	if err != nil {
+		return &SnaggleError{Src: proc, Dst: root, err: err}
+	}
+	if deleted(exe) {
+		err := &fs.PathError{Op: "readlink", Path: filepath.Join(proc, "exe"), Err: fs.ErrNotExist}
+		return &SnaggleError{Src: proc, Dst: root, err: fmt.Errorf("%w: %s", err, exe)}
+	}
+	mapped, err := mappedFiles(filepath.Join(proc, "maps"))
+	if err != nil {
+		return &SnaggleError{Src: proc, Dst: root, err: err}
+	}
+
+	paths := append([]string{exe}, slices.DeleteFunc(mapped, func(path string) bool { return path == exe })...)
+	graphs, err := parseAll(paths, options)
+	if err != nil {
+		return &SnaggleError{Src: proc, Dst: root, err: err}
+	}
+
+	// anything already snagged as a dependency keeps that location, rather than also going to root/lib64. Paths in
+	// maps are fully resolved, as is each Root.Path, but other nodes are where they were found (e.g. /lib/libc.so.6)
+	snagged := make(map[string]bool)
+	for idx, graph := range graphs {
+		if graph == nil || snagged[graph.Root.Path] {
+			continue // not an ELF, or already snagged
+		}
+		for _, node := range graph.Nodes {
+			if resolved, err := filepath.EvalSymlinks(node.Path); err == nil {
+				snagged[resolved] = true
+			}
+		}
+		snaggerrs.Go(func() error { return snaggle(paths[idx], graph, root, options, checker) })
+	}
+	return snaggerrs.Wait()
+}
+
+// Does the process at proc (`/proc/PID`) share our mount namespace & root directory, so see the same paths?
+func sameFilesystem(proc string) (bool, error) {
+	for _, link := range []string{"ns/mnt", "root"} {
+		theirs, err := os.Readlink(filepath.Join(proc, link))
+		if err != nil {
+			return false, err
+		}
+		ours, err := os.Readlink(filepath.Join(procfs, "self", link))
+		if err != nil {
+			return false, err
+		}
+		if theirs != ours {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Every file mapped as executable in the `/proc/PID/maps` at path, once each, in the order mapped.
+// Anonymous & pseudo mappings (e.g. `[vdso]`) and deleted files are ignored.
+func mappedFiles(path string) ([]string, error) {
+	maps, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = maps.Close() }()
+
+	var files []string
+	lines := bufio.NewScanner(maps)
+	for lines.Scan() {
+		// address perms offset dev inode pathname, the pathname is padded with spaces & may contain spaces
+		fields := strings.SplitN(lines.Text(), " ", 6)
+		if len(fields) < 6 {
+			continue // anonymous
+		}
+		perms, inode, file := fields[1], fields[4], strings.TrimLeft(fields[5], " ")
+		switch {
+		case len(perms) < 3 || perms[2] != 'x':
+			continue
+		case inode == "0", !filepath.IsAbs(file), deleted(file):
+			continue
+		case !slices.Contains(files, file):
+			files = append(files, file)
+		}
+	}
+	return files, lines.Err()
+}
+
+// Has the file at path, as given in `/proc/PID/maps` or by `/proc/PID/exe`, been deleted?
+func deleted(path string) bool { return strings.HasSuffix(path, " (deleted)") }
@@ -0,0 +1,220 @@
+package snaggle
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/MusicalNinjaDad/snaggle/internal"
+)
+
+// Error returned if the interpreter of a script cannot be snagged, see [Scripts()]
+var ErrScriptInterpreter = errors.New("cannot snag script interpreter")
+
+// The PATH used to find commands run by scripts, within any sysroot, unless set via [Path()]
+var defaultPath = []string{"/usr/local/sbin", "/usr/local/bin", "/usr/sbin", "/usr/bin", "/sbin", "/bin"}
+
+// The kernel only reads this much of a `#!` line (BINPRM_BUF_SIZE)
+const maxShebang = 256
+
+// The kernel follows at most this many interpreters which are themselves scripts (BINPRM_MAX_RECURSION)
+const maxInterpreters = 4
+
+// The `#!` line of a script
+type shebang struct {
+	interpreter string // path to the interpreter, exactly as given
+	arg         string // the optional argument, everything after the interpreter as a single string
+}
+
+// The `#!` line of the file at path (within any sysroot), nil if it is not a script
+func readShebang(path string, options options) (*shebang, error) {
+	file, err := os.Open(options.host(path))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	head := make([]byte, maxShebang)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	line, found := bytes.CutPrefix(head[:n], []byte("#!"))
+	if !found {
+		return nil, nil
+	}
+	line, _, _ = bytes.Cut(line, []byte("\n"))
+	interpreter, arg := strings.Trim(string(line), " \t"), ""
+	if idx := strings.IndexAny(interpreter, " \t"); idx >= 0 {
+		interpreter, arg = interpreter[:idx], strings.Trim(interpreter[idx:], " \t")
+	}
+	if interpreter == "" {
+		return nil, nil // the kernel refuses to execute this
+	}
+	return &shebang{interpreter: interpreter, arg: arg}, nil
+}
+
+// The command run by `/usr/bin/env arg`, "" if none. arg is split on whitespace, as with `env -S`.
+func (s shebang) envCommand() string {
+	fields := strings.Fields(s.arg)
+	if idx := envCommandIndex(fields); idx < len(fields) {
+		return fields[idx]
+	}
+	return ""
+}
+
+// The index of the command in the arguments to `env`, len(args) if there is none
+func envCommandIndex(args []string) int {
+	for idx := 0; idx < len(args); idx++ {
+		switch arg := args[idx]; {
+		case arg == "-u", arg == "--unset", arg == "-C", arg == "--chdir":
+			idx++ // followed by a value
+		case strings.HasPrefix(arg, "-"), strings.Contains(arg, "="):
+			continue // other options & environment variables
+		default:
+			return idx
+		}
+	}
+	return len(args)
+}
+
+// Each file to snag, in order, once everything needed has been found & parsed. Nothing is snagged until then,
+// so that a script whose interpreter or commands cannot be snagged leaves root untouched.
+type plan []func() error
+
+// Snags every file in the plan, stopping at the first error
+func (p plan) snag() error {
+	for _, snag := range p {
+		if err := snag(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Plans to snag the script at path, with the `#!` line script, into root/bin, unless copying or snagging in
+// place, followed by its interpreter. With options.commands, also the commands it runs; returning any which could
+// not be resolved.
+func planScript(path string, script *shebang, root string, options options, checker chan<- skipCheck) (plan, []Command, error) {
+	var planned plan
+	if !options.copy && !options.inplace {
+		binDir, err := internal.ResolveIn(root, "bin")
+		if err != nil {
+			return nil, nil, &SnaggleError{Src: path, Dst: root, err: err}
+		}
+		planned = append(planned, func() error { return linkScript(path, binDir, root, options, checker) })
+	}
+	interpreter, err := planInterpreter(path, script, root, options, checker, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	planned = append(planned, interpreter...)
+
+	language, ok := script.shell()
+	if !options.commands || !ok {
+		return planned, nil, nil
+	}
+	commands, unresolved, err := planCommands(path, language, root, options, checker)
+	if err != nil {
+		return nil, nil, err
+	}
+	return append(planned, commands...), unresolved, nil
+}
+
+// Plans to snag the interpreter of the script at path to the exact path requested, in the same way as PT_INTERP;
+// and for `#!/usr/bin/env COMMAND` also COMMAND, found in the PATH, into root/bin. Either may itself be a script.
+func planInterpreter(path string, script *shebang, root string, options options, checker chan<- skipCheck, depth int) (plan, error) {
+	if depth > maxInterpreters {
+		return nil, &SnaggleError{Src: path, Dst: root, err: fmt.Errorf("%w: too many levels of interpreters", ErrScriptInterpreter)}
+	}
+	if !filepath.IsAbs(script.interpreter) {
+		return nil, &SnaggleError{Src: path, Dst: root, err: fmt.Errorf("%w: relative path %s", ErrScriptInterpreter, script.interpreter)}
+	}
+
+	exact := options
+	exact.copy, exact.inplace = true, false // copied files keep their full path under root
+	planned, err := planCommand(path, script.interpreter, root, exact, checker, depth)
+	if err != nil {
+		return nil, err
+	}
+
+	if filepath.Base(script.interpreter) != "env" {
+		return planned, nil
+	}
+	var command plan
+	switch name := script.envCommand(); {
+	case name == "":
+		return planned, nil
+	case strings.Contains(name, "/"):
+		command, err = planCommand(path, name, root, exact, checker, depth)
+	default:
+		found, ok := lookPath(name, options)
+		if !ok {
+			err := fmt.Errorf("%w: %s not found in PATH %s", ErrScriptInterpreter, name, strings.Join(options.searchPath(), ":"))
+			return nil, &SnaggleError{Src: path, Dst: root, err: err}
+		}
+		inBin := options
+		inBin.copy, inBin.inplace = false, false
+		command, err = planCommand(path, found, root, inBin, checker, depth)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(planned, command...), nil
+}
+
+// Plans to snag command, needed by the script at path, and everything it needs. Where it is snagged to depends
+// upon options.copy, as for [Snaggle].
+func planCommand(path string, command string, root string, options options, checker chan<- skipCheck, depth int) (plan, error) {
+	script, err := readShebang(command, options)
+	if err != nil {
+		return nil, &SnaggleError{Src: path, Dst: root, err: fmt.Errorf("%w: %w", ErrScriptInterpreter, err)}
+	}
+	if script != nil {
+		dir := "bin"
+		if options.copy {
+			dir = filepath.Dir(command)
+		}
+		dir, err := internal.ResolveIn(root, dir)
+		if err != nil {
+			return nil, &SnaggleError{Src: command, Dst: root, err: err}
+		}
+		interpreter, err := planInterpreter(command, script, root, options, checker, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		return append(plan{func() error { return linkScript(command, dir, root, options, checker) }}, interpreter...), nil
+	}
+
+	parseOptions := options
+	parseOptions.copy = false // the interpreter must be an ELF
+	graph, err := parse(command, parseOptions)
+	if err != nil {
+		return nil, &SnaggleError{Src: command, Dst: root, err: fmt.Errorf("%w: %w", ErrScriptInterpreter, err)}
+	}
+	return plan{func() error { return snaggle(command, graph, root, options, checker) }}, nil
+}
+
+// Links the script at path, unparsed, into dir
+func linkScript(path string, dir string, root string, options options, checker chan<- skipCheck) error {
+	if err := link(path, dir, options.sysroot, checker); err != nil {
+		return &SnaggleError{Src: path, Dst: root, err: err}
+	}
+	return nil
+}
+
+// The first executable file called name in the PATH (within any sysroot)
+func lookPath(name string, options options) (string, bool) {
+	for _, dir := range options.searchPath() {
+		path := filepath.Join(dir, name)
+		info, err := os.Stat(options.host(path))
+		if err == nil && info.Mode().IsRegular() && info.Mode().Perm()&0111 != 0 {
+			return path, true
+		}
+	}
+	return "", false
+}
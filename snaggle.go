@@ -14,11 +14,14 @@ package snaggle
 import (
 	debug_elf "debug/elf"
 	"errors"
+	"fmt"
 	"io"
 	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"syscall"
 
 	"golang.org/x/sync/errgroup"
@@ -38,7 +41,9 @@ func init() {
 // Errors returned will be [*fs.PathError] with Path=sourcePath,
 // errors wrapped further down the chain may include the resolved path.
 // Our PathError may wrap a further PathError or an [*os.LinkError].
-func link(sourcePath string, targetDir string, checker chan<- skipCheck) (err error) {
+//
+// If sysroot is not "", sourcePath is relative to sysroot and all symlinks are resolved within it.
+func link(sourcePath string, targetDir string, sysroot string, checker chan<- skipCheck) (err error) {
 	originalSourcePath := sourcePath
 
 	op := "resolve target"
@@ -54,7 +59,7 @@ func link(sourcePath string, targetDir string, checker chan<- skipCheck) (err er
 	// This avoids needing to ensure that any link/copy etc. actions
 	// follow symlinks and risking hard to find bugs.
 	op = "resolve"
-	sourcePath, err = filepath.EvalSymlinks(sourcePath)
+	sourcePath, err = internal.EvalSymlinksIn(sysroot, sourcePath)
 	if err != nil {
 		return &fs.PathError{Op: op, Path: originalSourcePath, Err: err}
 	}
@@ -64,12 +69,16 @@ func link(sourcePath string, targetDir string, checker chan<- skipCheck) (err er
 		return &fs.PathError{Op: op, Path: originalSourcePath, Err: err}
 	}
 
-	reply := make(chan bool)
-	checker <- skipCheck{target, reply}
+	reply := make(chan string)
+	checker <- skipCheck{target, sourcePath, reply}
 
-	if <-reply {
+	switch previous := <-reply; {
+	case previous != "" && internal.SameFile(previous, sourcePath):
 		op = "skip"
-	} else {
+	case previous != "":
+		op = "link"
+		err = fmt.Errorf("%w: %s is already snagged from %s", ErrConflict, target, previous)
+	default:
 		op = "link"
 		err = os.Link(sourcePath, target)
 		// Error codes: https://man7.org/linux/man-pages/man2/link.2.html
@@ -103,7 +112,16 @@ func link(sourcePath string, targetDir string, checker chan<- skipCheck) (err er
 //
 // Snaggle will hardlink (or copy, see notes):
 //   - path -> root/bin (executables) or path/lib64 (libraries), unless the Option [InPlace()] is provided
-//   - All dynamically linked dependencies -> root/lib64
+//   - The interpreter -> root/the exact path requested by the binary (e.g. root/lib/ld-musl-x86_64.so.1)
+//   - All dynamically linked dependencies -> root/lib64 (or root/lib for musl & 32-bit binaries, see [Lib32()];
+//     root/lib64/lp64d for riscv64), except:
+//   - Dependencies located via a DT_RPATH or DT_RUNPATH relative to `$ORIGIN` keep the same relative location
+//   - Dependencies located via any other DT_RPATH or DT_RUNPATH keep their full path under root
+//   - Dependencies requested by path (a `DT_NEEDED` containing a `/`) are snagged to that exact path under
+//     root if absolute, or relative to the snagged binary if relative
+//   - `/etc/ld.so.preload` -> root/etc/ld.so.preload, only with the Option [LdSoPreload()]
+//   - Scripts -> root/bin and their `#!` interpreter -> root/the exact path requested, only with the Option [Scripts()]
+//   - Commands run by shell scripts -> root/bin, only with the Option [Commands()]
 //
 // For example:
 //
@@ -116,6 +134,8 @@ func link(sourcePath string, targetDir string, checker chan<- skipCheck) (err er
 //
 // # Notes:
 //
+//   - Any symlinks already in root (e.g. `lib64 -> usr/lib64`) are followed, without leaving root
+//   - Two different files will never be snagged to the same path, an error wrapping [ErrConflict] is returned instead
 //   - Hardlinks will be created if possible.
 //   - A copy will be performed if hardlinking fails for one of the following reasons:
 //   - path & root are on different filesystems
@@ -123,96 +143,282 @@ func link(sourcePath string, targetDir string, checker chan<- skipCheck) (err er
 //   - Copies will retain the original filemode
 //   - Copies will attempt to retain the original ownership, although this will likely fail if running as non-root
 func Snaggle(path string, root string, opts ...Option) error {
-	snaggerrs := new(errgroup.Group)
+	options, snaggerrs, checker, restore := setup(opts)
+	defer restore()
 
-	checker := make(chan skipCheck)
-	go skipHandler(checker)
+	if options.sysroot != "" {
+		sysroot, err := filepath.Abs(options.sysroot)
+		if err != nil {
+			return &InvocationError{Path: path, Target: root, err: err}
+		}
+		options.sysroot = sysroot
+		path = filepath.Join("/", path)
+	}
+
+	if options.copy && options.inplace {
+		return &InvocationError{Path: path, Target: root, err: ErrCopyInplace}
+	}
 
-	var options options
+	switch {
+	case internal.IsDir(options.host(path)):
+		paths, err := listDir(path, options)
+		if err != nil {
+			return &SnaggleError{Src: path, Dst: root, err: err}
+		}
+		graphs, err := parseAll(paths, options)
+		if err != nil {
+			return &SnaggleError{Src: path, Dst: root, err: err}
+		}
+		// every interpreter & command is found & parsed before anything is snagged
+		plans := make([]plan, len(paths))
+		unresolved := make([][]Command, len(paths))
+		for idx, graph := range graphs {
+			if !options.scripts || (graph != nil && !options.copy) {
+				continue // not a script
+			}
+			script, err := readShebang(paths[idx], options)
+			if err != nil {
+				return &SnaggleError{Src: paths[idx], Dst: root, err: err}
+			}
+			if script != nil {
+				if plans[idx], unresolved[idx], err = planScript(paths[idx], script, root, options, checker); err != nil {
+					return err
+				}
+			}
+		}
+		for idx, graph := range graphs {
+			if graph != nil {
+				snaggerrs.Go(func() error { return snaggle(paths[idx], graph, root, options, checker) })
+			}
+			if plans[idx] != nil {
+				snaggerrs.Go(plans[idx].snag)
+			}
+		}
+		if err := snaggerrs.Wait(); err != nil {
+			return err
+		}
+		return unresolvedCommands(path, root, slices.Concat(unresolved...))
+	case options.recursive:
+		err := &fs.PathError{Op: "--recursive", Path: path, Err: syscall.ENOTDIR}
+		return &InvocationError{Path: path, Target: root, err: err}
+	default:
+		graph, err := parse(path, options)
+		var formatError *debug_elf.FormatError
+		if options.scripts && (errors.As(err, &formatError) || (err == nil && options.copy)) {
+			script, shebangErr := readShebang(path, options)
+			if shebangErr != nil {
+				return &SnaggleError{Src: path, Dst: root, err: shebangErr}
+			}
+			if script != nil {
+				planned, unresolved, planErr := planScript(path, script, root, options, checker)
+				if planErr != nil {
+					return planErr
+				}
+				if err == nil { // copying
+					if err := snaggle(path, graph, root, options, checker); err != nil {
+						return err
+					}
+				}
+				if err := planned.snag(); err != nil {
+					return err
+				}
+				return unresolvedCommands(path, root, unresolved)
+			}
+		}
+		if err != nil {
+			return &SnaggleError{path, "", err}
+		}
+		return snaggle(path, graph, root, options, checker)
+	}
+}
+
+// Everything needed to start snagging, for [Snaggle] & [FromProcess]: the options from opts, a group to snag in
+// (one at a time if verbose, to keep the output in order) and a checker for [link]. Call restore once finished, to
+// restore any log output discarded if not verbose.
+func setup(opts []Option) (options, *errgroup.Group, chan<- skipCheck, func()) {
+	options := options{lib32: "lib"}
 	for _, optfn := range opts {
 		optfn(&options)
 	}
 
-	switch {
-	case options.copy && options.inplace:
-		return &InvocationError{Path: path, Target: root, err: ErrCopyInplace}
-	case !options.verbose:
+	snaggerrs := new(errgroup.Group)
+	checker := make(chan skipCheck)
+	go skipHandler(checker)
+
+	restore := func() {}
+	if options.verbose {
+		snaggerrs.SetLimit(1)
+	} else {
 		output := log.Writer()
 		log.SetOutput(io.Discard)
-		defer log.SetOutput(output)
-	case options.verbose:
-		snaggerrs.SetLimit(1)
+		restore = func() { log.SetOutput(output) }
 	}
+	return options, snaggerrs, checker, restore
+}
 
-	snagfile := func(path string) error {
-		var badelf *debug_elf.FormatError
-		err := snaggle(path, root, options, checker)
-		switch {
-		case err == nil:
-			return nil // snagged
-		case errors.As(err, &badelf):
-			return nil // not an ELF
-		default:
-			return err
-		}
+// An error wrapping an [UnresolvedCommandError] for path, nil if every command was resolved
+func unresolvedCommands(path string, root string, unresolved []Command) error {
+	if len(unresolved) == 0 {
+		return nil
 	}
+	return &SnaggleError{Src: path, Dst: root, err: &UnresolvedCommandError{Unresolved: unresolved}}
+}
 
-	var snagdir func(dir string) error //see https://github.com/golang/go/issues/226 :-x FFS!
-	snagdir = func(dir string) error {
-		files, err := os.ReadDir(dir)
-		if err != nil {
-			return err
+// Every file in dir (within any sysroot), including those in subdirectories if options.recursive
+func listDir(dir string, options options) ([]string, error) {
+	return listSubdir(dir, nil, options)
+}
+
+// Lists dir for [listDir], skipping any subdirectory which is one of its ancestors. Such loops are made by
+// symlinks (e.g. Debian's `/usr/bin/X11 -> .`) and would otherwise never terminate.
+func listSubdir(dir string, ancestors []fs.FileInfo, options options) ([]string, error) {
+	info, err := os.Stat(options.host(dir))
+	if err != nil {
+		return nil, err
+	}
+	for _, ancestor := range ancestors {
+		if os.SameFile(info, ancestor) {
+			return nil, nil
 		}
+	}
+	ancestors = append(ancestors, info)
 
-		for _, file := range files {
-			path := filepath.Join(dir, file.Name())
-			isDir := internal.IsDir(path)
+	files, err := os.ReadDir(options.host(dir))
+	if err != nil {
+		return nil, err
+	}
 
-			switch {
-			case isDir && options.recursive:
-				if err := snagdir(path); err != nil {
-					return err
-				}
-			case isDir:
-				continue // skip Directory entries
-			default:
-				snaggerrs.Go(func() error { return snagfile(path) })
+	var paths []string
+	for _, file := range files {
+		path := filepath.Join(dir, file.Name())
+		isDir := internal.IsDir(options.host(path))
+
+		switch {
+		case isDir && options.recursive:
+			subdir, err := listSubdir(path, ancestors, options)
+			if err != nil {
+				return nil, err
 			}
+			paths = append(paths, subdir...)
+		case isDir:
+			continue // skip Directory entries
+		default:
+			paths = append(paths, path)
 		}
+	}
 
-		return nil
+	return paths, nil
+}
+
+// Parses the dependency graph of the file at path, ready to be snagged.
+//
+//   - When copying, files which are not ELFs are not an error and will simply be copied
+//   - With options.verifySymbols, any unresolved symbols are returned in an [elf.UnresolvedSymbolError]
+func parse(path string, options options) (*elf.Graph, error) {
+	var elfopts []elf.Option
+	if options.sysroot != "" {
+		elfopts = append(elfopts, elf.Sysroot(options.sysroot))
+	}
+	if options.isaLevel != 0 {
+		elfopts = append(elfopts, elf.TargetISA(options.isaLevel))
 	}
+	if options.dlopen != "" {
+		elfopts = append(elfopts, elf.Dlopen(options.dlopen))
+	}
+	if options.scanRodata {
+		elfopts = append(elfopts, elf.ScanRodata())
+	}
+	graph, err := elf.NewGraph(path, elfopts...)
+	var formatError *debug_elf.FormatError
+	if err != nil && !(options.copy && errors.As(err, &formatError)) {
+		return graph, err
+	}
+	if err == nil && options.verifySymbols {
+		return graph, graph.VerifySymbols()
+	}
+	return graph, nil
+}
 
-	switch {
-	case internal.IsDir(path):
-		if err := snagdir(path); err != nil {
-			return &SnaggleError{Src: path, Dst: root, err: err}
+// Parses all paths in parallel, before anything is snagged.
+//
+//   - Files which are not ELFs (and will not be copied) are returned as nil
+//   - Every dependency which cannot be found, for any path, is returned in a single [elf.MissingDependencyError]
+//   - Every symbol which cannot be resolved, for any path, is returned in a single [elf.UnresolvedSymbolError]
+func parseAll(paths []string, options options) ([]*elf.Graph, error) {
+	graphs := make([]*elf.Graph, len(paths))
+	errs := make([]error, len(paths))
+	parsers := new(errgroup.Group)
+	for idx, path := range paths {
+		parsers.Go(func() error {
+			graphs[idx], errs[idx] = parse(path, options)
+			return nil
+		})
+	}
+	_ = parsers.Wait() // errors are collected individually
+
+	missing := new(elf.MissingDependencyError)
+	unresolved := new(elf.UnresolvedSymbolError)
+	for idx, err := range errs {
+		var badelf *debug_elf.FormatError
+		var missingDependencies *elf.MissingDependencyError
+		var unresolvedSymbols *elf.UnresolvedSymbolError
+		switch {
+		case err == nil:
+			continue
+		case errors.As(err, &missingDependencies):
+			for _, lib := range missingDependencies.Missing {
+				if !slices.ContainsFunc(missing.Missing, func(m elf.Library) bool { // e.g. needed by a shared library
+					return m.Soname == lib.Soname && m.NeededBy == lib.NeededBy
+				}) {
+					missing.Missing = append(missing.Missing, lib)
+				}
+			}
+		case errors.As(err, &unresolvedSymbols):
+			for _, sym := range unresolvedSymbols.Unresolved {
+				if !slices.ContainsFunc(unresolved.Unresolved, func(u elf.Symbol) bool { // e.g. needed by a shared library
+					return u.Name == sym.Name && u.Version == sym.Version && u.NeededBy == sym.NeededBy
+				}) {
+					unresolved.Unresolved = append(unresolved.Unresolved, sym)
+				}
+			}
+		case errors.As(err, &badelf):
+			graphs[idx] = nil // not an ELF
+		default:
+			return nil, err
 		}
-		return snaggerrs.Wait()
-	case options.recursive:
-		err := &fs.PathError{Op: "--recursive", Path: path, Err: syscall.ENOTDIR}
-		return &InvocationError{Path: path, Target: root, err: err}
-	default:
-		return snaggle(path, root, options, checker)
 	}
+	if len(missing.Missing) > 0 {
+		return nil, missing
+	}
+	if len(unresolved.Unresolved) > 0 {
+		return nil, unresolved
+	}
+	return graphs, nil
 }
 
-func snaggle(path string, root string, options options, checker chan<- skipCheck) error {
-	binDir := filepath.Join(root, "bin")
-	libDir := filepath.Join(root, "lib64")
-	file, err := elf.New(path)
+// Snags the Root of graph, which was parsed from path, and everything it needs into root.
+func snaggle(path string, graph *elf.Graph, root string, options options, checker chan<- skipCheck) error {
+	file := *graph.Root
+	var lib string
 	switch {
-	case err == nil:
-		break
-	case options.copy:
-		var formatError *debug_elf.FormatError
-		if errors.As(err, &formatError) {
-			break
-		} else {
-			return &SnaggleError{path, "", err}
-		}
+	case file.Libc == elf.MUSL: // musl does not search lib64 by default
+		lib = "lib"
+	case file.Class == elf.EI_CLASS(elf.ELF32):
+		lib = options.lib32
+	case file.Machine == debug_elf.EM_RISCV: // glibc uses lib64/lp64d for the lp64d ABI
+		lib = "lib64/lp64d"
 	default:
-		return &SnaggleError{path, "", err}
+		lib = "lib64"
+	}
+
+	// follow any usr-merge symlinks already in root (e.g. lib64 -> /usr/lib64) without escaping root
+	binDir, err := internal.ResolveIn(root, "bin")
+	if err != nil {
+		return &SnaggleError{Src: path, Dst: root, err: err}
+	}
+	libDir, err := internal.ResolveIn(root, lib)
+	if err != nil {
+		return &SnaggleError{Src: path, Dst: root, err: err}
 	}
 
 	linkerrs := new(errgroup.Group)
@@ -222,27 +428,56 @@ func snaggle(path string, root string, options options, checker chan<- skipCheck
 		linkerrs.SetLimit(1)
 	}
 
+	var fileDir string
 	switch {
 	case options.inplace:
-		// do not link file
+		fileDir = filepath.Dir(file.Path)
 	case options.copy:
-		dest := filepath.Join(root, filepath.Dir(path))
-		linkerrs.Go(func() error { return link(path, dest, checker) })
+		fileDir = filepath.Join(root, filepath.Dir(path))
+	case file.IsExe():
+		fileDir = binDir
 	default:
-		if file.IsExe() {
-			linkerrs.Go(func() error { return link(path, binDir, checker) })
-		} else {
-			linkerrs.Go(func() error { return link(path, libDir, checker) })
+		fileDir = libDir
+	}
+
+	// where every library goes, before snagging anything
+	dirs, err := layout(file, fileDir, root, libDir, options.sysroot)
+	if err != nil {
+		return &SnaggleError{Src: path, Dst: root, err: err}
+	}
+
+	if !options.inplace {
+		linkerrs.Go(func() error { return link(path, fileDir, options.sysroot, checker) })
+	}
+
+	if graph.Interpreter != nil {
+		// exactly where PT_INTERP expects it, following any usr-merge symlinks already in root
+		interpreter := internal.InRoot(options.sysroot, graph.Interpreter.Path)
+		interpDir, err := internal.ResolveIn(root, filepath.Dir(interpreter))
+		if err != nil {
+			return &SnaggleError{Src: path, Dst: root, err: err}
 		}
+		linkerrs.Go(func() error { return link(interpreter, interpDir, options.sysroot, checker) })
 	}
 
-	// TODO: #50 make linking interpreter safer
-	if file.Interpreter != "" {
-		linkerrs.Go(func() error { return link(file.Interpreter, libDir, checker) }) // currently OK - as it sits in /lib64 ... but ...
+	if options.ldSoPreload && slices.ContainsFunc(file.Libraries, func(lib elf.Library) bool { return lib.Via == elf.PRELOAD }) {
+		etcDir, err := internal.ResolveIn(root, "etc")
+		if err != nil {
+			return &SnaggleError{Src: path, Dst: root, err: err}
+		}
+		linkerrs.Go(func() error { return link("/etc/ld.so.preload", etcDir, options.sysroot, checker) })
 	}
 
-	for _, lib := range file.Dependencies {
-		linkerrs.Go(func() error { return link(lib, libDir, checker) })
+	// every library file needs, sorted rather than in load order for readable output
+	for idx, dependency := range file.Dependencies {
+		if options.allHWCaps {
+			for dir, variant := range hwcapsVariants(file.Libraries[idx], dirs[dependency], options.sysroot) {
+				linkerrs.Go(func() error { return link(variant, dir, options.sysroot, checker) })
+			}
+			continue
+		}
+		libPath := internal.InRoot(options.sysroot, dependency)
+		linkerrs.Go(func() error { return link(libPath, dirs[dependency], options.sysroot, checker) })
 	}
 
 	// TODO: #37 improve error handling with context, error collector, rollback
@@ -253,12 +488,150 @@ func snaggle(path string, root string, options options, checker chan<- skipCheck
 	return nil
 }
 
+// The baseline & every `glibc-hwcaps` variant of lib (paths within sysroot), keyed by the directory to snag
+// each into, given that lib itself would be snagged into dir.
+//
+// The baseline goes to dir and each variant to dir/glibc-hwcaps/<subdir>, whichever of them lib is.
+func hwcapsVariants(lib elf.Library, dir string, sysroot string) map[string]string {
+	srcDir := filepath.Dir(lib.Path)
+	if lib.HWCaps != "" {
+		srcDir = filepath.Dir(filepath.Dir(srcDir)) // out of glibc-hwcaps/<subdir>
+		if filepath.Base(dir) == lib.HWCaps && filepath.Base(filepath.Dir(dir)) == "glibc-hwcaps" {
+			dir = filepath.Dir(filepath.Dir(dir)) // layout kept lib's position relative to its owner
+		}
+	}
+	filename := filepath.Base(lib.Path)
+
+	variants := map[string]string{}
+	baseline := internal.InRoot(sysroot, filepath.Join(srcDir, filename))
+	if _, err := internal.EvalSymlinksIn(sysroot, baseline); err == nil {
+		variants[dir] = baseline
+	}
+	// resolved within sysroot, not by globbing on the host, in case of absolute symlinks
+	hwcapsDir, err := internal.EvalSymlinksIn(sysroot, internal.InRoot(sysroot, filepath.Join(srcDir, "glibc-hwcaps")))
+	if err != nil {
+		return variants
+	}
+	subdirs, _ := os.ReadDir(hwcapsDir) // no variants if it can't be read
+	for _, subdir := range subdirs {
+		variant := internal.InRoot(sysroot, filepath.Join(srcDir, "glibc-hwcaps", subdir.Name(), filename))
+		if _, err := internal.EvalSymlinksIn(sysroot, variant); err == nil {
+			variants[filepath.Join(dir, "glibc-hwcaps", subdir.Name())] = variant
+		}
+	}
+	return variants
+}
+
+// Directory to snag each of file's Libraries into, given that file itself is snagged into fileDir.
+//
+//   - Libraries located via `$ORIGIN` keep the same position relative to the object whose DT_RPATH
+//     or DT_RUNPATH located them, so that they will still be found at runtime
+//   - Libraries located via any other absolute DT_RPATH or DT_RUNPATH keep their full path under root
+//   - Libraries requested by absolute pathname keep that path under root, those requested by relative
+//     pathname keep the same position relative to file, as ld.so opens them from the working directory
+//   - Everything else goes to libDir, as does anything located via `$ORIGIN` which would otherwise be snagged
+//     outside root (e.g. via `$ORIGIN/../../lib` from root/bin). When snagging in place, file's own directory
+//     is not under root, so its relative layout is kept wherever that leads.
+//
+// A relative pathname which would be snagged outside root returns an error wrapping [ErrOutsideRoot]: ld.so
+// opens pathnames directly, without searching, so cannot find it anywhere else.
+func layout(file elf.Elf, fileDir string, root string, libDir string, sysroot string) (map[string]string, error) {
+	dirs := map[string]string{file.Path: fileDir}
+	var outside []string // relative pathnames which cannot be snagged
+	// Libraries are sorted by path, not load order, so an owner may not have been placed yet
+	var place func(lib elf.Library) string
+	place = func(lib elf.Library) string {
+		if dir, placed := dirs[lib.Path]; placed {
+			return dir
+		}
+		dirs[lib.Path] = libDir // guard against cycles
+		switch {
+		case lib.Owner != "" && originRelative(lib.SearchDir):
+			ownerDir, placed := dirs[lib.Owner]
+			if !placed {
+				for _, owner := range file.Libraries {
+					if owner.Path == lib.Owner {
+						ownerDir = place(owner)
+					}
+				}
+			}
+			rel, err := filepath.Rel(filepath.Dir(lib.Owner), filepath.Dir(lib.Path))
+			if err == nil && (!within(root, ownerDir) || within(root, filepath.Join(ownerDir, rel))) {
+				dirs[lib.Path] = filepath.Join(ownerDir, rel)
+			}
+		case lib.Owner != "" && filepath.IsAbs(lib.SearchDir), lib.Source == elf.PATHNAME && filepath.IsAbs(lib.Soname):
+			dirs[lib.Path] = filepath.Join(root, filepath.Dir(internal.InRoot(sysroot, lib.Path)))
+		case lib.Source == elf.PATHNAME:
+			dir := filepath.Join(fileDir, filepath.Dir(lib.Soname))
+			if within(root, fileDir) && !within(root, dir) {
+				outside = append(outside, lib.Soname+" needed by "+lib.NeededBy)
+			}
+			dirs[lib.Path] = dir
+		}
+		return dirs[lib.Path]
+	}
+	for _, lib := range file.Libraries {
+		place(lib)
+	}
+	if len(outside) > 0 {
+		return dirs, fmt.Errorf("%w: %s", ErrOutsideRoot, strings.Join(outside, ", "))
+	}
+	return dirs, nil
+}
+
+// Does dir, a search path entry, start with `$ORIGIN` (or `${ORIGIN}`) as a whole path component?
+func originRelative(dir string) bool {
+	for _, origin := range []string{"$ORIGIN", "${ORIGIN}"} {
+		if dir == origin || strings.HasPrefix(dir, origin+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Is dir root, or somewhere beneath it?
+func within(root string, dir string) bool {
+	rel, err := filepath.Rel(root, dir)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
 // options used by [Snaggle]
 type options struct {
-	copy      bool // copy entire directory contents to /destinationroot/full/source/path
-	inplace   bool // snag in place, only snag dependencies & interpreter
-	recursive bool // recurse subdirectories & snag everything
-	verbose   bool // output to stdout and process sequentially for readability
+	copy          bool         // copy entire directory contents to /destinationroot/full/source/path
+	inplace       bool         // snag in place, only snag dependencies & interpreter
+	recursive     bool         // recurse subdirectories & snag everything
+	verbose       bool         // output to stdout and process sequentially for readability
+	sysroot       string       // resolve everything relative to this root, "" for the host
+	lib32         string       // directory, relative to root, for 32-bit libraries
+	verifySymbols bool         // fail if any undefined symbol is not exported by the resolved libraries
+	isaLevel      int          // x86-64 micro-architecture level to resolve glibc-hwcaps for, 0 for the baseline only
+	allHWCaps     bool         // also snag every glibc-hwcaps variant of each library
+	dlopen        elf.Priority // also snag .note.dlopen dependencies with at least this priority, "" for none
+	scanRodata    bool         // guess dlopen dependencies from .rodata if there is no .note.dlopen
+	ldSoPreload   bool         // also snag /etc/ld.so.preload
+	scripts       bool         // also snag scripts, with their `#!` interpreters
+	commands      bool         // also snag the commands run by shell scripts
+	path          []string     // PATH to find commands run by scripts, nil for defaultPath
+}
+
+// The path on the host to path within the sysroot, following symlinks within the sysroot
+func (o options) host(path string) string {
+	if o.sysroot == "" {
+		return path
+	}
+	resolved, err := internal.EvalSymlinksIn(o.sysroot, path)
+	if err != nil {
+		return filepath.Join(o.sysroot, path) // leave reporting the error to whoever tries to use the path
+	}
+	return resolved
+}
+
+// The PATH used to find commands run by scripts
+func (o options) searchPath() []string {
+	if o.path == nil {
+		return defaultPath
+	}
+	return o.path
 }
 
 // Option setting functions
@@ -276,6 +649,83 @@ func Recursive() Option { return func(o *options) { o.recursive = true } }
 // Output to stdout and process sequentially for readability
 func Verbose() Option { return func(o *options) { o.verbose = true } }
 
+// Snag 32-bit libraries to root/dir rather than root/lib.
+//
+// Use this if the 32-bit interpreter searches a different directory, e.g. "lib32" on Debian-based 64-bit systems.
+// Has no effect on musl binaries, whose libraries always go to root/lib.
+func Lib32(dir string) Option { return func(o *options) { o.lib32 = dir } }
+
+// Snag from the root filesystem at root, rather than the host, without needing to chroot.
+//
+// The path given to [Snaggle], interpreters, dependencies, ld.so.cache, ld.so.conf and any symlinks are
+// all resolved within root, as though it were `/`. A relative path is relative to root.
+func Sysroot(root string) Option { return func(o *options) { o.sysroot = root } }
+
+// Check, like `ldd -r`, that every undefined symbol is exported by the libraries which would be snagged,
+// with the required GNU symbol version (e.g. `GLIBC_2.34`), before snagging anything.
+//
+// Unresolved symbols are returned in an error wrapping an [elf.UnresolvedSymbolError].
+func VerifySymbols() Option { return func(o *options) { o.verifySymbols = true } }
+
+// Resolve libraries for an x86-64 CPU supporting the micro-architecture level (e.g. 3 for x86-64-v3), using the
+// matching `glibc-hwcaps` variants if there are any, see [elf.TargetISA].
+//
+// By default the baseline libraries are snagged, which run on any x86-64 CPU, even if `ld.so` on the host
+// would use a `glibc-hwcaps` variant.
+func TargetISA(level int) Option { return func(o *options) { o.isaLevel = level } }
+
+// Snag every `glibc-hwcaps` variant of each library, alongside the baseline, in the same layout: e.g.
+// root/lib64/libfoo.so & root/lib64/glibc-hwcaps/x86-64-v3/libfoo.so, so that `ld.so` can choose at runtime.
+//
+// Only the dependencies of the variant chosen by [TargetISA] (or the baseline) are snagged.
+func AllHWCaps() Option { return func(o *options) { o.allHWCaps = true } }
+
+// Also snag libraries which are loaded at runtime via `dlopen()`, as declared in `.note.dlopen`, with at least
+// priority: [elf.REQUIRED], [elf.RECOMMENDED] or [elf.SUGGESTED]. Plus anything they need.
+//
+// Only the first alternative which can be found is snagged. Nothing is snagged if a REQUIRED library cannot be
+// found, in the same way as for a missing `DT_NEEDED`.
+func Dlopen(priority elf.Priority) Option { return func(o *options) { o.dlopen = priority } }
+
+// With [Dlopen], also snag any library whose soname (e.g. `libfoo.so.1`) is found in `.rodata` of a binary or
+// library which has no `.note.dlopen`. A heuristic: these are never required, see [elf.ScanRodata].
+func ScanRodata() Option { return func(o *options) { o.scanRodata = true } }
+
+// Also snag `/etc/ld.so.preload` to root/etc/ld.so.preload, if anything it lists was found. Otherwise the
+// libraries it lists are snagged but will not be preloaded when running from root.
+func LdSoPreload() Option { return func(o *options) { o.ldSoPreload = true } }
+
+// Also snag scripts: any file starting with `#!` is snagged to root/bin (or as for [Copy()] & [InPlace()]) and
+// its interpreter to the exact path requested, plus everything the interpreter needs. For
+// `#!/usr/bin/env COMMAND`, COMMAND is also found in the PATH (within any sysroot) and snagged to root/bin.
+// Interpreters which are themselves scripts are followed, as the kernel does.
+//
+// An interpreter which cannot be found returns an error wrapping [ErrScriptInterpreter].
+func Scripts() Option { return func(o *options) { o.scripts = true } }
+
+// Also snag the commands run by shell scripts (`sh`, `dash`, `ash`, `bash` & `mksh`), implies [Scripts()].
+//
+// A best effort: each script is parsed and every command it runs by name (e.g. `sed`, or `mkdir` via `exec` or
+// `env`), other than builtins & functions declared in the script, is found in the PATH (see [Path()]) and snagged
+// to root/bin; or to the exact path given if it is absolute. Commands which are themselves scripts have their
+// interpreter snagged, but are not parsed.
+//
+// Commands which cannot be found, or which are constructed at runtime (e.g. `exec "$@"`), are returned in an
+// error wrapping an [UnresolvedCommandError], after snagging everything else.
+func Commands() Option { return func(o *options) { o.scripts, o.commands = true, true } }
+
+// Find commands run by scripts in the colon-separated path (within any sysroot), rather than
+// `/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin`. Also used for `#!/usr/bin/env COMMAND`.
+// An empty path keeps the default.
+func Path(path string) Option {
+	return func(o *options) {
+		o.path = nil
+		if path != "" {
+			o.path = filepath.SplitList(path)
+		}
+	}
+}
+
 // An error occurred during snaglling
 type SnaggleError struct {
 	Src string // Source path
@@ -306,6 +756,12 @@ type InvocationError struct {
 
 var (
 	ErrCopyInplace = errors.New("cannot copy in-place")
+	// Two different files would be snagged to the same destination, e.g. binaries which request the
+	// same interpreter path but resolve it to different files
+	ErrConflict = errors.New("conflicting files for the same destination")
+	// A library requested by relative pathname (e.g. `../../lib/libx.so`) would need to be snagged outside
+	// the destination
+	ErrOutsideRoot = errors.New("relative pathname outside the destination")
 )
 
 func (e *InvocationError) Error() string {
@@ -321,19 +777,18 @@ func (e *InvocationError) Unwrap() error {
 
 type skipCheck struct {
 	destination string
-	response    chan bool
+	source      string      // fully resolved
+	response    chan string // the source already linked to destination, "" if none
 }
 
 func skipHandler(in <-chan skipCheck) {
-	linked := make(map[string]bool)
+	linked := make(map[string]string)
 	for {
 		request := <-in
-		_, exists := linked[request.destination]
+		source, exists := linked[request.destination]
 		if !exists {
-			linked[request.destination] = true
-			request.response <- false
-			continue
+			linked[request.destination] = request.source
 		}
-		request.response <- true
+		request.response <- source
 	}
 }
@@ -1,15 +1,19 @@
 package snaggle_test
 
 import (
+	"bufio"
 	"errors"
 	"io"
 	"io/fs"
 	"log"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 	"testing"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/stretchr/testify/assert"
@@ -206,6 +210,243 @@ func TestLinkDifferentFile(t *testing.T) {
 
 }
 
+func TestOriginLayout(t *testing.T) {
+	Assert := assert.New(t)
+	tmp := WorkspaceTempDir(t)
+
+	// bin/rpath has DT_RPATH=$ORIGIN/../lib
+	err := snaggle.Snaggle("elf/testdata/rpath/bin/rpath", tmp)
+	Assert.NoError(err)
+
+	for _, snagged := range []string{"bin/rpath", "lib/liba.so", "lib/libb.so", "lib64/libc.so.6"} {
+		Assert.FileExists(filepath.Join(tmp, snagged))
+	}
+	Assert.NoFileExists(filepath.Join(tmp, "lib64/liba.so"))
+}
+
+func TestSysroot(t *testing.T) {
+	var stdout strings.Builder
+	log.SetOutput(&stdout)
+	t.Cleanup(func() { log.SetOutput(os.Stdout) })
+
+	Assert := Assert(t)
+	sysroot := BuildSysroot(t)
+	dest := WorkspaceTempDir(t)
+
+	expectedFiles := map[string]string{
+		filepath.Join(sysroot, "opt/app/hello_dynamic"):                 filepath.Join(dest, "bin/hello"),
+		filepath.Join(sysroot, "opt/sysroot-libs/ld-linux-x86-64.so.2"): filepath.Join(dest, "lib64/ld-linux-x86-64.so.2"),
+		filepath.Join(sysroot, "opt/sysroot-libs/libc.so.6"):            filepath.Join(dest, "lib64/libc.so.6"),
+	}
+	expectedOut := []string{
+		"/usr/bin/hello (" + filepath.Join(sysroot, "opt/app/hello_dynamic") + ") -> " + filepath.Join(dest, "bin/hello"),
+		"/lib64/ld-linux-x86-64.so.2 (" + filepath.Join(sysroot, "opt/sysroot-libs/ld-linux-x86-64.so.2") + ") -> " + filepath.Join(dest, "lib64/ld-linux-x86-64.so.2"),
+		"/opt/sysroot-libs/libc.so.6 (" + filepath.Join(sysroot, "opt/sysroot-libs/libc.so.6") + ") -> " + filepath.Join(dest, "lib64/libc.so.6"),
+	}
+
+	err := snaggle.Snaggle("/usr/bin/hello", dest, snaggle.Sysroot(sysroot), snaggle.Verbose())
+	Assert.Testify.NoError(err)
+
+	Assert.DirectoryContents(expectedFiles, dest)
+	Assert.Stdout(expectedOut, StripLines(stdout.String()))
+}
+
+func TestMixedClasses(t *testing.T) {
+	for _, lib32 := range []string{"", "lib32"} {
+		t.Run("lib32="+lib32, func(t *testing.T) {
+			Assert := Assert(t)
+			sysroot := BuildSysroot(t)
+			dest := WorkspaceTempDir(t)
+			Assert.Testify.NoError(os.CopyFS(sysroot, os.DirFS("elf/testdata/i386/root")))
+
+			opts := []snaggle.Option{snaggle.Sysroot(sysroot)}
+			libDir := "lib"
+			if lib32 != "" {
+				opts = append(opts, snaggle.Lib32(lib32))
+				libDir = lib32
+			}
+
+			expectedFiles := map[string]string{
+				filepath.Join(sysroot, "opt/app/hello_dynamic"):                 filepath.Join(dest, "bin/hello"),
+				filepath.Join(sysroot, "opt/sysroot-libs/ld-linux-x86-64.so.2"): filepath.Join(dest, "lib64/ld-linux-x86-64.so.2"),
+				filepath.Join(sysroot, "opt/sysroot-libs/libc.so.6"):            filepath.Join(dest, "lib64/libc.so.6"),
+				filepath.Join(sysroot, "usr/bin/hello32"):                       filepath.Join(dest, "bin/hello32"),
+				filepath.Join(sysroot, "lib/ld-linux.so.2"):                     filepath.Join(dest, "lib/ld-linux.so.2"),
+				filepath.Join(sysroot, "usr/lib/libthirtytwo.so"):               filepath.Join(dest, libDir, "libthirtytwo.so"),
+			}
+
+			err := snaggle.Snaggle("/usr/bin", dest, opts...)
+			Assert.Testify.NoError(err)
+			Assert.DirectoryContents(expectedFiles, dest)
+		})
+	}
+}
+
+func TestMusl(t *testing.T) {
+	Assert := Assert(t)
+	sysroot, err := filepath.Abs("elf/testdata/musl/root")
+	Assert.Testify.NoError(err)
+	dest := WorkspaceTempDir(t)
+
+	// ld-musl-x86_64.so.1 is also libc, libraries go to lib as musl does not search lib64
+	expectedFiles := map[string]string{
+		filepath.Join(sysroot, "usr/bin/hello_musl"):       filepath.Join(dest, "bin/hello_musl"),
+		filepath.Join(sysroot, "lib/ld-musl-x86_64.so.1"):  filepath.Join(dest, "lib/ld-musl-x86_64.so.1"),
+		filepath.Join(sysroot, "opt/musl/lib/libgreet.so"): filepath.Join(dest, "lib/libgreet.so"),
+	}
+
+	err = snaggle.Snaggle("/usr/bin/hello_musl", dest, snaggle.Sysroot(sysroot), snaggle.Lib32("lib32"))
+	Assert.Testify.NoError(err)
+	Assert.DirectoryContents(expectedFiles, dest)
+}
+
+func TestForeignArch(t *testing.T) {
+	tests := map[string]struct {
+		interpreter string // in root
+		libDir      string // in dest
+	}{
+		"aarch64": {"lib/aarch64-linux-gnu/ld-linux-aarch64.so.1", "lib64"},
+		"riscv64": {"usr/lib64/lp64d/ld-linux-riscv64-lp64d.so.1", "lib64/lp64d"},
+		"ppc64le": {"lib64/ld64.so.2", "lib64"},
+	}
+	for arch, tc := range tests {
+		t.Run(arch, func(t *testing.T) {
+			Assert := Assert(t)
+			sysroot, err := filepath.Abs(filepath.Join("elf/testdata/foreign", arch, "root"))
+			Assert.Testify.NoError(err)
+			dest := WorkspaceTempDir(t)
+
+			file, err := elf.New("/usr/bin/hello", elf.Sysroot(sysroot))
+			Assert.Testify.NoError(err)
+
+			expectedFiles := map[string]string{
+				filepath.Join(sysroot, "usr/bin/hello"): filepath.Join(dest, "bin/hello"),
+				filepath.Join(sysroot, tc.interpreter):  filepath.Join(dest, file.Interpreter),
+				file.Dependencies[0]:                    filepath.Join(dest, tc.libDir, "libc.so.6"),
+				file.Dependencies[1]:                    filepath.Join(dest, tc.libDir, "libgreet.so"),
+			}
+
+			err = snaggle.Snaggle("/usr/bin/hello", dest, snaggle.Sysroot(sysroot))
+			Assert.Testify.NoError(err)
+			Assert.DirectoryContents(expectedFiles, dest)
+		})
+	}
+}
+
+func TestUsrMerge(t *testing.T) {
+	Assert := Assert(t)
+	dest := WorkspaceTempDir(t)
+	Assert.Testify.NoError(os.MkdirAll(filepath.Join(dest, "usr/lib64"), 0775))
+	Assert.Testify.NoError(os.Symlink("/usr/lib64", filepath.Join(dest, "lib64"))) // absolute: must stay within dest
+
+	err := snaggle.Snaggle(P_hello_dynamic, dest)
+	Assert.Testify.NoError(err)
+
+	Assert.Testify.True(SameFile(P_ld_linux, filepath.Join(dest, "usr/lib64/ld-linux-x86-64.so.2")))
+	Assert.Testify.True(SameFile(P_libc, filepath.Join(dest, "usr/lib64/libc.so.6")))
+	Assert.Testify.True(SameFile(P_hello_dynamic, filepath.Join(dest, "bin/hello_dynamic")))
+}
+
+func TestConflict(t *testing.T) {
+	Assert := Assert(t)
+	sysroot := BuildSysroot(t)
+	dest := WorkspaceTempDir(t)
+	Assert.Testify.NoError(os.MkdirAll(filepath.Join(sysroot, "usr/bin/other"), 0775))
+	Assert.Testify.NoError(Copy(P_hello_static, filepath.Join(sysroot, "usr/bin/other/hello")))
+
+	// usr/bin/hello & usr/bin/other/hello are different files, both snagged to bin/hello
+	err := snaggle.Snaggle("/usr/bin", dest, snaggle.Sysroot(sysroot), snaggle.Recursive())
+	Assert.Testify.ErrorIs(err, snaggle.ErrConflict)
+}
+
+func TestPathname(t *testing.T) {
+	Assert := Assert(t)
+	sysroot := BuildSysroot(t)
+	dest := WorkspaceTempDir(t)
+	for src, dst := range map[string]string{
+		"elf/testdata/pathneeded/bin/app":                     "opt/app/app",
+		"elf/testdata/pathneeded/bin/plugins/libplugin.so":    "opt/app/plugins/libplugin.so",
+		"elf/testdata/pathneeded/opt/vendor/lib/libvendor.so": "opt/vendor/lib/libvendor.so",
+	} {
+		Assert.Testify.NoError(os.MkdirAll(filepath.Dir(filepath.Join(sysroot, dst)), 0775))
+		Assert.Testify.NoError(Copy(src, filepath.Join(sysroot, dst)))
+	}
+
+	err := snaggle.Snaggle("/opt/app/app", dest, snaggle.Sysroot(sysroot))
+	Assert.Testify.NoError(err)
+
+	// absolute pathnames at exactly that path, relative pathnames relative to the executable
+	for _, snagged := range []string{"bin/app", "bin/plugins/libplugin.so", "opt/vendor/lib/libvendor.so", "lib64/libc.so.6"} {
+		Assert.Testify.FileExists(filepath.Join(dest, snagged))
+	}
+}
+
+func TestMissingDependencies(t *testing.T) {
+	Assert := Assert(t)
+	dest := WorkspaceTempDir(t)
+	bin, err := filepath.Abs("elf/testdata/rpath/bin")
+	Assert.Testify.NoError(err)
+
+	// bin/rpath is fine but bin/missing & bin/runpath are not, so nothing is snagged
+	err = snaggle.Snaggle(bin, dest)
+	Assert.Testify.ErrorIs(err, elf.ErrMissingDependency)
+
+	expected := []elf.Library{
+		{Soname: "libmissing1.so", NeededBy: bin + "/missing"},
+		{Soname: "libmissing2.so", NeededBy: bin + "/missing"},
+		{Soname: "libb.so", NeededBy: bin + "/../lib/liba.so"},
+	}
+	var missing *elf.MissingDependencyError
+	if Assert.Testify.ErrorAs(err, &missing) {
+		Assert.Testify.Equal(expected, missing.Missing)
+	}
+	Assert.DirectoryContents(map[string]string{}, dest)
+}
+
+func TestWhy(t *testing.T) {
+	Assert := Assert(t)
+	bin, err := filepath.Abs("elf/testdata/rpath/bin/rpath")
+	Assert.Testify.NoError(err)
+	libdir := filepath.Dir(bin) + "/../lib"
+
+	reasons, err := snaggle.Why("libb.so", []string{bin})
+	Assert.Testify.NoError(err)
+	if !Assert.Testify.Len(reasons, 1) {
+		return
+	}
+	reason := reasons[0]
+	Assert.Testify.Equal(bin, reason.Binary)
+	Assert.Testify.Equal([][]elf.Edge{{
+		{From: bin, To: libdir + "/liba.so", Soname: "liba.so"},
+		{From: libdir + "/liba.so", To: libdir + "/libb.so", Soname: "libb.so"},
+	}}, reason.Chains)
+	Assert.Testify.Equal(elf.Source(elf.RPATH), reason.Library.Source)
+	if Assert.Testify.NotEmpty(reason.Library.Candidates) {
+		Assert.Testify.Equal(elf.Candidate{Source: elf.RPATH, SearchDir: "$ORIGIN/../lib", Owner: bin, Path: libdir + "/libb.so", Found: true}, reason.Library.Candidates[0])
+	}
+
+	// missing & runpath cannot find libb.so, but still need it
+	reasons, err = snaggle.Why("libb.so", []string{filepath.Dir(bin)})
+	Assert.Testify.NoError(err)
+	Assert.Testify.Len(reasons, 3)
+	for _, reason := range reasons {
+		if reason.Library.Path == "" {
+			Assert.Testify.NotEmpty(reason.Library.Candidates, "where %s searched", reason.Binary)
+		}
+	}
+
+	// only rpath needs the file at this path
+	reasons, err = snaggle.Why(libdir+"/libb.so", []string{filepath.Dir(bin)})
+	Assert.Testify.NoError(err)
+	if Assert.Testify.Len(reasons, 1) {
+		Assert.Testify.Equal(bin, reasons[0].Binary)
+	}
+
+	reasons, err = snaggle.Why("libnothing.so", []string{bin})
+	Assert.Testify.NoError(err)
+	Assert.Testify.Empty(reasons)
+}
+
 func BenchmarkCommonBinaries(b *testing.B) {
 	log.SetOutput(io.Discard)
 	b.Cleanup(func() { log.SetOutput(os.Stdout) })
@@ -272,3 +513,438 @@ func BenchmarkCommonBinaries(b *testing.B) {
 		})
 	}
 }
+
+func TestVerifySymbols(t *testing.T) {
+	Assert := Assert(t)
+	dest := WorkspaceTempDir(t)
+	bin, err := filepath.Abs("elf/testdata/symbols/bin/newer")
+	Assert.Testify.NoError(err)
+
+	err = snaggle.Snaggle(bin, dest, snaggle.VerifySymbols())
+	Assert.Testify.ErrorIs(err, elf.ErrUnresolvedSymbol)
+	var unresolved *elf.UnresolvedSymbolError
+	if Assert.Testify.ErrorAs(err, &unresolved) {
+		Assert.Testify.Equal([]elf.Symbol{{Name: "newfunc", Version: "SYM_2.0", Library: "libsym.so", NeededBy: bin}}, unresolved.Unresolved)
+	}
+	Assert.DirectoryContents(map[string]string{}, dest)
+
+	// unresolved symbols are only an error when asked for
+	Assert.Testify.NoError(snaggle.Snaggle(bin, dest))
+	Assert.Testify.FileExists(filepath.Join(dest, "bin/newer"))
+}
+
+func TestCompat(t *testing.T) {
+	Assert := Assert(t)
+
+	// the bundled libc.so.6 is older than /bin/new needs
+	report, err := snaggle.Compat("elf/testdata/compat/root")
+	Assert.Testify.ErrorIs(err, snaggle.ErrIncompatible)
+	Assert.Testify.Equal(snaggle.CompatReport{
+		GlibcNeeded: "GLIBC_2.17",
+		MinKernel:   "4.4.0",
+		ISALevel:    3,
+		Binaries: []snaggle.Compatibility{
+			{Path: "/bin/new", GlibcNeeded: "GLIBC_2.17", MinKernel: "4.4.0", ISALevel: 3, BundledGlibc: "GLIBC_2.2.5", Incompatible: true},
+			{Path: "/bin/old", GlibcNeeded: "GLIBC_2.2.5", BundledGlibc: "GLIBC_2.2.5"},
+			{Path: "/lib64/libc.so.6", BundledGlibc: "GLIBC_2.2.5"},
+		},
+	}, report)
+
+	// nothing is flagged without a bundled libc.so.6
+	report, err = snaggle.Compat("elf/testdata/rpath")
+	Assert.Testify.NoError(err)
+	Assert.Testify.NotEmpty(report.GlibcNeeded)
+	for _, binary := range report.Binaries {
+		Assert.Testify.Empty(binary.BundledGlibc)
+		Assert.Testify.False(binary.Incompatible)
+	}
+
+	// only the headers are read: a truncated ELF is ignored, a foreign one is reported without resolving anything.
+	// A directory symlinked into itself is only listed once.
+	root := WorkspaceTempDir(t)
+	for _, path := range []string{"bin/old", "lib64/libc.so.6"} {
+		Assert.Testify.NoError(os.MkdirAll(filepath.Join(root, filepath.Dir(path)), 0755))
+		Assert.Testify.NoError(Copy(filepath.Join("elf/testdata/compat/root", path), filepath.Join(root, path)))
+	}
+	Assert.Testify.NoError(Copy("elf/testdata/foreign/aarch64/root/usr/bin/hello", filepath.Join(root, "bin/hello")))
+	old, err := os.ReadFile(filepath.Join(root, "bin/old"))
+	Assert.Testify.NoError(err)
+	Assert.Testify.NoError(os.WriteFile(filepath.Join(root, "bin/truncated"), old[:32], 0755))
+	Assert.Testify.NoError(os.Symlink(".", filepath.Join(root, "bin/X11"))) // a loop, as in Debian
+
+	report, err = snaggle.Compat(root)
+	Assert.Testify.NoError(err)
+	var paths []string
+	for _, binary := range report.Binaries {
+		paths = append(paths, binary.Path)
+	}
+	Assert.Testify.Equal([]string{"/bin/hello", "/bin/old", "/lib64/libc.so.6"}, paths)
+	Assert.Testify.False(report.Binaries[0].Incompatible) // no aarch64 libc.so.6 is bundled
+}
+
+func TestTargetISA(t *testing.T) {
+	Assert := assert.New(t)
+	src, err := filepath.Abs("elf/testdata/hwcaps")
+	Assert.NoError(err)
+	baseline := filepath.Join(src, "lib/libhw.so")
+	v2 := filepath.Join(src, "lib/glibc-hwcaps/x86-64-v2/libhw.so")
+	v3 := filepath.Join(src, "lib/glibc-hwcaps/x86-64-v3/libhw.so")
+
+	// the baseline by default, even though ld.so on the host may prefer a glibc-hwcaps variant
+	dest := WorkspaceTempDir(t)
+	Assert.NoError(snaggle.Snaggle(filepath.Join(src, "bin/hw"), dest))
+	Assert.True(SameFile(baseline, filepath.Join(dest, "lib/libhw.so")))
+	Assert.NoDirExists(filepath.Join(dest, "lib/glibc-hwcaps"))
+
+	// bin/hw has DT_RUNPATH=$ORIGIN/../lib, so the variant keeps its relative location
+	dest = WorkspaceTempDir(t)
+	Assert.NoError(snaggle.Snaggle(filepath.Join(src, "bin/hw"), dest, snaggle.TargetISA(3)))
+	Assert.True(SameFile(v3, filepath.Join(dest, "lib/glibc-hwcaps/x86-64-v3/libhw.so")))
+	Assert.NoFileExists(filepath.Join(dest, "lib/libhw.so"))
+
+	dest = WorkspaceTempDir(t)
+	Assert.NoError(snaggle.Snaggle(filepath.Join(src, "bin/hw"), dest, snaggle.TargetISA(3), snaggle.AllHWCaps()))
+	Assert.True(SameFile(baseline, filepath.Join(dest, "lib/libhw.so")))
+	Assert.True(SameFile(v2, filepath.Join(dest, "lib/glibc-hwcaps/x86-64-v2/libhw.so")))
+	Assert.True(SameFile(v3, filepath.Join(dest, "lib/glibc-hwcaps/x86-64-v3/libhw.so")))
+
+	// variants reached via an absolute symlink within a sysroot
+	sysroot := BuildSysroot(t)
+	Assert.NoError(os.MkdirAll(filepath.Join(sysroot, "opt/hw/bin"), 0775))
+	Assert.NoError(Copy(filepath.Join(src, "bin/hw"), filepath.Join(sysroot, "opt/hw/bin/hw")))
+	Assert.NoError(os.Symlink("/opt/hwlibs", filepath.Join(sysroot, "opt/hw/lib")))
+	for _, path := range []string{"libhw.so", "glibc-hwcaps/x86-64-v2/libhw.so", "glibc-hwcaps/x86-64-v3/libhw.so"} {
+		Assert.NoError(os.MkdirAll(filepath.Join(sysroot, "opt/hwlibs", filepath.Dir(path)), 0775))
+		Assert.NoError(Copy(filepath.Join(src, "lib", path), filepath.Join(sysroot, "opt/hwlibs", path)))
+	}
+	dest = WorkspaceTempDir(t)
+	Assert.NoError(snaggle.Snaggle("/opt/hw/bin/hw", dest, snaggle.Sysroot(sysroot), snaggle.AllHWCaps()))
+	Assert.True(SameFile(baseline, filepath.Join(dest, "lib/libhw.so")))
+	Assert.True(SameFile(v2, filepath.Join(dest, "lib/glibc-hwcaps/x86-64-v2/libhw.so")))
+	Assert.True(SameFile(v3, filepath.Join(dest, "lib/glibc-hwcaps/x86-64-v3/libhw.so")))
+}
+
+func TestDlopen(t *testing.T) {
+	Assert := assert.New(t)
+	src, err := filepath.Abs("elf/testdata/dlopen")
+	Assert.NoError(err)
+
+	// invisible to ldd, so not snagged by default
+	dest := WorkspaceTempDir(t)
+	Assert.NoError(snaggle.Snaggle(filepath.Join(src, "bin/app"), dest))
+	Assert.NoDirExists(filepath.Join(dest, "lib"))
+
+	// bin/app has DT_RUNPATH=$ORIGIN/../lib, so the plugins keep their relative location
+	dest = WorkspaceTempDir(t)
+	Assert.NoError(snaggle.Snaggle(filepath.Join(src, "bin/app"), dest, snaggle.Dlopen(elf.RECOMMENDED)))
+	for _, plugin := range []string{"libplugin-req.so", "libplugin-dep.so", "libplugin-rec.so"} {
+		Assert.True(SameFile(filepath.Join(src, "lib", plugin), filepath.Join(dest, "lib", plugin)), plugin)
+	}
+	Assert.NoFileExists(filepath.Join(dest, "lib/libplugin-sug.so"))
+
+	dest = WorkspaceTempDir(t)
+	err = snaggle.Snaggle(filepath.Join(src, "bin/broken"), dest, snaggle.Dlopen(elf.REQUIRED))
+	Assert.ErrorIs(err, elf.ErrMissingDependency)
+	Assert.NoDirExists(filepath.Join(dest, "bin"))
+
+	dest = WorkspaceTempDir(t)
+	Assert.NoError(snaggle.Snaggle(filepath.Join(src, "bin/scan"), dest, snaggle.Dlopen(elf.REQUIRED), snaggle.ScanRodata()))
+	Assert.FileExists(filepath.Join(dest, "lib/libplugin-rodata.so"))
+}
+
+func TestFilter(t *testing.T) {
+	Assert := assert.New(t)
+	src, err := filepath.Abs("elf/testdata/filter")
+	Assert.NoError(err)
+
+	// filtees & audit libraries are found via DT_RUNPATH=$ORIGIN(/../lib), so keep their relative location
+	dest := WorkspaceTempDir(t)
+	Assert.NoError(snaggle.Snaggle(filepath.Join(src, "bin/filtered"), dest))
+	for _, lib := range []string{"libfilter.so", "libfiltee.so", "libaux.so", "libaudit.so"} {
+		Assert.True(SameFile(filepath.Join(src, "lib", lib), filepath.Join(dest, "lib", lib)), lib)
+	}
+}
+
+func TestLdSoPreload(t *testing.T) {
+	Assert := assert.New(t)
+	sysroot := BuildSysroot(t)
+	Assert.NoError(os.MkdirAll(filepath.Join(sysroot, "opt/preload"), 0775))
+	for _, lib := range []string{"libpreload.so", "libaux.so"} {
+		Assert.NoError(Copy(filepath.Join("elf/testdata/filter/lib", lib), filepath.Join(sysroot, "opt/preload", lib)))
+	}
+	Assert.NoError(os.WriteFile(filepath.Join(sysroot, "etc/ld.so.preload"), []byte("/opt/preload/libpreload.so\n"), 0664))
+
+	// preloaded libraries are always snagged, at the path listed
+	dest := WorkspaceTempDir(t)
+	Assert.NoError(snaggle.Snaggle("/usr/bin/hello", dest, snaggle.Sysroot(sysroot)))
+	Assert.FileExists(filepath.Join(dest, "opt/preload/libpreload.so"))
+	Assert.FileExists(filepath.Join(dest, "opt/preload/libaux.so"))
+	Assert.NoFileExists(filepath.Join(dest, "etc/ld.so.preload"))
+
+	dest = WorkspaceTempDir(t)
+	Assert.NoError(snaggle.Snaggle("/usr/bin/hello", dest, snaggle.Sysroot(sysroot), snaggle.LdSoPreload()))
+	Assert.True(SameFile(filepath.Join(sysroot, "etc/ld.so.preload"), filepath.Join(dest, "etc/ld.so.preload")))
+}
+
+func TestScripts(t *testing.T) {
+	Assert := assert.New(t)
+	sysroot := BuildSysroot(t)
+	Assert.NoError(Copy(P_hello_dynamic, filepath.Join(sysroot, "usr/bin/env")))
+	scripts := map[string]string{
+		"opt/app/run.sh":     "#!/usr/bin/hello\necho hello\n",
+		"opt/app/env.sh":     "#!/usr/bin/env -S GREETING=hi hello --flag\n",
+		"opt/app/nested.sh":  "#! /opt/app/run.sh -x\n",
+		"opt/app/tab.sh":     "#!/usr/bin/env\thello\n",
+		"opt/broken/miss.sh": "#!/usr/bin/env missing\n",
+	}
+	for path, script := range scripts {
+		Assert.NoError(os.MkdirAll(filepath.Join(sysroot, filepath.Dir(path)), 0775))
+		Assert.NoError(os.WriteFile(filepath.Join(sysroot, path), []byte(script), 0775))
+	}
+	libc := filepath.Join(sysroot, "opt/sysroot-libs/libc.so.6")
+
+	// scripts are skipped by default
+	dest := WorkspaceTempDir(t)
+	Assert.NoError(snaggle.Snaggle("/opt/app", dest, snaggle.Sysroot(sysroot)))
+	Assert.FileExists(filepath.Join(dest, "bin/hello_dynamic"))
+	Assert.NoFileExists(filepath.Join(dest, "bin/run.sh"))
+
+	dest = WorkspaceTempDir(t)
+	Assert.NoError(snaggle.Snaggle("/opt/app/run.sh", dest, snaggle.Sysroot(sysroot), snaggle.Scripts()))
+	Assert.True(SameFile(filepath.Join(sysroot, "opt/app/run.sh"), filepath.Join(dest, "bin/run.sh")))
+	Assert.True(SameFile(filepath.Join(sysroot, "opt/app/hello_dynamic"), filepath.Join(dest, "usr/bin/hello")))
+	Assert.True(SameFile(libc, filepath.Join(dest, "lib64/libc.so.6")))
+	Assert.FileExists(filepath.Join(dest, "lib64/ld-linux-x86-64.so.2"))
+
+	// env finds the command in the PATH, nested interpreters are followed
+	dest = WorkspaceTempDir(t)
+	Assert.NoError(snaggle.Snaggle("/opt/app", dest, snaggle.Sysroot(sysroot), snaggle.Scripts()))
+	for _, path := range []string{"bin/run.sh", "bin/env.sh", "bin/nested.sh", "bin/tab.sh", "bin/hello_dynamic", "bin/hello", "usr/bin/env", "usr/bin/hello", "opt/app/run.sh"} {
+		Assert.FileExists(filepath.Join(dest, path))
+	}
+	Assert.True(SameFile(libc, filepath.Join(dest, "lib64/libc.so.6")))
+
+	// copying keeps every path
+	dest = WorkspaceTempDir(t)
+	Assert.NoError(snaggle.Snaggle("/opt/app", dest, snaggle.Sysroot(sysroot), snaggle.Scripts(), snaggle.Copy()))
+	for _, path := range []string{"opt/app/run.sh", "opt/app/env.sh", "usr/bin/env", "usr/bin/hello", "bin/hello"} {
+		Assert.FileExists(filepath.Join(dest, path))
+	}
+	Assert.NoFileExists(filepath.Join(dest, "bin/run.sh"))
+
+	dest = filepath.Join(WorkspaceTempDir(t), "dest")
+	err := snaggle.Snaggle("/opt/broken/miss.sh", dest, snaggle.Sysroot(sysroot), snaggle.Scripts())
+	Assert.ErrorIs(err, snaggle.ErrScriptInterpreter)
+	Assert.ErrorContains(err, "missing not found in PATH")
+	Assert.NoDirExists(dest) // nothing is snagged
+
+	// the interpreter needs libhw.so, which is not in the sysroot
+	for _, dir := range []string{"opt/interp", "opt/nolib"} {
+		Assert.NoError(os.MkdirAll(filepath.Join(sysroot, dir), 0775))
+	}
+	Assert.NoError(Copy("elf/testdata/hwcaps/bin/hw", filepath.Join(sysroot, "opt/interp/hw")))
+	Assert.NoError(Copy(P_hello_dynamic, filepath.Join(sysroot, "opt/nolib/hello_dynamic")))
+	Assert.NoError(os.WriteFile(filepath.Join(sysroot, "opt/nolib/hw.sh"), []byte("#!/opt/interp/hw\n"), 0775))
+	for _, path := range []string{"/opt/nolib", "/opt/nolib/hw.sh"} {
+		dest = filepath.Join(WorkspaceTempDir(t), "dest")
+		err = snaggle.Snaggle(path, dest, snaggle.Sysroot(sysroot), snaggle.Scripts())
+		Assert.ErrorIs(err, snaggle.ErrScriptInterpreter)
+		var missing *elf.MissingDependencyError
+		Assert.ErrorAs(err, &missing)
+		Assert.NoDirExists(dest, path) // nothing is snagged
+	}
+}
+
+func TestCommands(t *testing.T) {
+	Assert := assert.New(t)
+	sysroot := BuildSysroot(t)
+	for _, dir := range []string{"bin", "opt/tools", "opt/entrypoint"} {
+		Assert.NoError(os.MkdirAll(filepath.Join(sysroot, dir), 0775))
+	}
+	for _, command := range []string{"bin/sh", "usr/bin/sed", "usr/bin/env", "opt/tools/tool"} {
+		Assert.NoError(Copy(P_hello_dynamic, filepath.Join(sysroot, command)))
+	}
+	entrypoint := `#!/bin/sh
+set -eu
+greet() { echo "hello $1"; }
+greet world
+[ -d /tmp ] && cd /tmp
+if command -v tool > /dev/null; then
+	version=$(sed -n 1p /etc/version)
+fi
+env GREETING=hi hello --flag
+/opt/app/hello_dynamic
+./relative.sh
+missing-tool --help
+$TOOL
+exec "$@"
+`
+	Assert.NoError(os.WriteFile(filepath.Join(sysroot, "opt/entrypoint/entrypoint.sh"), []byte(entrypoint), 0775))
+
+	dest := WorkspaceTempDir(t)
+	err := snaggle.Snaggle("/opt/entrypoint", dest, snaggle.Sysroot(sysroot), snaggle.Commands())
+	for _, path := range []string{"bin/entrypoint.sh", "bin/sh", "bin/sed", "bin/env", "bin/hello", "opt/app/hello_dynamic", "lib64/libc.so.6"} {
+		Assert.FileExists(filepath.Join(dest, path))
+	}
+	Assert.NoFileExists(filepath.Join(dest, "bin/tool")) // only looked up
+	Assert.NoFileExists(filepath.Join(dest, "bin/greet"))
+
+	Assert.ErrorIs(err, snaggle.ErrUnresolvedCommand)
+	var unresolved *snaggle.UnresolvedCommandError
+	if Assert.ErrorAs(err, &unresolved) {
+		script := "/opt/entrypoint/entrypoint.sh"
+		expected := []snaggle.Command{
+			{Name: "./relative.sh", Script: script, Line: 11},
+			{Name: "missing-tool", Script: script, Line: 12},
+			{Name: "$TOOL", Script: script, Line: 13, Dynamic: true},
+			{Name: `"$@"`, Script: script, Line: 14, Dynamic: true},
+		}
+		Assert.Equal(expected, unresolved.Unresolved)
+	}
+
+	// a custom PATH
+	Assert.NoError(os.WriteFile(filepath.Join(sysroot, "opt/entrypoint/tool.sh"), []byte("#!/bin/sh\ntool\n"), 0775))
+	dest = WorkspaceTempDir(t)
+	Assert.NoError(snaggle.Snaggle("/opt/entrypoint/tool.sh", dest, snaggle.Sysroot(sysroot), snaggle.Commands(), snaggle.Path("/opt/tools:/usr/bin")))
+	Assert.FileExists(filepath.Join(dest, "bin/tool"))
+
+	// an empty PATH is the default
+	Assert.NoError(os.WriteFile(filepath.Join(sysroot, "opt/entrypoint/sed.sh"), []byte("#!/bin/sh\nsed\n"), 0775))
+	dest = WorkspaceTempDir(t)
+	Assert.NoError(snaggle.Snaggle("/opt/entrypoint/sed.sh", dest, snaggle.Sysroot(sysroot), snaggle.Commands(), snaggle.Path("")))
+	Assert.FileExists(filepath.Join(dest, "bin/sed"))
+
+	// only with Commands
+	dest = WorkspaceTempDir(t)
+	Assert.NoError(snaggle.Snaggle("/opt/entrypoint/tool.sh", dest, snaggle.Sysroot(sysroot), snaggle.Scripts(), snaggle.Path("/opt/tools")))
+	Assert.NoFileExists(filepath.Join(dest, "bin/tool"))
+
+	// hw needs libhw.so, which is not in the sysroot, so neither sed nor anything else is snagged
+	Assert.NoError(Copy("elf/testdata/hwcaps/bin/hw", filepath.Join(sysroot, "opt/tools/hw")))
+	Assert.NoError(os.WriteFile(filepath.Join(sysroot, "opt/entrypoint/hw.sh"), []byte("#!/bin/sh\nsed\nhw\n"), 0775))
+	dest = filepath.Join(WorkspaceTempDir(t), "dest")
+	err = snaggle.Snaggle("/opt/entrypoint/hw.sh", dest, snaggle.Sysroot(sysroot), snaggle.Commands(), snaggle.Path("/usr/bin:/opt/tools"))
+	var missing *elf.MissingDependencyError
+	Assert.ErrorAs(err, &missing)
+	Assert.NoDirExists(dest)
+}
+
+func TestFromProcess(t *testing.T) {
+	Assert := assert.New(t)
+	plugged, err := filepath.Abs("elf/testdata/process/bin/plugged")
+	Assert.NoError(err)
+	libdir := filepath.Join(filepath.Dir(plugged), "../lib")
+
+	process := exec.Command(plugged)
+	stdout, err := process.StdoutPipe()
+	Assert.NoError(err)
+	if !Assert.NoError(process.Start()) {
+		return
+	}
+	defer func() {
+		_ = process.Process.Kill()
+		_ = process.Wait()
+	}()
+	ready, err := bufio.NewReader(stdout).ReadString('\n')
+	if !Assert.NoError(err) || !Assert.Equal("ready\n", ready) {
+		return // plugin not loaded
+	}
+
+	// the plugin is invisible to ldd
+	dest := WorkspaceTempDir(t)
+	Assert.NoError(snaggle.Snaggle(plugged, dest))
+	Assert.NoFileExists(filepath.Join(dest, "lib64/libplugged-plugin.so"))
+
+	dest = WorkspaceTempDir(t)
+	Assert.NoError(snaggle.FromProcess(process.Process.Pid, dest))
+	Assert.True(SameFile(plugged, filepath.Join(dest, "bin/plugged")))
+	Assert.True(SameFile(filepath.Join(libdir, "libplugged-plugin.so"), filepath.Join(dest, "lib64/libplugged-plugin.so")))
+	Assert.True(SameFile(filepath.Join(libdir, "libplugged-dep.so"), filepath.Join(dest, "lib64/libplugged-dep.so")))
+	Assert.True(SameFile(P_libc, filepath.Join(dest, "lib64/libc.so.6")))
+	Assert.FileExists(filepath.Join(dest, P_ld_linux))
+	Assert.NoFileExists(filepath.Join(dest, "bin/libc.so.6"))
+
+	// mappings which are already dependencies of the executable are not snagged again, even via another path
+	var verbose strings.Builder
+	log.SetOutput(&verbose)
+	t.Cleanup(func() { log.SetOutput(os.Stdout) })
+	dest = WorkspaceTempDir(t)
+	Assert.NoError(snaggle.FromProcess(process.Process.Pid, dest, snaggle.Verbose()))
+	libc, err := filepath.EvalSymlinks(P_libc)
+	Assert.NoError(err)
+	Assert.NotContains(verbose.String(), "skip "+libc+" ")
+
+	dest = WorkspaceTempDir(t)
+	err = snaggle.FromProcess(process.Process.Pid, dest, snaggle.InPlace())
+	Assert.ErrorIs(err, snaggle.ErrProcessOption)
+	var invocationError *snaggle.InvocationError
+	Assert.ErrorAs(err, &invocationError)
+}
+
+func TestProcessNamespace(t *testing.T) {
+	Assert := assert.New(t)
+	sleep, err := exec.LookPath("sleep")
+	Assert.NoError(err)
+	sleep, err = filepath.EvalSymlinks(sleep)
+	Assert.NoError(err)
+
+	process := exec.Command("unshare", "--mount", sleep, "30")
+	if err := process.Start(); err != nil {
+		t.Skip("cannot unshare:", err)
+	}
+	defer func() {
+		_ = process.Process.Kill()
+		_ = process.Wait()
+	}()
+	exe := filepath.Join("/proc", strconv.Itoa(process.Process.Pid), "exe")
+	for range 100 { // until unshare has exec'd sleep, in the new namespace
+		if running, _ := os.Readlink(exe); running == sleep {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if running, _ := os.Readlink(exe); running != sleep {
+		t.Skip("cannot unshare the mount namespace")
+	}
+
+	err = snaggle.FromProcess(process.Process.Pid, WorkspaceTempDir(t))
+	Assert.ErrorIs(err, snaggle.ErrProcessNamespace)
+	var snaggleError *snaggle.SnaggleError
+	Assert.ErrorAs(err, &snaggleError) // not an InvocationError: PID & DESTINATION are valid arguments
+}
+
+func TestOriginOutsideRoot(t *testing.T) {
+	Assert := assert.New(t)
+	bin, err := filepath.Abs("elf/testdata/escape/opt/app/bin/origin")
+	Assert.NoError(err)
+	lib := filepath.Join(filepath.Dir(bin), "../../lib/liborigin.so")
+
+	// DESTINATION/bin/../../lib would be outside DESTINATION
+	dest := filepath.Join(WorkspaceTempDir(t), "dest")
+	Assert.NoError(snaggle.Snaggle(bin, dest))
+	Assert.True(SameFile(bin, filepath.Join(dest, "bin/origin")))
+	Assert.True(SameFile(lib, filepath.Join(dest, "lib64/liborigin.so")))
+	Assert.NoDirExists(filepath.Join(dest, "../lib"))
+
+	// in place, the library is already where it is needed
+	dest = WorkspaceTempDir(t)
+	Assert.NoError(snaggle.Snaggle(bin, dest, snaggle.InPlace()))
+	Assert.NoFileExists(filepath.Join(dest, "lib64/liborigin.so"))
+}
+
+func TestPathnameOutsideRoot(t *testing.T) {
+	Assert := assert.New(t)
+	bin, err := filepath.Abs("elf/testdata/escape/opt/app/bin/pathname")
+	Assert.NoError(err)
+
+	// DT_NEEDED ../../lib/libpathname.so, relative to DESTINATION/bin, would be outside DESTINATION and ld.so
+	// never searches for a pathname elsewhere
+	dest := filepath.Join(WorkspaceTempDir(t), "dest")
+	err = snaggle.Snaggle(bin, dest)
+	Assert.ErrorIs(err, snaggle.ErrOutsideRoot)
+	Assert.ErrorContains(err, "../../lib/libpathname.so needed by "+bin)
+	var snaggleError *snaggle.SnaggleError
+	Assert.ErrorAs(err, &snaggleError)
+	Assert.NoDirExists(dest) // nothing is snagged
+	Assert.NoDirExists(filepath.Join(dest, "../lib"))
+}
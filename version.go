@@ -2,4 +2,4 @@
 
 package snaggle
 
-const Version = "1.2.1"
+const Version = "1.3.0"
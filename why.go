@@ -0,0 +1,170 @@
+package snaggle
+
+import (
+	debug_elf "debug/elf"
+	"errors"
+	"path/filepath"
+	"slices"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/MusicalNinjaDad/snaggle/elf"
+	"github.com/MusicalNinjaDad/snaggle/internal"
+)
+
+// Why a library would be snagged for a binary
+type Reason struct {
+	// Path of the binary which needs the library
+	Binary string
+	// Every chain of `DT_NEEDED` entries which leads from Binary to the library, each starting at Binary
+	Chains [][]elf.Edge
+	// How the library was located for Binary, including every [elf.Candidate] searched.
+	//  - Source is UNRESOLVED if it was not searched for: if it is provided by the interpreter or
+	//    could not be found (Path == "")
+	Library elf.Library
+}
+
+// Why explains why library would be snagged for any of paths, returning a [Reason] for each binary which
+// needs it (directly or indirectly) in the same order as paths.
+//
+// library can be a soname (e.g. `libLLVM.so.17`), filename or full path. Any paths which are directories
+// are searched recursively, e.g. to explain the contents of an existing root created by [Snaggle]
+// use [Sysroot](root) and paths `/`.
+//
+// Only the Options [Sysroot()], [TargetISA()], [Dlopen()] & [ScanRodata()] are relevant, any others are ignored.
+func Why(library string, paths []string, opts ...Option) ([]Reason, error) {
+	options := options{}
+	for _, optfn := range opts {
+		optfn(&options)
+	}
+	options.recursive = true
+
+	elfopts := []elf.Option{elf.Explain()}
+	if options.sysroot != "" {
+		sysroot, err := filepath.Abs(options.sysroot)
+		if err != nil {
+			return nil, &InvocationError{Path: library, Target: options.sysroot, err: err}
+		}
+		options.sysroot = sysroot
+		elfopts = append(elfopts, elf.Sysroot(sysroot))
+	}
+	if options.isaLevel != 0 {
+		elfopts = append(elfopts, elf.TargetISA(options.isaLevel))
+	}
+	if options.dlopen != "" {
+		elfopts = append(elfopts, elf.Dlopen(options.dlopen))
+	}
+	if options.scanRodata {
+		elfopts = append(elfopts, elf.ScanRodata())
+	}
+
+	var files []string
+	for _, path := range paths {
+		if options.sysroot != "" {
+			path = filepath.Join("/", path)
+		}
+		if !internal.IsDir(options.host(path)) {
+			files = append(files, path)
+			continue
+		}
+		dir, err := listDir(path, options)
+		if err != nil {
+			return nil, &SnaggleError{Src: path, err: err}
+		}
+		files = append(files, dir...)
+	}
+
+	reasons := make([][]Reason, len(files))
+	parsers := new(errgroup.Group)
+	for idx, path := range files {
+		parsers.Go(func() error {
+			graph, err := elf.NewGraph(path, elfopts...)
+			var badelf *debug_elf.FormatError
+			var missing *elf.MissingDependencyError
+			switch {
+			case errors.As(err, &badelf):
+				return nil // not an ELF, so nothing is needed
+			case err != nil && !errors.As(err, &missing):
+				return &SnaggleError{Src: path, err: err}
+			}
+			reasons[idx] = why(library, graph, options.sysroot, missing)
+			return nil
+		})
+	}
+	if err := parsers.Wait(); err != nil {
+		return nil, err
+	}
+	return slices.Concat(reasons...), nil
+}
+
+// A [Reason] for each distinct file matching library in graph, with any libraries which could not be found
+// taken from missing (which may be nil)
+func why(library string, graph *elf.Graph, sysroot string, missing *elf.MissingDependencyError) []Reason {
+	matches := func(edge elf.Edge) bool {
+		return edge.Soname == library ||
+			edge.To != "" && (edge.To == library || filepath.Base(edge.To) == library || internal.InRoot(sysroot, edge.To) == library)
+	}
+
+	var reasons []Reason
+	reason := func(edge elf.Edge) *Reason {
+		for idx := range reasons {
+			if reasons[idx].Library.Path == edge.To && (edge.To != "" || reasons[idx].Library.Soname == edge.Soname) {
+				return &reasons[idx]
+			}
+		}
+		lib := elf.Library{Soname: edge.Soname, Path: edge.To, NeededBy: edge.From}
+		for _, located := range graph.Root.Libraries {
+			if edge.To != "" && located.Path == edge.To {
+				lib = located
+			}
+		}
+		if edge.To == "" && missing != nil {
+			for _, notFound := range missing.Missing {
+				if notFound.Soname == edge.Soname && notFound.NeededBy == edge.From {
+					lib = notFound
+				}
+			}
+		}
+		reasons = append(reasons, Reason{Binary: graph.Root.Path, Library: lib})
+		return &reasons[len(reasons)-1]
+	}
+
+	// only objects which lead to library need to be walked
+	leads := make(map[string]bool)
+	var queue []string
+	for _, edge := range graph.Edges {
+		if matches(edge) && !leads[edge.From] {
+			leads[edge.From] = true
+			queue = append(queue, edge.From)
+		}
+	}
+	for len(queue) > 0 {
+		for _, edge := range graph.NeededBy(queue[0]) {
+			if !leads[edge.From] {
+				leads[edge.From] = true
+				queue = append(queue, edge.From)
+			}
+		}
+		queue = queue[1:]
+	}
+
+	// depth-first through every chain which does not loop back on itself
+	var walk func(path string, chain []elf.Edge)
+	walk = func(path string, chain []elf.Edge) {
+		for _, edge := range graph.Needs(path) {
+			switch {
+			case matches(edge):
+				reason := reason(edge)
+				reason.Chains = append(reason.Chains, append(slices.Clone(chain), edge))
+			case !leads[edge.To], edge.To == graph.Root.Path:
+				continue
+			case slices.ContainsFunc(chain, func(e elf.Edge) bool { return e.From == edge.To }):
+				continue // cycle
+			default:
+				walk(edge.To, append(slices.Clone(chain), edge))
+			}
+		}
+	}
+	walk(graph.Root.Path, nil)
+	return reasons
+}